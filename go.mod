@@ -2,4 +2,8 @@ module github.com/JAbduvohidov/apm-ibank-core
 
 go 1.13
 
-require github.com/mattn/go-sqlite3 v2.0.3+incompatible
+require (
+	github.com/lib/pq v1.3.0
+	github.com/mattn/go-sqlite3 v2.0.3+incompatible
+	golang.org/x/crypto v0.0.0-20211117183948-ae814b36b871
+)
@@ -1,23 +1,34 @@
 package core
 
 import (
+	"context"
 	"database/sql"
 	"errors"
 	"fmt"
-	"github.com/JAbduvohidov/apm-ibank-core/pkg/queries"
 	"strconv"
 	"time"
 )
 
 var (
-	ErrInvalidPass         = errors.New("invalid password")
-	ErrLoginExist          = errors.New("login exits")
-	ErrPhoneNumberExist    = errors.New("phone number exits")
-	ErrPhoneNumberNotExist = errors.New("phone number does not exits")
-	ErrServiceExist        = errors.New("service exits")
-	ErrATMExist            = errors.New("atm exits")
-	ErrClientIsLocked      = errors.New("client is locked")
-	ErrServiceNotExist     = errors.New("service not found")
+	ErrInvalidPass           = errors.New("invalid password")
+	ErrLoginExist            = errors.New("login exits")
+	ErrPhoneNumberExist      = errors.New("phone number exits")
+	ErrPhoneNumberNotExist   = errors.New("phone number does not exits")
+	ErrServiceExist          = errors.New("service exits")
+	ErrATMExist              = errors.New("atm exits")
+	ErrClientIsLocked        = errors.New("client is locked")
+	ErrServiceNotExist       = errors.New("service not found")
+	ErrLoginNotFound         = errors.New("login not found")
+	ErrClientNotExist        = errors.New("client not found")
+	ErrInvalidCredentials    = errors.New("invalid login or password")
+	ErrAccountNotOwned       = errors.New("account does not belong to client")
+	ErrAccountNotExist       = errors.New("account not found")
+	ErrTargetAccountNotExist = errors.New("target account not found")
+	ErrRecipientHasNoAccount = errors.New("recipient has no account")
+	ErrInsufficientFunds     = errors.New("insufficient funds")
+	ErrInvalidAmount         = errors.New("amount must be positive")
+	ErrSenderIsLocked        = fmt.Errorf("sender: %w", ErrClientIsLocked)
+	ErrRecipientIsLocked     = fmt.Errorf("recipient: %w", ErrClientIsLocked)
 )
 
 type QueryError struct {
@@ -31,13 +42,13 @@ type DbError struct {
 
 type Account struct {
 	Id      int64
-	Balance float64
+	Balance Money
 }
 
 type AccountWithClientId struct {
 	Id       int64
 	ClientId int64
-	Balance  float64
+	Balance  Money
 }
 
 type ATM struct {
@@ -55,22 +66,44 @@ type Client struct {
 	Status      string
 }
 
+type AuthResult struct {
+	ClientId    int64
+	PhoneNumber int64
+	Status      string
+	LastLoginAt time.Time
+}
+
+// Journal is one side of an operation as seen by the client owning
+// AccountId. A transfer is journaled once for the sender and once for the
+// recipient. Payments for services record the payer reference and the fee
+// charged on top of Amount, zero when there was none.
 type Journal struct {
-	Id            int64
-	Date          string
-	Type          string
-	TransferredTo string
-	Amount        float64
+	Id                    int64
+	Date                  time.Time
+	Type                  string
+	Direction             string
+	AccountId             int64
+	Counterparty          string
+	CounterpartyAccountId int64
+	TransferredTo         string
+	Amount                Money
+	Rate                  Rate
+	Reference             string
+	Fee                   Money
 }
 
 const (
-	Transfer = "transfer"
-	Service  = "service"
-	Active   = "active"
-	Locked   = "locked"
-	Clients  = "clients"
-	ATMs     = "atms"
-	Accounts = "accounts"
+	Transfer   = "transfer"
+	Service    = "service"
+	Conversion = "conversion"
+	Reversal   = "reversal"
+	Incoming   = "incoming"
+	Outgoing   = "outgoing"
+	Active     = "active"
+	Locked     = "locked"
+	Clients    = "clients"
+	ATMs       = "atms"
+	Accounts   = "accounts"
 )
 
 func (receiver *QueryError) Unwrap() error {
@@ -97,79 +130,197 @@ func dbError(err error) *DbError {
 	return &DbError{Err: err}
 }
 
-func Init(db *sql.DB) (err error) {
-	ddls := []string{queries.ClientsDDL, queries.AccountsDDL, queries.JournalDDL, queries.ServicesDDL, queries.AtmsDDL}
-	for _, ddl := range ddls {
-		_, err = db.Exec(ddl)
-		if err != nil {
-			return dbError(err)
-		}
+func lastInsertId(result sql.Result, err error) (int64, error) {
+	if err != nil {
+		return 0, err
 	}
-	return nil
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return 0, dbError(err)
+	}
+	return id, nil
 }
 
-func checkClientExist(login string, phoneNumber int64, db *sql.DB) (err error) {
-	var dbLogin string
-	var dbPhoneNumber int64
+// Init brings the schema up to date, see Migrate.
+func Init(db *sql.DB) (err error) {
+	return InitContext(context.Background(), db)
+}
 
-	if login != "" {
-		err = db.QueryRow(
-			queries.LoginExistSQL,
-			login).Scan(&dbLogin)
+// InitContext is Init bound to ctx. Like every other ...Context function it
+// fails once ctx is done and rolls back the transaction in progress.
+func InitContext(ctx context.Context, db *sql.DB) (err error) {
+	return sqliteBank(db).Init(ctx)
+}
 
-		if !errors.Is(err, sql.ErrNoRows) {
+func (receiver *Bank) Init(ctx context.Context) (err error) {
+	store, ok := receiver.store.(datedInitStore)
+	if ok {
+		return store.initAt(ctx, receiver.now)
+	}
+	return receiver.store.Init(ctx)
+}
+
+func checkClientExist(ctx context.Context, login string, phoneNumber int64, tx Tx) (err error) {
+	if login != "" {
+		_, err = tx.GetClientByLogin(ctx, login)
+		if err == nil {
 			return ErrLoginExist
 		}
+		if !errors.Is(err, ErrLoginNotFound) {
+			return err
+		}
 	}
 
-	err = db.QueryRow(
-		queries.PhoneNumberExistSQL,
-		phoneNumber).Scan(&dbPhoneNumber)
-
-	if !errors.Is(err, sql.ErrNoRows) {
+	_, err = tx.GetClientByPhoneNumber(ctx, phoneNumber)
+	if err == nil {
 		return ErrPhoneNumberExist
 	}
+	if !errors.Is(err, ErrPhoneNumberNotExist) {
+		return err
+	}
 
 	return nil
 }
 
-func checkServiceExist(name string, db *sql.DB) (err error) {
-	var dbName string
+type accountState struct {
+	id          int64
+	clientId    int64
+	balance     Money
+	status      string
+	phoneNumber int64
+}
 
-	err = db.QueryRow(
-		queries.ServiceExistSQL,
-		name).Scan(&dbName)
+func getAccountState(ctx context.Context, accountId int64, tx Tx) (state accountState, err error) {
+	account, err := tx.GetAccount(ctx, accountId)
+	if err != nil {
+		return accountState{}, err
+	}
 
-	if !errors.Is(err, sql.ErrNoRows) {
-		return ErrServiceExist
+	client, err := tx.GetClient(ctx, account.ClientId)
+	if err != nil {
+		return accountState{}, err
 	}
 
-	return nil
+	return accountState{
+		id:          account.Id,
+		clientId:    account.ClientId,
+		balance:     account.Balance,
+		status:      client.Status,
+		phoneNumber: client.PhoneNumber,
+	}, nil
 }
 
-func checkATMExist(location string, db *sql.DB) (err error) {
-	var dbLocation string
+// withdraw checks the session, the sender and the source account inside tx
+// and takes amount from the account.
+func (receiver *Bank) withdraw(ctx context.Context, session Session, accountId int64, amount Money, tx Tx) (sender accountState, err error) {
+	clientId, err := receiver.sessionClientId(ctx, session, tx)
+	if err != nil {
+		return accountState{}, err
+	}
+
+	sender, err = getAccountState(ctx, accountId, tx)
+	if err != nil {
+		return accountState{}, err
+	}
 
-	err = db.QueryRow(
-		queries.AtmExistSQL,
-		location).Scan(&dbLocation)
+	if sender.clientId != clientId {
+		return accountState{}, ErrAccountNotOwned
+	}
 
-	if !errors.Is(err, sql.ErrNoRows) {
-		return ErrATMExist
+	if sender.status == Locked {
+		return accountState{}, ErrSenderIsLocked
 	}
 
-	return nil
+	if sender.balance.Currency != amount.Currency {
+		return accountState{}, ErrCurrencyMismatch
+	}
+
+	if sender.balance.Amount < amount.Amount {
+		return accountState{}, ErrInsufficientFunds
+	}
+
+	err = tx.UpdateBalance(ctx, accountId, -1*amount.Amount)
+	if err != nil {
+		return accountState{}, err
+	}
+
+	return sender, nil
 }
 
-func AddClient(name, login, password string, phoneNumber int64, db *sql.DB) (err error) {
-	err = checkClientExist(login, phoneNumber, db)
+func deposit(ctx context.Context, accountId int64, amount Money, tx Tx) (recipient accountState, err error) {
+	recipient, err = getAccountState(ctx, accountId, tx)
+	if errors.Is(err, ErrAccountNotExist) {
+		return accountState{}, ErrTargetAccountNotExist
+	}
 	if err != nil {
-		return err
+		return accountState{}, err
+	}
+
+	if recipient.status == Locked {
+		return accountState{}, ErrRecipientIsLocked
+	}
+
+	if recipient.balance.Currency != amount.Currency {
+		return accountState{}, ErrCurrencyMismatch
+	}
+
+	return recipient, tx.UpdateBalance(ctx, accountId, amount.Amount)
+}
+
+// addToJournal dates journal with the bank's clock and stores it for
+// clientId.
+func (receiver *Bank) addToJournal(ctx context.Context, clientId int64, journal Journal, tx Tx) (journalId int64, err error) {
+	journal.Date = receiver.now()
+	return tx.AddJournal(ctx, clientId, journal)
+}
+
+// addTransferToJournal journals the outgoing side for the sender and the
+// incoming side for the recipient and returns the id of the outgoing one.
+func (receiver *Bank) addTransferToJournal(ctx context.Context, kind, transferredTo string, sender, recipient accountState, sent, received Money, rate Rate, tx Tx) (journalId int64, err error) {
+	journalId, err = receiver.addToJournal(ctx, sender.clientId, Journal{
+		Type:                  kind,
+		Direction:             Outgoing,
+		AccountId:             sender.id,
+		Counterparty:          strconv.FormatInt(recipient.phoneNumber, 10),
+		CounterpartyAccountId: recipient.id,
+		TransferredTo:         transferredTo,
+		Amount:                sent,
+		Rate:                  rate,
+	}, tx)
+	if err != nil {
+		return 0, err
+	}
+
+	_, err = receiver.addToJournal(ctx, recipient.clientId, Journal{
+		Type:                  kind,
+		Direction:             Incoming,
+		AccountId:             recipient.id,
+		Counterparty:          strconv.FormatInt(sender.phoneNumber, 10),
+		CounterpartyAccountId: sender.id,
+		TransferredTo:         transferredTo,
+		Amount:                received,
+		Rate:                  rate,
+	}, tx)
+	if err != nil {
+		return 0, err
 	}
 
-	tx, err := db.Begin()
+	return journalId, nil
+}
+
+func AddClient(name, login, password string, phoneNumber int64, db *sql.DB) (err error) {
+	return AddClientContext(context.Background(), name, login, password, phoneNumber, db)
+}
+
+func AddClientContext(ctx context.Context, name, login, password string, phoneNumber int64, db *sql.DB) (err error) {
+	return sqliteBank(db).AddClient(ctx, name, login, password, phoneNumber)
+}
+
+func (receiver *Bank) AddClient(ctx context.Context, name, login, password string, phoneNumber int64) (err error) {
+	tx, err := receiver.store.Begin(ctx, TxOptions{})
 	if err != nil {
-		return dbError(err)
+		return err
 	}
 
 	defer func() {
@@ -180,13 +331,23 @@ func AddClient(name, login, password string, phoneNumber int64, db *sql.DB) (err
 		err = tx.Commit()
 	}()
 
-	_, err = tx.Exec(
-		queries.AddClientSQL,
-		sql.Named("name", name),
-		sql.Named("login", login),
-		sql.Named("password", password),
-		sql.Named("phone_number", phoneNumber),
-	)
+	err = checkClientExist(ctx, login, phoneNumber, tx)
+	if err != nil {
+		return err
+	}
+
+	passwordHash, err := receiver.hasher.Hash(password)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.AddClient(ctx, Client{
+		Name:        name,
+		Login:       login,
+		Password:    passwordHash,
+		PhoneNumber: phoneNumber,
+		Status:      Active,
+	})
 	if err != nil {
 		return err
 	}
@@ -194,8 +355,23 @@ func AddClient(name, login, password string, phoneNumber int64, db *sql.DB) (err
 	return nil
 }
 
-func AddAccount(phoneNumber, balance int64, db *sql.DB) (err error) {
-	tx, err := db.Begin()
+func AddAccount(phoneNumber int64, balance Money, db *sql.DB) (err error) {
+	return AddAccountContext(context.Background(), phoneNumber, balance, db)
+}
+
+func AddAccountContext(ctx context.Context, phoneNumber int64, balance Money, db *sql.DB) (err error) {
+	return sqliteBank(db).AddAccount(ctx, phoneNumber, balance)
+}
+
+func (receiver *Bank) AddAccount(ctx context.Context, phoneNumber int64, balance Money) (err error) {
+	if !IsSupportedCurrency(balance.Currency) {
+		return ErrUnsupportedCurrency
+	}
+	if balance.Amount < 0 {
+		return ErrInvalidAmount
+	}
+
+	tx, err := receiver.store.Begin(ctx, TxOptions{})
 	if err != nil {
 		return err
 	}
@@ -208,68 +384,67 @@ func AddAccount(phoneNumber, balance int64, db *sql.DB) (err error) {
 		err = tx.Commit()
 	}()
 
-	var clientId int64
-
-	err = tx.QueryRow(
-		queries.GetClientIdByPhoneNumberSQL,
-		phoneNumber,
-	).Scan(&clientId)
+	client, err := tx.GetClientByPhoneNumber(ctx, phoneNumber)
 	if err != nil {
 		return err
 	}
-	balance = balance * 100
-	_, err = tx.Exec(
-		queries.AddAccountSQL,
-		sql.Named("client_id", clientId),
-		sql.Named("balance", balance),
-	)
+
+	accountId, err := tx.AddAccount(ctx, client.Id, balance)
 	if err != nil {
 		return err
 	}
 
-	return nil
+	return receiver.post(ctx, systemOpening, 0, []LedgerEntry{
+		accountEntry(accountId, balance),
+		systemEntry(systemOpening, negate(balance)),
+	}, tx)
 }
 
 func AddService(name string, db *sql.DB) (err error) {
-	err = checkServiceExist(name, db)
-	if err != nil {
-		return err
-	}
+	return AddServiceContext(context.Background(), name, db)
+}
+
+func AddServiceContext(ctx context.Context, name string, db *sql.DB) (err error) {
+	return sqliteBank(db).AddService(ctx, name)
+}
 
-	tx, err := db.Begin()
+func (receiver *Bank) AddService(ctx context.Context, name string) (err error) {
+	tx, err := receiver.store.Begin(ctx, TxOptions{})
 	if err != nil {
 		return err
 	}
 
 	defer func() {
 		if err != nil {
-			rollbackErr := tx.Rollback()
-			_ = rollbackErr
+			_ = tx.Rollback()
 			return
 		}
 		err = tx.Commit()
 	}()
 
-	_, err = tx.Exec(
-		queries.AddServiceSQL,
-		sql.Named("name", name),
-	)
+	exists, err := tx.ServiceExists(ctx, name)
 	if err != nil {
 		return err
 	}
+	if exists {
+		return ErrServiceExist
+	}
 
-	return nil
+	return tx.AddService(ctx, name)
 }
 
 func AddAtm(name, location string, db *sql.DB) (err error) {
-	err = checkATMExist(location, db)
-	if err != nil {
-		return err
-	}
+	return AddAtmContext(context.Background(), name, location, db)
+}
+
+func AddAtmContext(ctx context.Context, name, location string, db *sql.DB) (err error) {
+	return sqliteBank(db).AddAtm(ctx, name, location)
+}
 
-	tx, err := db.Begin()
+func (receiver *Bank) AddAtm(ctx context.Context, name, location string) (err error) {
+	tx, err := receiver.store.Begin(ctx, TxOptions{})
 	if err != nil {
-		return dbError(err)
+		return err
 	}
 
 	defer func() {
@@ -280,292 +455,288 @@ func AddAtm(name, location string, db *sql.DB) (err error) {
 		err = tx.Commit()
 	}()
 
-	_, err = tx.Exec(
-		queries.AddAtmSQL,
-		sql.Named("name", name),
-		sql.Named("location", location),
-	)
+	exists, err := tx.ATMExists(ctx, location)
 	if err != nil {
 		return err
 	}
+	if exists {
+		return ErrATMExist
+	}
 
-	return nil
+	return tx.AddATM(ctx, ATM{Name: name, Location: location})
 }
 
-func Login(login, password string, db *sql.DB) (phoneNumber int64, err error) {
-	var dbLogin, dbPassword, dbStatus string
+var hideUnknownLogins bool
 
-	err = db.QueryRow(
-		queries.LoginSQL,
-		login).Scan(&dbLogin, &dbPassword, &phoneNumber, &dbStatus)
+// SetHideUnknownLogins makes Authenticate report unknown logins as
+// ErrInvalidCredentials so callers can't tell which logins exist.
+func SetHideUnknownLogins(hide bool) {
+	settingsMu.Lock()
+	defer settingsMu.Unlock()
+	hideUnknownLogins = hide
+}
 
-	if err != nil {
-		if errors.Is(err, sql.ErrNoRows) {
-			return -1, nil
-		}
+// Authenticate checks the credentials and returns the client they belong to.
+// LastLoginAt holds the previous successful login and is zero on the first one.
+func Authenticate(login, password string, db *sql.DB) (result AuthResult, err error) {
+	return AuthenticateContext(context.Background(), login, password, db)
+}
 
-		return -1, queryError(queries.LoginSQL, err)
-	}
+func AuthenticateContext(ctx context.Context, login, password string, db *sql.DB) (result AuthResult, err error) {
+	return sqliteBank(db).Authenticate(ctx, login, password)
+}
 
-	if dbPassword != password {
-		return -1, ErrInvalidPass
+func (receiver *Bank) Authenticate(ctx context.Context, login, password string) (result AuthResult, err error) {
+	client, attempts, err := receiver.reserveLogin(ctx, login)
+	if errors.Is(err, ErrLoginNotFound) {
+		if receiver.hideUnknownLogins {
+			_, _ = receiver.hasher.Hash(password)
+			return AuthResult{}, ErrInvalidCredentials
+		}
+		return AuthResult{}, ErrLoginNotFound
 	}
-
-	if dbStatus == Locked {
-		return -1, ErrClientIsLocked
+	if err != nil {
+		return AuthResult{}, err
 	}
 
-	return phoneNumber, nil
-}
-
-func GetListOfClientAccounts(login string, db *sql.DB) (accounts []Account, err error) {
-	var clientId int64
-	err = db.QueryRow(
-		queries.GetClientIdByLoginSQL,
-		login,
-	).Scan(&clientId)
-	if err != nil {
-		return nil, queryError(queries.GetClientIdByLoginSQL, err)
+	result = AuthResult{
+		ClientId:    client.Id,
+		PhoneNumber: client.PhoneNumber,
+		Status:      client.Status,
+		LastLoginAt: attempts.LastLoginAt,
 	}
 
-	rows, err := db.Query(queries.GetClientAccountsSQL, clientId)
+	ok, rehash, err := receiver.verifyPassword(client.Password, password)
 	if err != nil {
-		return nil, queryError(queries.GetClientAccountsSQL, err)
+		_ = receiver.releaseLogin(ctx, result.ClientId)
+		return AuthResult{}, err
 	}
-	defer func() {
-		if innerErr := rows.Close(); innerErr != nil {
-			accounts, err = nil, dbError(innerErr)
+
+	if !ok {
+		err = receiver.recordFailedLogin(ctx, result.ClientId)
+		if err != nil {
+			return AuthResult{}, err
 		}
-	}()
+		return AuthResult{}, ErrInvalidCredentials
+	}
 
-	for rows.Next() {
-		account := Account{}
-		err = rows.Scan(&account.Id, &account.Balance)
+	var passwordHash string
+	if rehash {
+		passwordHash, err = receiver.hasher.Hash(password)
 		if err != nil {
-			return nil, dbError(err)
+			_ = receiver.releaseLogin(ctx, result.ClientId)
+			return AuthResult{}, err
 		}
-		account.Balance /= 100.0
-		accounts = append(accounts, account)
 	}
-	if rows.Err() != nil {
-		return nil, dbError(rows.Err())
+
+	err = receiver.recordSuccessfulLogin(ctx, client, passwordHash)
+	if err != nil {
+		return AuthResult{}, err
 	}
 
-	return accounts, nil
+	return result, nil
 }
 
-func GetJournalListFormatted(login string, limit, offset int64, db *sql.DB) (journals []Journal, err error) {
-	var clientId int64
-	err = db.QueryRow(
-		queries.GetClientIdByLoginSQL,
-		login,
-	).Scan(&clientId)
-	if err != nil {
-		return nil, queryError(queries.GetClientIdByLoginSQL, err)
-	}
+// Login is kept for callers of the old API: an unknown login yields -1 with
+// no error and wrong credentials yield ErrInvalidPass.
+func Login(login, password string, db *sql.DB) (phoneNumber int64, err error) {
+	return LoginContext(context.Background(), login, password, db)
+}
 
-	rows, err := db.Query(queries.GetJournalListFormattedSQL, clientId, limit, offset)
-	if err != nil {
-		return nil, queryError(queries.GetJournalListFormattedSQL, err)
-	}
-	defer func() {
-		if innerErr := rows.Close(); innerErr != nil {
-			journals, err = nil, dbError(innerErr)
-		}
-	}()
+func LoginContext(ctx context.Context, login, password string, db *sql.DB) (phoneNumber int64, err error) {
+	return sqliteBank(db).Login(ctx, login, password)
+}
 
-	for rows.Next() {
-		journal := Journal{}
-		err = rows.Scan(&journal.Id, &journal.Date, &journal.Type, &journal.TransferredTo, &journal.Amount)
-		if err != nil {
-			return nil, dbError(err)
-		}
-		journal.Amount /= 100.0
-		journals = append(journals, journal)
+func (receiver *Bank) Login(ctx context.Context, login, password string) (phoneNumber int64, err error) {
+	result, err := receiver.Authenticate(ctx, login, password)
+	if errors.Is(err, ErrLoginNotFound) {
+		return -1, nil
+	}
+	if errors.Is(err, ErrInvalidCredentials) {
+		return -1, ErrInvalidPass
 	}
-	if rows.Err() != nil {
-		return nil, dbError(rows.Err())
+	if err != nil {
+		return -1, err
 	}
 
-	return journals, nil
+	return result.PhoneNumber, nil
 }
 
-func GetListOfATMs(db *sql.DB) (atms []ATM, err error) {
-	rows, err := db.Query(queries.GetAllATMsSQL)
+func GetListOfClientAccounts(login string, db *sql.DB) (accounts []Account, err error) {
+	return GetListOfClientAccountsContext(context.Background(), login, db)
+}
+
+func GetListOfClientAccountsContext(ctx context.Context, login string, db *sql.DB) (accounts []Account, err error) {
+	return sqliteBank(db).GetListOfClientAccounts(ctx, login)
+}
+
+func (receiver *Bank) GetListOfClientAccounts(ctx context.Context, login string) (accounts []Account, err error) {
+	tx, err := receiver.store.Begin(ctx, TxOptions{ReadOnly: true})
 	if err != nil {
-		return nil, queryError(queries.GetAllATMsSQL, err)
+		return nil, err
 	}
+
 	defer func() {
-		if innerErr := rows.Close(); innerErr != nil {
-			atms, err = nil, dbError(innerErr)
+		if err != nil {
+			_ = tx.Rollback()
+			return
 		}
+		err = tx.Commit()
 	}()
 
-	for rows.Next() {
-		atm := ATM{}
-		err = rows.Scan(&atm.Id, &atm.Name, &atm.Location)
-		if err != nil {
-			return nil, dbError(err)
-		}
-		atms = append(atms, atm)
-	}
-	if rows.Err() != nil {
-		return nil, dbError(rows.Err())
+	client, err := tx.GetClientByLogin(ctx, login)
+	if err != nil {
+		return nil, err
 	}
 
-	return atms, nil
+	return tx.ListClientAccounts(ctx, client.Id)
 }
 
-func SearchClientByName(name string, db *sql.DB) (clients []Client, err error) {
-	name = "%" + name + "%"
-	rows, err := db.Query(queries.SearchClientByName, name)
+func GetJournalListFormatted(login string, limit, offset int64, db *sql.DB) (journals []Journal, err error) {
+	return GetJournalListFormattedContext(context.Background(), login, limit, offset, db)
+}
+
+func GetJournalListFormattedContext(ctx context.Context, login string, limit, offset int64, db *sql.DB) (journals []Journal, err error) {
+	return sqliteBank(db).GetJournalList(ctx, NewJournalQuery(login).Page(limit, offset))
+}
+
+func GetListOfATMs(db *sql.DB) (atms []ATM, err error) {
+	return GetListOfATMsContext(context.Background(), db)
+}
+
+func GetListOfATMsContext(ctx context.Context, db *sql.DB) (atms []ATM, err error) {
+	return sqliteBank(db).GetListOfATMs(ctx)
+}
+
+func (receiver *Bank) GetListOfATMs(ctx context.Context) (atms []ATM, err error) {
+	tx, err := receiver.store.Begin(ctx, TxOptions{ReadOnly: true})
 	if err != nil {
-		return nil, queryError(queries.GetAllATMsSQL, err)
+		return nil, err
 	}
+
 	defer func() {
-		if innerErr := rows.Close(); innerErr != nil {
-			clients, err = nil, dbError(innerErr)
+		if err != nil {
+			_ = tx.Rollback()
+			return
 		}
+		err = tx.Commit()
 	}()
 
-	for rows.Next() {
-		client := Client{}
-		err = rows.Scan(&client.Id, &client.Name, &client.Login, &client.Password, &client.PhoneNumber, &client.Status)
-		if err != nil {
-			return nil, dbError(err)
-		}
-		clients = append(clients, client)
-	}
-	if rows.Err() != nil {
-		return nil, dbError(rows.Err())
-	}
+	return tx.ListATMs(ctx)
+}
 
-	return clients, nil
+func SearchClientByName(name string, db *sql.DB) (clients []Client, err error) {
+	return SearchClientByNameContext(context.Background(), name, db)
 }
 
-func SearchClientByPhoneNumber(phoneNumber int64, db *sql.DB) (clients []Client, err error) {
-	phoneNum := "%" + strconv.Itoa(int(phoneNumber)) + "%"
-	rows, err := db.Query(queries.SearchClientByPhoneNumber, phoneNum)
+func SearchClientByNameContext(ctx context.Context, name string, db *sql.DB) (clients []Client, err error) {
+	return sqliteBank(db).SearchClientByName(ctx, name)
+}
+
+func (receiver *Bank) SearchClientByName(ctx context.Context, name string) (clients []Client, err error) {
+	tx, err := receiver.store.Begin(ctx, TxOptions{ReadOnly: true})
 	if err != nil {
-		return nil, queryError(queries.GetAllATMsSQL, err)
+		return nil, err
 	}
+
 	defer func() {
-		if innerErr := rows.Close(); innerErr != nil {
-			clients, err = nil, dbError(innerErr)
+		if err != nil {
+			_ = tx.Rollback()
+			return
 		}
+		err = tx.Commit()
 	}()
 
-	for rows.Next() {
-		client := Client{}
-		err = rows.Scan(&client.Id, &client.Name, &client.Login, &client.Password, &client.PhoneNumber, &client.Status)
-		if err != nil {
-			return nil, dbError(err)
-		}
-		clients = append(clients, client)
-	}
-	if rows.Err() != nil {
-		return nil, dbError(rows.Err())
-	}
+	return tx.SearchClientsByName(ctx, name)
+}
 
-	return clients, nil
+func SearchClientByPhoneNumber(phoneNumber int64, db *sql.DB) (clients []Client, err error) {
+	return SearchClientByPhoneNumberContext(context.Background(), phoneNumber, db)
 }
 
-func GetListOfClients(db *sql.DB) (clients []Client, err error) {
-	rows, err := db.Query(queries.GetListOfClientsSQL)
+func SearchClientByPhoneNumberContext(ctx context.Context, phoneNumber int64, db *sql.DB) (clients []Client, err error) {
+	return sqliteBank(db).SearchClientByPhoneNumber(ctx, phoneNumber)
+}
+
+func (receiver *Bank) SearchClientByPhoneNumber(ctx context.Context, phoneNumber int64) (clients []Client, err error) {
+	tx, err := receiver.store.Begin(ctx, TxOptions{ReadOnly: true})
 	if err != nil {
-		return nil, queryError(queries.GetListOfClientsSQL, err)
+		return nil, err
 	}
+
 	defer func() {
-		if innerErr := rows.Close(); innerErr != nil {
-			clients, err = nil, dbError(innerErr)
+		if err != nil {
+			_ = tx.Rollback()
+			return
 		}
+		err = tx.Commit()
 	}()
 
-	for rows.Next() {
-		client := Client{}
-		err = rows.Scan(&client.Id, &client.Name, &client.Login, &client.Password, &client.PhoneNumber, &client.Status)
-		if err != nil {
-			return nil, dbError(err)
-		}
-		clients = append(clients, client)
-	}
-	if rows.Err() != nil {
-		return nil, dbError(rows.Err())
-	}
+	return tx.SearchClientsByPhoneNumber(ctx, strconv.FormatInt(phoneNumber, 10))
+}
+
+func GetListOfClients(db *sql.DB) (clients []Client, err error) {
+	return GetListOfClientsContext(context.Background(), db)
+}
 
-	return clients, nil
+func GetListOfClientsContext(ctx context.Context, db *sql.DB) (clients []Client, err error) {
+	return sqliteBank(db).GetListOfClients(ctx)
 }
 
-func GetListOfClientsFormatted(limit, offset int64, db *sql.DB) (clients []Client, err error) {
-	rows, err := db.Query(queries.GetListOfClientsFormattedSQL, limit, offset)
+func (receiver *Bank) GetListOfClients(ctx context.Context) (clients []Client, err error) {
+	tx, err := receiver.store.Begin(ctx, TxOptions{ReadOnly: true})
 	if err != nil {
-		return nil, queryError(queries.GetListOfClientsFormattedSQL, err)
+		return nil, err
 	}
+
 	defer func() {
-		if innerErr := rows.Close(); innerErr != nil {
-			clients, err = nil, dbError(innerErr)
+		if err != nil {
+			_ = tx.Rollback()
+			return
 		}
+		err = tx.Commit()
 	}()
 
-	for rows.Next() {
-		client := Client{}
-		err = rows.Scan(&client.Id, &client.Name, &client.Login, &client.Password, &client.PhoneNumber, &client.Status)
-		if err != nil {
-			return nil, dbError(err)
-		}
-		clients = append(clients, client)
-	}
-	if rows.Err() != nil {
-		return nil, dbError(rows.Err())
-	}
+	return tx.ListClients(ctx)
+}
+
+func GetListOfClientsFormatted(limit, offset int64, db *sql.DB) (clients []Client, err error) {
+	return GetListOfClientsFormattedContext(context.Background(), limit, offset, db)
+}
 
-	return clients, nil
+func GetListOfClientsFormattedContext(ctx context.Context, limit, offset int64, db *sql.DB) (clients []Client, err error) {
+	return sqliteBank(db).GetListOfClientsFormatted(ctx, limit, offset)
 }
 
-func GetListOfAccountsWithClients(db *sql.DB) (accountsWithClientIds []AccountWithClientId, err error) {
-	rows, err := db.Query(queries.GetListOfAccountsSQL)
+func (receiver *Bank) GetListOfClientsFormatted(ctx context.Context, limit, offset int64) (clients []Client, err error) {
+	tx, err := receiver.store.Begin(ctx, TxOptions{ReadOnly: true})
 	if err != nil {
-		return nil, queryError(queries.GetListOfAccountsSQL, err)
+		return nil, err
 	}
+
 	defer func() {
-		if innerErr := rows.Close(); innerErr != nil {
-			accountsWithClientIds, err = nil, dbError(innerErr)
+		if err != nil {
+			_ = tx.Rollback()
+			return
 		}
+		err = tx.Commit()
 	}()
 
-	for rows.Next() {
-		accountWithClientId := AccountWithClientId{}
-		err = rows.Scan(&accountWithClientId.Id, &accountWithClientId.ClientId, &accountWithClientId.Balance)
-		if err != nil {
-			return nil, dbError(err)
-		}
-		accountWithClientId.Balance /= 100.0
-		accountsWithClientIds = append(accountsWithClientIds, accountWithClientId)
-	}
-	if rows.Err() != nil {
-		return nil, dbError(rows.Err())
-	}
+	return tx.ListClientsByName(ctx, limit, offset)
+}
 
-	return accountsWithClientIds, nil
+func GetListOfAccountsWithClients(db *sql.DB) (accountsWithClientIds []AccountWithClientId, err error) {
+	return GetListOfAccountsWithClientsContext(context.Background(), db)
 }
 
-func PayForService(nameOfService string, accountId int64, login string, amount float64, db *sql.DB) (err error) {
-	err = checkServiceExist(nameOfService, db)
-	if !(errors.Is(err, ErrServiceExist)) {
-		return ErrServiceNotExist
-	}
-	tx, err := db.Begin()
-	if err != nil {
-		return dbError(err)
-	}
+func GetListOfAccountsWithClientsContext(ctx context.Context, db *sql.DB) (accountsWithClientIds []AccountWithClientId, err error) {
+	return sqliteBank(db).GetListOfAccountsWithClients(ctx)
+}
 
-	var clientId int64
-	err = db.QueryRow(
-		queries.GetClientIdByLoginSQL,
-		login,
-	).Scan(&clientId)
+func (receiver *Bank) GetListOfAccountsWithClients(ctx context.Context) (accountsWithClientIds []AccountWithClientId, err error) {
+	tx, err := receiver.store.Begin(ctx, TxOptions{ReadOnly: true})
 	if err != nil {
-		return queryError(queries.GetClientIdByLoginSQL, err)
+		return nil, err
 	}
 
 	defer func() {
@@ -576,210 +747,219 @@ func PayForService(nameOfService string, accountId int64, login string, amount f
 		err = tx.Commit()
 	}()
 
-	amount *= 100.0
-	_, err = tx.Exec(
-		queries.UpdateClientBalanceSQL,
-		sql.Named("id", accountId),
-		sql.Named("amount", -1*amount),
-	)
-	if err != nil {
-		return err
-	}
+	return tx.ListAccounts(ctx)
+}
+
+func PayForService(nameOfService string, accountId int64, session Session, amount Money, db *sql.DB, options ...MoveOption) (err error) {
+	return PayForServiceContext(context.Background(), nameOfService, accountId, session, amount, db, options...)
+}
+
+func PayForServiceContext(ctx context.Context, nameOfService string, accountId int64, session Session, amount Money, db *sql.DB, options ...MoveOption) (err error) {
+	return sqliteBank(db).PayForService(ctx, nameOfService, accountId, session, amount, options...)
+}
+
+// PayForService pays for a service without a payer reference, see
+// PayForServiceWithReference.
+func (receiver *Bank) PayForService(ctx context.Context, nameOfService string, accountId int64, session Session, amount Money, options ...MoveOption) (err error) {
+	return receiver.PayForServiceWithReference(ctx, nameOfService, "", accountId, session, amount, options...)
+}
+
+func PayForServiceWithReference(nameOfService, reference string, accountId int64, session Session, amount Money, db *sql.DB, options ...MoveOption) (err error) {
+	return PayForServiceWithReferenceContext(context.Background(), nameOfService, reference, accountId, session, amount, db, options...)
+}
 
-	dateAndTime := time.Now()
+func PayForServiceWithReferenceContext(ctx context.Context, nameOfService, reference string, accountId int64, session Session, amount Money, db *sql.DB, options ...MoveOption) (err error) {
+	return sqliteBank(db).PayForServiceWithReference(ctx, nameOfService, reference, accountId, session, amount, options...)
+}
 
-	_, err = tx.Exec(
-		queries.AddToJournalSQL,
-		sql.Named("date", dateAndTime.Format("01-02-2006 15:04:05")),
-		sql.Named("client_id", clientId),
-		sql.Named("type", Service),
-		sql.Named("transferred_to", nameOfService),
-		sql.Named("amount", amount),
-	)
+// PayForServiceWithReference pays amount for the service on behalf of the
+// payer identified by reference, e.g. a phone or contract number. The fee of
+// the service is withdrawn from the account on top of amount.
+func (receiver *Bank) PayForServiceWithReference(ctx context.Context, nameOfService, reference string, accountId int64, session Session, amount Money, options ...MoveOption) (err error) {
+	err = receiver.checkAmount(amount)
 	if err != nil {
 		return err
 	}
 
-	return nil
+	request := fmt.Sprintf("%s %s from account %d: %v", Service, nameOfService, accountId, amount)
+	if reference != "" {
+		request += fmt.Sprintf(" for %q", reference)
+	}
+	_, err = receiver.moveMoney(ctx, session, request, options, func(tx Tx) (journalId int64, result Money, err error) {
+		journalId, err = receiver.payForService(ctx, nameOfService, reference, accountId, session, amount, tx)
+		return journalId, amount, err
+	})
+	return err
 }
 
-func TransferToByAccountId(targetAccountId int64, login string, accountId int64, amount float64, db *sql.DB) (err error) {
-	var targetClientId int64
-	err = db.QueryRow(
-		queries.GetClientIdByAccountSQL,
-		targetAccountId,
-	).Scan(&targetClientId)
+func (receiver *Bank) payForService(ctx context.Context, nameOfService, reference string, accountId int64, session Session, amount Money, tx Tx) (journalId int64, err error) {
+	service, err := tx.GetCatalogService(ctx, nameOfService)
 	if err != nil {
-		return queryError(queries.GetClientIdByAccountSQL, err)
+		return 0, err
 	}
 
-	var targetClientStatus string
-	err = db.QueryRow(
-		queries.GetClientStatusSQL,
-		targetClientId,
-	).Scan(&targetClientStatus)
+	fee, err := service.checkPayment(reference, amount)
 	if err != nil {
-		return queryError(queries.GetClientStatusSQL, err)
+		return 0, err
 	}
 
-	if targetClientStatus == Locked {
-		return ErrClientIsLocked
+	providerId, err := tx.GetServiceProvider(ctx, nameOfService)
+	if err != nil {
+		return 0, err
 	}
 
-	tx, err := db.Begin()
+	total := NewMoney(amount.Amount+fee.Amount, amount.Currency)
+	sender, err := receiver.withdraw(ctx, session, accountId, total, tx)
 	if err != nil {
-		return dbError(err)
+		return 0, err
 	}
 
-	defer func() {
+	credit := systemEntry(systemServices, amount)
+	if providerId != 0 {
+		credit, err = creditProvider(ctx, providerId, amount, tx)
 		if err != nil {
-			_ = tx.Rollback()
-			return
+			return 0, err
 		}
-		err = tx.Commit()
-	}()
-
-	var clientId int64
-	err = db.QueryRow(
-		queries.GetClientIdByLoginSQL,
-		login,
-	).Scan(&clientId)
-	if err != nil {
-		return queryError(queries.GetClientIdByLoginSQL, err)
 	}
 
-	amount *= 100.0
-
-	_, err = tx.Exec(
-		queries.UpdateClientBalanceSQL,
-		sql.Named("id", accountId),
-		sql.Named("amount", -1*amount),
-	)
+	journalId, err = receiver.addToJournal(ctx, sender.clientId, Journal{
+		Type:          Service,
+		Direction:     Outgoing,
+		AccountId:     accountId,
+		Counterparty:  nameOfService,
+		TransferredTo: nameOfService,
+		Amount:        amount,
+		Reference:     reference,
+		Fee:           optionalMoney(fee.Amount, fee.Currency),
+	}, tx)
 	if err != nil {
-		return err
+		return 0, err
 	}
 
-	_, err = tx.Exec(
-		queries.UpdateClientBalanceSQL,
-		sql.Named("id", targetAccountId),
-		sql.Named("amount", amount),
-	)
-	if err != nil {
-		return err
-	}
+	return journalId, receiver.post(ctx, Service, journalId, []LedgerEntry{
+		accountEntry(accountId, negate(total)),
+		credit,
+		systemEntry(systemFees, fee),
+	}, tx)
+}
 
-	dateAndTime := time.Now()
+func TransferToByAccountId(targetAccountId int64, session Session, accountId int64, amount Money, db *sql.DB, options ...MoveOption) (err error) {
+	return TransferToByAccountIdContext(context.Background(), targetAccountId, session, accountId, amount, db, options...)
+}
+
+func TransferToByAccountIdContext(ctx context.Context, targetAccountId int64, session Session, accountId int64, amount Money, db *sql.DB, options ...MoveOption) (err error) {
+	return sqliteBank(db).TransferToByAccountId(ctx, targetAccountId, session, accountId, amount, options...)
+}
 
-	_, err = tx.Exec(
-		queries.AddToJournalSQL,
-		sql.Named("date", dateAndTime.Format("01-02-2006 15:04:05")),
-		sql.Named("client_id", clientId),
-		sql.Named("type", Transfer),
-		sql.Named("transferred_to", targetAccountId),
-		sql.Named("amount", amount),
-	)
+func (receiver *Bank) TransferToByAccountId(ctx context.Context, targetAccountId int64, session Session, accountId int64, amount Money, options ...MoveOption) (err error) {
+	err = receiver.checkAmount(amount)
 	if err != nil {
 		return err
 	}
 
-	return nil
+	request := fmt.Sprintf("%s to account %d from account %d: %v", Transfer, targetAccountId, accountId, amount)
+	_, err = receiver.moveMoney(ctx, session, request, options, func(tx Tx) (journalId int64, result Money, err error) {
+		journalId, err = receiver.transferToByAccountId(ctx, targetAccountId, session, accountId, amount, tx)
+		return journalId, amount, err
+	})
+	return err
 }
 
-func TransferToByPhoneNumber(phoneNumber int64, login string, accountId int64, amount float64, db *sql.DB) (err error) {
-	var targetClientStatus string
-	err = db.QueryRow(
-		queries.GetClientStatusByPhoneNumberSQL,
-		phoneNumber,
-	).Scan(&targetClientStatus)
+func (receiver *Bank) transferToByAccountId(ctx context.Context, targetAccountId int64, session Session, accountId int64, amount Money, tx Tx) (journalId int64, err error) {
+	sender, err := receiver.withdraw(ctx, session, accountId, amount, tx)
 	if err != nil {
-		return queryError(queries.GetClientStatusByPhoneNumberSQL, err)
-	}
-
-	if targetClientStatus == Locked {
-		return ErrClientIsLocked
+		return 0, err
 	}
 
-	var targetClientId int64
-	err = db.QueryRow(
-		queries.GetClientIdByPhoneNumberSQL,
-		phoneNumber,
-	).Scan(&targetClientId)
+	recipient, err := deposit(ctx, targetAccountId, amount, tx)
 	if err != nil {
-		return queryError(queries.GetClientIdByPhoneNumberSQL, err)
+		return 0, err
 	}
 
-	var targetAccountId int64
-	err = db.QueryRow(
-		queries.GetClientAccountIdSQL,
-		targetClientId,
-	).Scan(&targetAccountId)
+	journalId, err = receiver.addTransferToJournal(ctx, Transfer, strconv.FormatInt(targetAccountId, 10), sender, recipient, amount, amount, 0, tx)
 	if err != nil {
-		return queryError(queries.GetClientAccountIdSQL, err)
+		return 0, err
 	}
 
-	tx, err := db.Begin()
+	return journalId, receiver.post(ctx, Transfer, journalId, []LedgerEntry{
+		accountEntry(accountId, negate(amount)),
+		accountEntry(targetAccountId, amount),
+	}, tx)
+}
+
+func TransferToByPhoneNumber(phoneNumber int64, session Session, accountId int64, amount Money, db *sql.DB, options ...MoveOption) (err error) {
+	return TransferToByPhoneNumberContext(context.Background(), phoneNumber, session, accountId, amount, db, options...)
+}
+
+func TransferToByPhoneNumberContext(ctx context.Context, phoneNumber int64, session Session, accountId int64, amount Money, db *sql.DB, options ...MoveOption) (err error) {
+	return sqliteBank(db).TransferToByPhoneNumber(ctx, phoneNumber, session, accountId, amount, options...)
+}
+
+func (receiver *Bank) TransferToByPhoneNumber(ctx context.Context, phoneNumber int64, session Session, accountId int64, amount Money, options ...MoveOption) (err error) {
+	err = receiver.checkAmount(amount)
 	if err != nil {
-		return dbError(err)
+		return err
 	}
 
-	defer func() {
-		if err != nil {
-			_ = tx.Rollback()
-			return
-		}
-		err = tx.Commit()
-	}()
+	request := fmt.Sprintf("%s to %d from account %d: %v", Transfer, phoneNumber, accountId, amount)
+	_, err = receiver.moveMoney(ctx, session, request, options, func(tx Tx) (journalId int64, result Money, err error) {
+		journalId, err = receiver.transferToByPhoneNumber(ctx, phoneNumber, session, accountId, amount, tx)
+		return journalId, amount, err
+	})
+	return err
+}
 
-	var clientId int64
-	err = db.QueryRow(
-		queries.GetClientIdByLoginSQL,
-		login,
-	).Scan(&clientId)
+func (receiver *Bank) transferToByPhoneNumber(ctx context.Context, phoneNumber int64, session Session, accountId int64, amount Money, tx Tx) (journalId int64, err error) {
+	target, err := tx.GetClientByPhoneNumber(ctx, phoneNumber)
 	if err != nil {
-		return queryError(queries.GetClientIdByLoginSQL, err)
+		return 0, err
 	}
 
-	amount *= 100.0
+	if target.Status == Locked {
+		return 0, ErrRecipientIsLocked
+	}
 
-	_, err = tx.Exec(
-		queries.UpdateClientBalanceSQL,
-		sql.Named("id", accountId),
-		sql.Named("amount", -1*amount),
-	)
+	targetAccountId, err := tx.FindClientAccount(ctx, target.Id, amount.Currency)
+	if errors.Is(err, ErrAccountNotExist) {
+		return 0, ErrRecipientHasNoAccount
+	}
 	if err != nil {
-		return err
+		return 0, err
 	}
 
-	_, err = tx.Exec(
-		queries.UpdateClientBalanceSQL,
-		sql.Named("id", targetAccountId),
-		sql.Named("amount", amount),
-	)
+	sender, err := receiver.withdraw(ctx, session, accountId, amount, tx)
 	if err != nil {
-		return err
+		return 0, err
 	}
 
-	dateAndTime := time.Now()
+	recipient, err := deposit(ctx, targetAccountId, amount, tx)
+	if err != nil {
+		return 0, err
+	}
 
-	_, err = tx.Exec(
-		queries.AddToJournalSQL,
-		sql.Named("date", dateAndTime.Format("01-02-2006 15:04:05")),
-		sql.Named("client_id", clientId),
-		sql.Named("type", Transfer),
-		sql.Named("transferred_to", phoneNumber),
-		sql.Named("amount", amount),
-	)
+	journalId, err = receiver.addTransferToJournal(ctx, Transfer, strconv.FormatInt(phoneNumber, 10), sender, recipient, amount, amount, 0, tx)
 	if err != nil {
-		return err
+		return 0, err
 	}
 
-	return nil
+	return journalId, receiver.post(ctx, Transfer, journalId, []LedgerEntry{
+		accountEntry(accountId, negate(amount)),
+		accountEntry(targetAccountId, amount),
+	}, tx)
 }
 
 func ImportListOfClients(clients []Client, db *sql.DB) (err error) {
-	tx, err := db.Begin()
+	return ImportListOfClientsContext(context.Background(), clients, db)
+}
+
+func ImportListOfClientsContext(ctx context.Context, clients []Client, db *sql.DB) (err error) {
+	return sqliteBank(db).ImportListOfClients(ctx, clients)
+}
+
+func (receiver *Bank) ImportListOfClients(ctx context.Context, clients []Client) (err error) {
+	tx, err := receiver.store.Begin(ctx, TxOptions{})
 	if err != nil {
-		return dbError(err)
+		return err
 	}
 
 	defer func() {
@@ -790,16 +970,17 @@ func ImportListOfClients(clients []Client, db *sql.DB) (err error) {
 		err = tx.Commit()
 	}()
 
+	return receiver.importClients(ctx, clients, tx)
+}
+
+func (receiver *Bank) importClients(ctx context.Context, clients []Client, tx Tx) (err error) {
 	for _, client := range clients {
-		_, err = tx.Exec(
-			queries.UpdateListOfClientsSQL,
-			sql.Named("id", client.Id),
-			sql.Named("name", client.Name),
-			sql.Named("login", client.Login),
-			sql.Named("password", client.Password),
-			sql.Named("phone_number", client.PhoneNumber),
-			sql.Named("status", client.Status),
-		)
+		client.Password, err = receiver.ensurePasswordHash(client.Password)
+		if err != nil {
+			return err
+		}
+
+		err = tx.SaveClient(ctx, client)
 		if err != nil {
 			return err
 		}
@@ -809,9 +990,17 @@ func ImportListOfClients(clients []Client, db *sql.DB) (err error) {
 }
 
 func ImportListOfAccounts(accountWithClientIds []AccountWithClientId, db *sql.DB) (err error) {
-	tx, err := db.Begin()
+	return ImportListOfAccountsContext(context.Background(), accountWithClientIds, db)
+}
+
+func ImportListOfAccountsContext(ctx context.Context, accountWithClientIds []AccountWithClientId, db *sql.DB) (err error) {
+	return sqliteBank(db).ImportListOfAccounts(ctx, accountWithClientIds)
+}
+
+func (receiver *Bank) ImportListOfAccounts(ctx context.Context, accountWithClientIds []AccountWithClientId) (err error) {
+	tx, err := receiver.store.Begin(ctx, TxOptions{})
 	if err != nil {
-		return dbError(err)
+		return err
 	}
 
 	defer func() {
@@ -822,13 +1011,21 @@ func ImportListOfAccounts(accountWithClientIds []AccountWithClientId, db *sql.DB
 		err = tx.Commit()
 	}()
 
+	return receiver.importAccounts(ctx, accountWithClientIds, tx)
+}
+
+func (receiver *Bank) importAccounts(ctx context.Context, accountWithClientIds []AccountWithClientId, tx Tx) (err error) {
 	for _, accountWithClientId := range accountWithClientIds {
-		_, err = tx.Exec(
-			queries.UpdateListOfAccountsWithClientIdsSQL,
-			sql.Named("id", accountWithClientId.Id),
-			sql.Named("client_id", accountWithClientId.ClientId),
-			sql.Named("balance", accountWithClientId.Balance),
-		)
+		if !IsSupportedCurrency(accountWithClientId.Balance.Currency) {
+			return ErrUnsupportedCurrency
+		}
+
+		err = tx.SaveAccount(ctx, accountWithClientId)
+		if err != nil {
+			return err
+		}
+
+		err = receiver.postAdjustment(ctx, systemImport, systemImport, accountWithClientId.Id, accountWithClientId.Balance, tx)
 		if err != nil {
 			return err
 		}
@@ -838,9 +1035,17 @@ func ImportListOfAccounts(accountWithClientIds []AccountWithClientId, db *sql.DB
 }
 
 func ImportListOfATMs(atms []ATM, db *sql.DB) (err error) {
-	tx, err := db.Begin()
+	return ImportListOfATMsContext(context.Background(), atms, db)
+}
+
+func ImportListOfATMsContext(ctx context.Context, atms []ATM, db *sql.DB) (err error) {
+	return sqliteBank(db).ImportListOfATMs(ctx, atms)
+}
+
+func (receiver *Bank) ImportListOfATMs(ctx context.Context, atms []ATM) (err error) {
+	tx, err := receiver.store.Begin(ctx, TxOptions{})
 	if err != nil {
-		return dbError(err)
+		return err
 	}
 
 	defer func() {
@@ -851,13 +1056,12 @@ func ImportListOfATMs(atms []ATM, db *sql.DB) (err error) {
 		err = tx.Commit()
 	}()
 
+	return importATMs(ctx, atms, tx)
+}
+
+func importATMs(ctx context.Context, atms []ATM, tx Tx) (err error) {
 	for _, atm := range atms {
-		_, err = tx.Exec(
-			queries.UpdateListOfATMsSQL,
-			sql.Named("id", atm.Id),
-			sql.Named("name", atm.Name),
-			sql.Named("location", atm.Location),
-		)
+		err = tx.SaveATM(ctx, atm)
 		if err != nil {
 			return err
 		}
@@ -867,17 +1071,36 @@ func ImportListOfATMs(atms []ATM, db *sql.DB) (err error) {
 }
 
 func ChangeClientStatus(phoneNumber int64, status string, db *sql.DB) (err error) {
-	err = checkClientExist("", phoneNumber, db)
-	if !errors.Is(err, ErrPhoneNumberExist) {
-		return ErrPhoneNumberNotExist
+	return ChangeClientStatusContext(context.Background(), phoneNumber, status, db)
+}
+
+func ChangeClientStatusContext(ctx context.Context, phoneNumber int64, status string, db *sql.DB) (err error) {
+	return sqliteBank(db).ChangeClientStatus(ctx, phoneNumber, status)
+}
+
+func (receiver *Bank) ChangeClientStatus(ctx context.Context, phoneNumber int64, status string) (err error) {
+	tx, err := receiver.store.Begin(ctx, TxOptions{})
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+			return
+		}
+		err = tx.Commit()
+	}()
+
+	client, err := tx.GetClientByPhoneNumber(ctx, phoneNumber)
+	if err != nil {
+		return err
 	}
 
-	_, err = db.Exec(queries.ChangeClientStatusSQL,
-		sql.Named("status", status),
-		sql.Named("phone_number", phoneNumber),
-	)
+	err = tx.SetClientStatus(ctx, client.Id, status)
 	if err != nil {
-		return dbError(err)
+		return err
 	}
-	return nil
-}
\ No newline at end of file
+
+	return tx.ResetLoginAttempts(ctx, client.Id)
+}
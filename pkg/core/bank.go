@@ -0,0 +1,182 @@
+package core
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"sync"
+	"time"
+)
+
+var ErrLimitExceeded = errors.New("limit exceeded")
+
+// Bank runs the core operations over a Store. The package level functions
+// taking a *sql.DB are shorthands for a Bank over NewSQLiteStore configured
+// with SetClock, SetPasswordHasher and the other package setters.
+type Bank struct {
+	store             Store
+	now               func() time.Time
+	hasher            PasswordHasher
+	hashers           map[string]PasswordHasher
+	logger            *log.Logger
+	lockoutPolicy     LockoutPolicy
+	sessionTTL        time.Duration
+	hideUnknownLogins bool
+	limits            Limits
+	retryPolicy       RetryPolicy
+	idempotencyWindow time.Duration
+}
+
+// Limits bound single operations. Zero values are not enforced.
+type Limits struct {
+	// MaxAmount caps one payment, transfer or conversion, in minor units
+	// per currency.
+	MaxAmount map[string]int64
+	// MaxPageSize caps the limit of a page request.
+	MaxPageSize int64
+}
+
+type Option func(bank *Bank)
+
+func WithClock(clock func() time.Time) Option {
+	return func(bank *Bank) {
+		bank.now = clock
+	}
+}
+
+func WithPasswordHasher(hasher PasswordHasher) Option {
+	return func(bank *Bank) {
+		bank.hasher = hasher
+	}
+}
+
+// WithPasswordVerifier lets the bank verify hashes of the hasher's scheme,
+// see RegisterPasswordHasher.
+func WithPasswordVerifier(hasher PasswordHasher) Option {
+	return func(bank *Bank) {
+		bank.hashers[hasher.Scheme()] = hasher
+	}
+}
+
+// WithLogger reports lockouts and other notable events, nothing is logged
+// by default or with a nil logger.
+func WithLogger(logger *log.Logger) Option {
+	return func(bank *Bank) {
+		if logger == nil {
+			logger = log.New(ioutil.Discard, "", 0)
+		}
+		bank.logger = logger
+	}
+}
+
+func WithLockoutPolicy(policy LockoutPolicy) Option {
+	return func(bank *Bank) {
+		bank.lockoutPolicy = policy
+	}
+}
+
+func WithSessionTTL(ttl time.Duration) Option {
+	return func(bank *Bank) {
+		bank.sessionTTL = ttl
+	}
+}
+
+// WithHideUnknownLogins makes Authenticate report unknown logins as
+// ErrInvalidCredentials, see SetHideUnknownLogins.
+func WithHideUnknownLogins(hide bool) Option {
+	return func(bank *Bank) {
+		bank.hideUnknownLogins = hide
+	}
+}
+
+func WithLimits(limits Limits) Option {
+	return func(bank *Bank) {
+		bank.limits = limits
+	}
+}
+
+// WithRetryPolicy sets how often transfers, payments and conversions are
+// retried after colliding with concurrent ones.
+func WithRetryPolicy(policy RetryPolicy) Option {
+	return func(bank *Bank) {
+		bank.retryPolicy = policy
+	}
+}
+
+// WithIdempotencyWindow sets how long idempotency keys are remembered, see
+// WithIdempotencyKey.
+func WithIdempotencyWindow(window time.Duration) Option {
+	return func(bank *Bank) {
+		bank.idempotencyWindow = window
+	}
+}
+
+func NewBank(store Store, options ...Option) *Bank {
+	bank := &Bank{
+		store:             store,
+		now:               time.Now,
+		hasher:            DefaultPasswordHasher,
+		hashers:           make(map[string]PasswordHasher),
+		logger:            log.New(ioutil.Discard, "", 0),
+		lockoutPolicy:     DefaultLockoutPolicy,
+		sessionTTL:        DefaultSessionTTL,
+		retryPolicy:       DefaultRetryPolicy,
+		idempotencyWindow: DefaultIdempotencyWindow,
+	}
+	settingsMu.RLock()
+	for scheme, hasher := range passwordHashers {
+		bank.hashers[scheme] = hasher
+	}
+	settingsMu.RUnlock()
+	for _, option := range options {
+		option(bank)
+	}
+	return bank
+}
+
+// settingsMu guards the settings of the package level functions, which the
+// Set functions may change while those functions run.
+var settingsMu sync.RWMutex
+
+// sqliteBank is the Bank behind the package level functions. It takes the
+// settings as they are when it's made, later changes don't affect it.
+func sqliteBank(db *sql.DB) *Bank {
+	settingsMu.RLock()
+	options := []Option{
+		WithClock(now),
+		WithPasswordHasher(passwordHasher),
+		WithLockoutPolicy(lockoutPolicy),
+		WithSessionTTL(sessionTTL),
+		WithHideUnknownLogins(hideUnknownLogins),
+		WithRetryPolicy(retryPolicy),
+		WithIdempotencyWindow(idempotencyWindow),
+	}
+	settingsMu.RUnlock()
+	return NewBank(NewSQLiteStore(db), options...)
+}
+
+// checkAmount validates amounts handed to the money-moving operations.
+func (receiver *Bank) checkAmount(amount Money) (err error) {
+	err = checkAmount(amount)
+	if err != nil {
+		return err
+	}
+
+	max, ok := receiver.limits.MaxAmount[amount.Currency]
+	if ok && amount.Amount > max {
+		return fmt.Errorf("%w: %v is above %v", ErrLimitExceeded, amount, NewMoney(max, amount.Currency))
+	}
+	return nil
+}
+
+func (receiver *Bank) checkPageRequest(request PageRequest) (err error) {
+	if request.Limit <= 0 {
+		return ErrInvalidLimit
+	}
+	if receiver.limits.MaxPageSize > 0 && request.Limit > receiver.limits.MaxPageSize {
+		return fmt.Errorf("%w: page of %d is above %d", ErrLimitExceeded, request.Limit, receiver.limits.MaxPageSize)
+	}
+	return nil
+}
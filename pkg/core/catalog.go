@@ -0,0 +1,199 @@
+package core
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"regexp"
+)
+
+var (
+	ErrInvalidCategory         = errors.New("unknown service category")
+	ErrInvalidReferencePattern = errors.New("invalid payer reference pattern")
+	ErrInvalidReference        = errors.New("payer reference doesn't match the service")
+)
+
+// Categories of the service catalog. Services added by AddService have none.
+const (
+	Mobile    = "mobile"
+	Utilities = "utilities"
+	Internet  = "internet"
+)
+
+// CatalogService is a service together with the terms of paying for it.
+// Zero MinAmount and MaxAmount leave the amount of a payment unbounded.
+// Every payment is charged FixedFee plus FeePercent percent of its amount,
+// rounded down, on top. Payments for a service with amounts set must be in
+// their currency. The whole payer reference must match ReferencePattern, an
+// empty pattern takes any reference.
+type CatalogService struct {
+	Name             string
+	Category         string
+	MinAmount        Money
+	MaxAmount        Money
+	FixedFee         Money
+	FeePercent       Rate
+	ReferencePattern string
+}
+
+// Currency returns the currency of the service's amounts, empty when none
+// is set.
+func (receiver CatalogService) Currency() string {
+	for _, amount := range []Money{receiver.MinAmount, receiver.MaxAmount, receiver.FixedFee} {
+		if amount.Amount != 0 {
+			return amount.Currency
+		}
+	}
+	return ""
+}
+
+// Fee returns the commission charged on top of a payment of amount.
+func (receiver CatalogService) Fee(amount Money) Money {
+	fee := scaleAmount(amount.Amount, int64(receiver.FeePercent), 0, 100*pow10(RateDigits), 0)
+	return NewMoney(fee+receiver.FixedFee.Amount, amount.Currency)
+}
+
+// optionalMoney returns amount in currency, or the zero Money for a zero
+// amount, for amounts stored without a currency of their own.
+func optionalMoney(amount int64, currency string) Money {
+	if amount == 0 {
+		return Money{}
+	}
+	return NewMoney(amount, currency)
+}
+
+func referencePattern(pattern string) (*regexp.Regexp, error) {
+	compiled, err := regexp.Compile("^(?:" + pattern + ")$")
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidReferencePattern, err)
+	}
+	return compiled, nil
+}
+
+func checkCatalogService(service CatalogService) (err error) {
+	switch service.Category {
+	case "", Mobile, Utilities, Internet:
+	default:
+		return ErrInvalidCategory
+	}
+
+	currency := service.Currency()
+	for _, amount := range []Money{service.MinAmount, service.MaxAmount, service.FixedFee} {
+		if amount.Amount == 0 {
+			continue
+		}
+		if amount.Amount < 0 {
+			return ErrInvalidAmount
+		}
+		if !IsSupportedCurrency(amount.Currency) {
+			return ErrUnsupportedCurrency
+		}
+		if amount.Currency != currency {
+			return ErrCurrencyMismatch
+		}
+	}
+
+	if service.MaxAmount.Amount != 0 && service.MinAmount.Amount > service.MaxAmount.Amount {
+		return ErrInvalidAmount
+	}
+
+	if service.FeePercent < 0 {
+		return ErrInvalidRate
+	}
+
+	_, err = referencePattern(service.ReferencePattern)
+	return err
+}
+
+// checkPayment checks a payment of amount with the payer reference against
+// the terms of the service and returns the fee it's charged.
+func (receiver CatalogService) checkPayment(reference string, amount Money) (fee Money, err error) {
+	currency := receiver.Currency()
+	if currency != "" && currency != amount.Currency {
+		return Money{}, ErrCurrencyMismatch
+	}
+
+	if amount.Amount < receiver.MinAmount.Amount {
+		return Money{}, fmt.Errorf("%w: %v is below %v", ErrLimitExceeded, amount, receiver.MinAmount)
+	}
+	if receiver.MaxAmount.Amount != 0 && amount.Amount > receiver.MaxAmount.Amount {
+		return Money{}, fmt.Errorf("%w: %v is above %v", ErrLimitExceeded, amount, receiver.MaxAmount)
+	}
+
+	pattern, err := referencePattern(receiver.ReferencePattern)
+	if err != nil {
+		return Money{}, err
+	}
+	if !pattern.MatchString(reference) {
+		return Money{}, ErrInvalidReference
+	}
+
+	return receiver.Fee(amount), nil
+}
+
+func AddCatalogService(service CatalogService, db *sql.DB) (err error) {
+	return AddCatalogServiceContext(context.Background(), service, db)
+}
+
+func AddCatalogServiceContext(ctx context.Context, service CatalogService, db *sql.DB) (err error) {
+	return sqliteBank(db).AddCatalogService(ctx, service)
+}
+
+// AddCatalogService adds a service with its category and payment terms.
+func (receiver *Bank) AddCatalogService(ctx context.Context, service CatalogService) (err error) {
+	err = checkCatalogService(service)
+	if err != nil {
+		return err
+	}
+
+	tx, err := receiver.store.Begin(ctx, TxOptions{})
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+			return
+		}
+		err = tx.Commit()
+	}()
+
+	exists, err := tx.ServiceExists(ctx, service.Name)
+	if err != nil {
+		return err
+	}
+	if exists {
+		return ErrServiceExist
+	}
+
+	return tx.AddCatalogService(ctx, service)
+}
+
+func GetServiceCatalog(category string, db *sql.DB) (services []CatalogService, err error) {
+	return GetServiceCatalogContext(context.Background(), category, db)
+}
+
+func GetServiceCatalogContext(ctx context.Context, category string, db *sql.DB) (services []CatalogService, err error) {
+	return sqliteBank(db).GetServiceCatalog(ctx, category)
+}
+
+// GetServiceCatalog lists the services of category, all of them when
+// category is empty.
+func (receiver *Bank) GetServiceCatalog(ctx context.Context, category string) (services []CatalogService, err error) {
+	tx, err := receiver.store.Begin(ctx, TxOptions{ReadOnly: true})
+	if err != nil {
+		return nil, err
+	}
+
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+			return
+		}
+		err = tx.Commit()
+	}()
+
+	return tx.ListCatalogServices(ctx, category)
+}
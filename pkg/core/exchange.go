@@ -0,0 +1,243 @@
+package core
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	ErrInvalidRate          = errors.New("invalid exchange rate")
+	ErrExchangeRateNotFound = errors.New("exchange rate not found")
+	ErrSameCurrency         = errors.New("accounts have the same currency")
+)
+
+// Rate is a fixed point exchange rate with RateDigits fractional digits: the
+// number of quote currency major units for one base currency major unit.
+type Rate int64
+
+const RateDigits = 6
+
+func ParseRate(value string) (rate Rate, err error) {
+	value = strings.TrimSpace(value)
+	whole, fraction := value, ""
+	if dot := strings.IndexByte(value, '.'); dot >= 0 {
+		whole, fraction = value[:dot], value[dot+1:]
+	}
+
+	fraction = strings.TrimRight(fraction, "0")
+	if whole == "" || !isDigits(whole) || !isDigits(fraction) || len(fraction) > RateDigits {
+		return 0, ErrInvalidRate
+	}
+	fraction += strings.Repeat("0", RateDigits-len(fraction))
+
+	parsed, err := strconv.ParseInt(whole+fraction, 10, 64)
+	if err != nil || parsed <= 0 {
+		return 0, ErrInvalidRate
+	}
+
+	return Rate(parsed), nil
+}
+
+func (receiver Rate) String() string {
+	scale := pow10(RateDigits)
+	return fmt.Sprintf("%d.%0*d", int64(receiver)/scale, RateDigits, int64(receiver)%scale)
+}
+
+// ExchangeRate holds the bank's rates for one currency pair. Buy is used
+// when a client sells Base to the bank, Sell when a client buys Base.
+type ExchangeRate struct {
+	Base        string
+	Quote       string
+	Buy         Rate
+	Sell        Rate
+	EffectiveAt time.Time
+}
+
+func LoadExchangeRates(rates []ExchangeRate, db *sql.DB) (err error) {
+	return LoadExchangeRatesContext(context.Background(), rates, db)
+}
+
+func LoadExchangeRatesContext(ctx context.Context, rates []ExchangeRate, db *sql.DB) (err error) {
+	return sqliteBank(db).LoadExchangeRates(ctx, rates)
+}
+
+func (receiver *Bank) LoadExchangeRates(ctx context.Context, rates []ExchangeRate) (err error) {
+	for _, rate := range rates {
+		if !IsSupportedCurrency(rate.Base) || !IsSupportedCurrency(rate.Quote) {
+			return ErrUnsupportedCurrency
+		}
+		if rate.Base == rate.Quote || rate.Buy <= 0 || rate.Sell <= 0 || rate.Buy > rate.Sell {
+			return ErrInvalidRate
+		}
+	}
+
+	tx, err := receiver.store.Begin(ctx, TxOptions{})
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+			return
+		}
+		err = tx.Commit()
+	}()
+
+	for _, rate := range rates {
+		if rate.EffectiveAt.IsZero() {
+			rate.EffectiveAt = receiver.now()
+		}
+
+		err = tx.AddExchangeRate(ctx, rate)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func GetExchangeRate(base, quote string, db *sql.DB) (rate ExchangeRate, err error) {
+	return GetExchangeRateContext(context.Background(), base, quote, db)
+}
+
+func GetExchangeRateContext(ctx context.Context, base, quote string, db *sql.DB) (rate ExchangeRate, err error) {
+	return sqliteBank(db).GetExchangeRate(ctx, base, quote)
+}
+
+func (receiver *Bank) GetExchangeRate(ctx context.Context, base, quote string) (rate ExchangeRate, err error) {
+	tx, err := receiver.store.Begin(ctx, TxOptions{ReadOnly: true})
+	if err != nil {
+		return ExchangeRate{}, err
+	}
+
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+			return
+		}
+		err = tx.Commit()
+	}()
+
+	return tx.GetExchangeRate(ctx, base, quote, receiver.now())
+}
+
+// convert returns amount expressed in currency together with the rate used.
+// Results are rounded down to whole minor units.
+func (receiver *Bank) convert(ctx context.Context, amount Money, currency string, tx Tx) (converted Money, rate Rate, err error) {
+	current := receiver.now()
+
+	pair, err := tx.GetExchangeRate(ctx, amount.Currency, currency, current)
+	if err == nil {
+		converted = Money{
+			Amount:   scaleAmount(amount.Amount, int64(pair.Buy), minorUnitDigits(currency), pow10(RateDigits), minorUnitDigits(amount.Currency)),
+			Currency: currency,
+		}
+		return converted, pair.Buy, nil
+	}
+	if !errors.Is(err, ErrExchangeRateNotFound) {
+		return Money{}, 0, err
+	}
+
+	pair, err = tx.GetExchangeRate(ctx, currency, amount.Currency, current)
+	if err != nil {
+		return Money{}, 0, err
+	}
+
+	converted = Money{
+		Amount:   scaleAmount(amount.Amount, pow10(RateDigits), minorUnitDigits(currency), int64(pair.Sell), minorUnitDigits(amount.Currency)),
+		Currency: currency,
+	}
+	return converted, pair.Sell, nil
+}
+
+// scaleAmount computes floor(amount * numerator * 10^toDigits / (denominator * 10^fromDigits)).
+func scaleAmount(amount, numerator int64, toDigits int, denominator int64, fromDigits int) int64 {
+	result := new(big.Int).Mul(big.NewInt(amount), big.NewInt(numerator))
+	result.Mul(result, big.NewInt(pow10(toDigits)))
+	divisor := new(big.Int).Mul(big.NewInt(denominator), big.NewInt(pow10(fromDigits)))
+	return result.Quo(result, divisor).Int64()
+}
+
+// ConvertBetweenOwnAccounts moves amount from one of the client's accounts to
+// another one in a different currency at the current bank rate.
+func ConvertBetweenOwnAccounts(session Session, fromAccountId, toAccountId int64, amount Money, db *sql.DB, options ...MoveOption) (converted Money, err error) {
+	return ConvertBetweenOwnAccountsContext(context.Background(), session, fromAccountId, toAccountId, amount, db, options...)
+}
+
+func ConvertBetweenOwnAccountsContext(ctx context.Context, session Session, fromAccountId, toAccountId int64, amount Money, db *sql.DB, options ...MoveOption) (converted Money, err error) {
+	return sqliteBank(db).ConvertBetweenOwnAccounts(ctx, session, fromAccountId, toAccountId, amount, options...)
+}
+
+func (receiver *Bank) ConvertBetweenOwnAccounts(ctx context.Context, session Session, fromAccountId, toAccountId int64, amount Money, options ...MoveOption) (converted Money, err error) {
+	err = receiver.checkAmount(amount)
+	if err != nil {
+		return Money{}, err
+	}
+
+	request := fmt.Sprintf("%s from account %d to account %d: %v", Conversion, fromAccountId, toAccountId, amount)
+	return receiver.moveMoney(ctx, session, request, options, func(tx Tx) (journalId int64, result Money, err error) {
+		return receiver.convertBetweenOwnAccounts(ctx, session, fromAccountId, toAccountId, amount, tx)
+	})
+}
+
+func (receiver *Bank) convertBetweenOwnAccounts(ctx context.Context, session Session, fromAccountId, toAccountId int64, amount Money, tx Tx) (journalId int64, converted Money, err error) {
+	sender, err := receiver.withdraw(ctx, session, fromAccountId, amount, tx)
+	if err != nil {
+		return 0, Money{}, err
+	}
+
+	target, err := getAccountState(ctx, toAccountId, tx)
+	if errors.Is(err, ErrAccountNotExist) {
+		return 0, Money{}, ErrTargetAccountNotExist
+	}
+	if err != nil {
+		return 0, Money{}, err
+	}
+
+	if target.clientId != sender.clientId {
+		return 0, Money{}, ErrAccountNotOwned
+	}
+
+	if target.balance.Currency == amount.Currency {
+		return 0, Money{}, ErrSameCurrency
+	}
+
+	converted, rate, err := receiver.convert(ctx, amount, target.balance.Currency, tx)
+	if err != nil {
+		return 0, Money{}, err
+	}
+
+	if converted.Amount <= 0 {
+		return 0, Money{}, ErrInvalidAmount
+	}
+
+	target, err = deposit(ctx, toAccountId, converted, tx)
+	if err != nil {
+		return 0, Money{}, err
+	}
+
+	journalId, err = receiver.addTransferToJournal(ctx, Conversion, strconv.FormatInt(toAccountId, 10), sender, target, amount, converted, rate, tx)
+	if err != nil {
+		return 0, Money{}, err
+	}
+
+	err = receiver.post(ctx, Conversion, journalId, []LedgerEntry{
+		accountEntry(fromAccountId, negate(amount)),
+		systemEntry(systemExchange, amount),
+		systemEntry(systemExchange, negate(converted)),
+		accountEntry(toAccountId, converted),
+	}, tx)
+	if err != nil {
+		return 0, Money{}, err
+	}
+
+	return journalId, converted, nil
+}
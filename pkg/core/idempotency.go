@@ -0,0 +1,128 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+var (
+	ErrIdempotencyKeyNotFound = errors.New("idempotency key not found")
+	ErrIdempotencyKeyReused   = errors.New("idempotency key was used for another request")
+)
+
+// IdempotencyKey remembers the outcome of a money-moving operation a client
+// ran with a key. Request describes the operation and its arguments, Result
+// is the amount the operation ended up moving.
+type IdempotencyKey struct {
+	Key       string
+	Request   string
+	JournalId int64
+	Result    Money
+	CreatedAt time.Time
+}
+
+const DefaultIdempotencyWindow = 24 * time.Hour
+
+var idempotencyWindow = DefaultIdempotencyWindow
+
+// SetIdempotencyWindow sets how long idempotency keys are remembered.
+func SetIdempotencyWindow(window time.Duration) {
+	settingsMu.Lock()
+	defer settingsMu.Unlock()
+	idempotencyWindow = window
+}
+
+// MoveOption adjusts a single payment, transfer or conversion.
+type MoveOption func(options *moveOptions)
+
+type moveOptions struct {
+	idempotencyKey string
+}
+
+// WithIdempotencyKey makes a payment, transfer or conversion idempotent: a
+// repeat of the same request with the same key within the idempotency window
+// returns the result of the first one instead of moving money again. Keys are
+// per client.
+func WithIdempotencyKey(key string) MoveOption {
+	return func(options *moveOptions) {
+		options.idempotencyKey = key
+	}
+}
+
+// moveMoney runs operation in a transaction of its own, again when the
+// transaction collides with a concurrent one, and returns the amount it
+// moved. Under an idempotency key a repeated request isn't run, the result
+// of the first one is returned instead.
+func (receiver *Bank) moveMoney(ctx context.Context, session Session, request string, options []MoveOption, operation func(tx Tx) (journalId int64, result Money, err error)) (result Money, err error) {
+	var applied moveOptions
+	for _, option := range options {
+		option(&applied)
+	}
+
+	err = receiver.retry(ctx, func() (err error) {
+		result, err = receiver.moveMoneyOnce(ctx, session, request, applied.idempotencyKey, operation)
+		return err
+	})
+	return result, err
+}
+
+func (receiver *Bank) moveMoneyOnce(ctx context.Context, session Session, request, key string, operation func(tx Tx) (journalId int64, result Money, err error)) (result Money, err error) {
+	tx, err := receiver.store.Begin(ctx, TxOptions{})
+	if err != nil {
+		return Money{}, err
+	}
+
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+			return
+		}
+		err = tx.Commit()
+	}()
+
+	if key == "" {
+		_, result, err = operation(tx)
+		return result, err
+	}
+
+	clientId, err := receiver.sessionClientId(ctx, session, tx)
+	if err != nil {
+		return Money{}, err
+	}
+
+	current := receiver.now()
+	err = tx.DeleteIdempotencyKeysBefore(ctx, current.Add(-receiver.idempotencyWindow))
+	if err != nil {
+		return Money{}, err
+	}
+
+	stored, err := tx.GetIdempotencyKey(ctx, clientId, key)
+	if err == nil {
+		if stored.Request != request {
+			return Money{}, ErrIdempotencyKeyReused
+		}
+		return stored.Result, nil
+	}
+	if !errors.Is(err, ErrIdempotencyKeyNotFound) {
+		return Money{}, err
+	}
+
+	journalId, result, err := operation(tx)
+	if err != nil {
+		return Money{}, err
+	}
+
+	err = tx.AddIdempotencyKey(ctx, clientId, IdempotencyKey{
+		Key:       key,
+		Request:   request,
+		JournalId: journalId,
+		Result:    result,
+		CreatedAt: current,
+	})
+	if err != nil {
+		return Money{}, err
+	}
+
+	return result, nil
+}
@@ -0,0 +1,225 @@
+package core
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+// JournalQuery selects journal entries of one client. Every method returns
+// a copy with one more filter, so queries can be shared and extended:
+//
+//	query := NewJournalQuery("vasya").Types(Transfer).From(monthAgo).Descending()
+//	journals, err := GetJournalListContext(ctx, query.Page(20, 0), db)
+type JournalQuery struct {
+	login        string
+	from         time.Time
+	to           time.Time
+	types        []string
+	counterparty string
+	accountId    int64
+	minAmount    *Money
+	maxAmount    *Money
+	descending   bool
+	limit        int64
+	offset       int64
+}
+
+func NewJournalQuery(login string) JournalQuery {
+	return JournalQuery{login: login, limit: -1}
+}
+
+// From keeps entries made at or after from.
+func (receiver JournalQuery) From(from time.Time) JournalQuery {
+	receiver.from = from
+	return receiver
+}
+
+// To keeps entries made before to.
+func (receiver JournalQuery) To(to time.Time) JournalQuery {
+	receiver.to = to
+	return receiver
+}
+
+func (receiver JournalQuery) Types(types ...string) JournalQuery {
+	receiver.types = append(append([]string(nil), receiver.types...), types...)
+	return receiver
+}
+
+// Counterparty keeps entries with the given phone number or service name
+// on the other side.
+func (receiver JournalQuery) Counterparty(counterparty string) JournalQuery {
+	receiver.counterparty = counterparty
+	return receiver
+}
+
+func (receiver JournalQuery) AccountId(accountId int64) JournalQuery {
+	receiver.accountId = accountId
+	return receiver
+}
+
+// MinAmount keeps entries in amount's currency of at least amount.
+func (receiver JournalQuery) MinAmount(amount Money) JournalQuery {
+	receiver.minAmount = &amount
+	return receiver
+}
+
+// MaxAmount keeps entries in amount's currency of at most amount.
+func (receiver JournalQuery) MaxAmount(amount Money) JournalQuery {
+	receiver.maxAmount = &amount
+	return receiver
+}
+
+// Descending returns the newest entries first.
+func (receiver JournalQuery) Descending() JournalQuery {
+	receiver.descending = true
+	return receiver
+}
+
+func (receiver JournalQuery) Page(limit, offset int64) JournalQuery {
+	receiver.limit, receiver.offset = limit, offset
+	return receiver
+}
+
+// filter resolves the query for the journal of clientId.
+func (receiver JournalQuery) filter(clientId int64) (JournalFilter, error) {
+	if receiver.minAmount != nil && receiver.maxAmount != nil && receiver.minAmount.Currency != receiver.maxAmount.Currency {
+		return JournalFilter{}, ErrCurrencyMismatch
+	}
+
+	return JournalFilter{
+		ClientId:     clientId,
+		From:         receiver.from,
+		To:           receiver.to,
+		Types:        receiver.types,
+		Counterparty: receiver.counterparty,
+		AccountId:    receiver.accountId,
+		MinAmount:    receiver.minAmount,
+		MaxAmount:    receiver.maxAmount,
+		Descending:   receiver.descending,
+		Limit:        receiver.limit,
+		Offset:       receiver.offset,
+	}, nil
+}
+
+func GetJournalList(query JournalQuery, db *sql.DB) (journals []Journal, err error) {
+	return GetJournalListContext(context.Background(), query, db)
+}
+
+func GetJournalListContext(ctx context.Context, query JournalQuery, db *sql.DB) (journals []Journal, err error) {
+	return sqliteBank(db).GetJournalList(ctx, query)
+}
+
+func (receiver *Bank) GetJournalList(ctx context.Context, query JournalQuery) (journals []Journal, err error) {
+	tx, err := receiver.store.Begin(ctx, TxOptions{ReadOnly: true})
+	if err != nil {
+		return nil, err
+	}
+
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+			return
+		}
+		err = tx.Commit()
+	}()
+
+	client, err := tx.GetClientByLogin(ctx, query.login)
+	if err != nil {
+		return nil, err
+	}
+
+	filter, err := query.filter(client.Id)
+	if err != nil {
+		return nil, err
+	}
+
+	return tx.ListJournal(ctx, filter)
+}
+
+// GetJournalPage reads the journal page by page in the query's order. The
+// query's own Page is ignored in favour of request.
+func GetJournalPage(query JournalQuery, request PageRequest, db *sql.DB) (page JournalPage, err error) {
+	return GetJournalPageContext(context.Background(), query, request, db)
+}
+
+func GetJournalPageContext(ctx context.Context, query JournalQuery, request PageRequest, db *sql.DB) (page JournalPage, err error) {
+	return sqliteBank(db).GetJournalPage(ctx, query, request)
+}
+
+func (receiver *Bank) GetJournalPage(ctx context.Context, query JournalQuery, request PageRequest) (page JournalPage, err error) {
+	err = receiver.checkPageRequest(request)
+	if err != nil {
+		return JournalPage{}, err
+	}
+
+	position, err := decodeCursor(journalCursor, request.Cursor)
+	if err != nil {
+		return JournalPage{}, err
+	}
+
+	tx, err := receiver.store.Begin(ctx, TxOptions{ReadOnly: true})
+	if err != nil {
+		return JournalPage{}, err
+	}
+
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+			return
+		}
+		err = tx.Commit()
+	}()
+
+	client, err := tx.GetClientByLogin(ctx, query.login)
+	if err != nil {
+		return JournalPage{}, err
+	}
+
+	filter, err := query.filter(client.Id)
+	if err != nil {
+		return JournalPage{}, err
+	}
+
+	filter.Descending = query.descending != position.Before
+	if request.Cursor != "" {
+		date, err := parseTime(position.Date)
+		if err != nil {
+			return JournalPage{}, ErrInvalidCursor
+		}
+		filter.After = &JournalPosition{Date: date, Id: position.Id}
+	}
+	filter.Limit, filter.Offset = request.Limit+1, 0
+
+	page.Journals, err = tx.ListJournal(ctx, filter)
+	if err != nil {
+		return JournalPage{}, err
+	}
+
+	more := int64(len(page.Journals)) > request.Limit
+	if more {
+		page.Journals = page.Journals[:request.Limit]
+	}
+	if position.Before {
+		for i, j := 0, len(page.Journals)-1; i < j; i, j = i+1, j-1 {
+			page.Journals[i], page.Journals[j] = page.Journals[j], page.Journals[i]
+		}
+	}
+
+	if len(page.Journals) > 0 {
+		first, last := page.Journals[0], page.Journals[len(page.Journals)-1]
+		page.NextCursor, page.PreviousCursor = pageCursors(request.Cursor, position, more,
+			cursor{Kind: journalCursor, Id: first.Id, Date: formatTime(first.Date)},
+			cursor{Kind: journalCursor, Id: last.Id, Date: formatTime(last.Date)},
+		)
+	}
+
+	if request.WithTotal {
+		page.Total, err = tx.CountJournal(ctx, filter)
+		if err != nil {
+			return JournalPage{}, err
+		}
+	}
+
+	return page, nil
+}
@@ -0,0 +1,227 @@
+package core
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+)
+
+var (
+	ErrUnbalancedPosting = errors.New("posting debits and credits differ")
+	ErrLedgerMismatch    = errors.New("account balance differs from ledger")
+)
+
+// System ledger accounts hold the other side of money entering or leaving
+// client accounts.
+const (
+	systemOpening  = "opening"
+	systemServices = "services"
+	systemExchange = "exchange"
+	systemImport   = "import"
+	systemPayouts  = "payouts"
+	systemFees     = "fees"
+)
+
+func accountEntry(accountId int64, amount Money) LedgerEntry {
+	return LedgerEntry{AccountId: accountId, Amount: amount}
+}
+
+func systemEntry(name string, amount Money) LedgerEntry {
+	return LedgerEntry{SystemAccount: name, Amount: amount}
+}
+
+func negate(amount Money) Money {
+	return Money{Amount: -amount.Amount, Currency: amount.Currency}
+}
+
+// heldBalance returns the balance entry changes when it is a client account
+// or a provider settlement account. Other system accounts hold no balance.
+func heldBalance(ctx context.Context, entry LedgerEntry, tx Tx) (balance Money, held bool, err error) {
+	if entry.SystemAccount == "" {
+		account, err := tx.GetAccount(ctx, entry.AccountId)
+		if err != nil {
+			return Money{}, false, err
+		}
+		return account.Balance, true, nil
+	}
+
+	providerId, ok := settlementProvider(entry.SystemAccount)
+	if !ok {
+		return Money{}, false, nil
+	}
+
+	provider, err := tx.GetProvider(ctx, providerId)
+	if err != nil {
+		return Money{}, false, err
+	}
+	return provider.Balance, true, nil
+}
+
+// applyEntry changes the balance entry is booked to, if it holds one.
+func applyEntry(ctx context.Context, entry LedgerEntry, tx Tx) (err error) {
+	if entry.SystemAccount == "" {
+		return tx.UpdateBalance(ctx, entry.AccountId, entry.Amount.Amount)
+	}
+
+	providerId, ok := settlementProvider(entry.SystemAccount)
+	if !ok {
+		return nil
+	}
+	return tx.UpdateProviderBalance(ctx, providerId, entry.Amount.Amount)
+}
+
+// post records posting unless it has no non-zero entries. The entries must
+// sum to zero in every currency.
+func post(ctx context.Context, posting Posting, tx Tx) (err error) {
+	sums := make(map[string]int64)
+	var entries []LedgerEntry
+	for _, entry := range posting.Entries {
+		sums[entry.Amount.Currency] += entry.Amount.Amount
+		if entry.Amount.Amount != 0 {
+			entries = append(entries, entry)
+		}
+	}
+	for currency, sum := range sums {
+		if sum != 0 {
+			return fmt.Errorf("%w: %s %s", ErrUnbalancedPosting, posting.Type, currency)
+		}
+	}
+	if len(entries) == 0 {
+		return nil
+	}
+
+	posting.Entries = entries
+	_, err = tx.AddPosting(ctx, posting)
+	return err
+}
+
+// post records entries as one posting made now. A journalId of zero leaves
+// the posting unlinked.
+func (receiver *Bank) post(ctx context.Context, kind string, journalId int64, entries []LedgerEntry, tx Tx) (err error) {
+	return post(ctx, Posting{
+		JournalId: journalId,
+		Type:      kind,
+		CreatedAt: receiver.now(),
+		Entries:   entries,
+	}, tx)
+}
+
+// postAdjustment brings the ledger of accountId in line with balance,
+// booking the difference against the system account.
+func (receiver *Bank) postAdjustment(ctx context.Context, kind, systemAccount string, accountId int64, balance Money, tx Tx) (err error) {
+	balances, err := tx.GetLedgerBalances(ctx, accountId)
+	if err != nil {
+		return err
+	}
+
+	differences := map[string]int64{balance.Currency: balance.Amount}
+	for _, current := range balances {
+		differences[current.Currency] -= current.Amount
+	}
+
+	var entries []LedgerEntry
+	for currency, difference := range differences {
+		entries = append(entries,
+			accountEntry(accountId, NewMoney(difference, currency)),
+			systemEntry(systemAccount, NewMoney(-difference, currency)),
+		)
+	}
+
+	return receiver.post(ctx, kind, 0, entries, tx)
+}
+
+// GetLedgerBalance derives the balance of an account from its ledger entries.
+func GetLedgerBalance(accountId int64, db *sql.DB) (balance Money, err error) {
+	return GetLedgerBalanceContext(context.Background(), accountId, db)
+}
+
+func GetLedgerBalanceContext(ctx context.Context, accountId int64, db *sql.DB) (balance Money, err error) {
+	return sqliteBank(db).GetLedgerBalance(ctx, accountId)
+}
+
+func (receiver *Bank) GetLedgerBalance(ctx context.Context, accountId int64) (balance Money, err error) {
+	tx, err := receiver.store.Begin(ctx, TxOptions{ReadOnly: true})
+	if err != nil {
+		return Money{}, err
+	}
+
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+			return
+		}
+		err = tx.Commit()
+	}()
+
+	account, err := tx.GetAccount(ctx, accountId)
+	if err != nil {
+		return Money{}, err
+	}
+
+	balances, err := tx.GetLedgerBalances(ctx, accountId)
+	if err != nil {
+		return Money{}, err
+	}
+
+	balance = NewMoney(0, account.Balance.Currency)
+	for _, current := range balances {
+		if current.Currency == balance.Currency {
+			balance.Amount = current.Amount
+		}
+	}
+
+	return balance, nil
+}
+
+// CheckLedger verifies that every posting sums to zero per currency, and so
+// does the whole ledger, and that account and provider balances match their
+// entries.
+func CheckLedger(db *sql.DB) (err error) {
+	return CheckLedgerContext(context.Background(), db)
+}
+
+func CheckLedgerContext(ctx context.Context, db *sql.DB) (err error) {
+	return sqliteBank(db).CheckLedger(ctx)
+}
+
+func (receiver *Bank) CheckLedger(ctx context.Context) (err error) {
+	tx, err := receiver.store.Begin(ctx, TxOptions{ReadOnly: true})
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+			return
+		}
+		err = tx.Commit()
+	}()
+
+	postingId, sum, found, err := tx.GetUnbalancedPosting(ctx)
+	if err != nil {
+		return err
+	}
+	if found {
+		return fmt.Errorf("%w: posting %d is off by %v", ErrUnbalancedPosting, postingId, sum)
+	}
+
+	account, ledger, found, err := tx.GetAccountOutOfLedger(ctx)
+	if err != nil {
+		return err
+	}
+	if found {
+		return fmt.Errorf("%w: account %d has %v, ledger %v", ErrLedgerMismatch, account.Id, account.Balance, ledger)
+	}
+
+	provider, ledger, found, err := tx.GetProviderOutOfLedger(ctx)
+	if err != nil {
+		return err
+	}
+	if found {
+		return fmt.Errorf("%w: provider %d has %v, ledger %v", ErrLedgerMismatch, provider.Id, provider.Balance, ledger)
+	}
+
+	return nil
+}
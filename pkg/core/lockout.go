@@ -0,0 +1,236 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+var ErrClientTemporarilyLocked = errors.New("client is temporarily locked")
+
+// LockoutPolicy describes when repeated wrong passwords lock a client.
+// MaxFailedAttempts of zero disables the lockout, a zero Window never forgets
+// failures and a zero LockDuration keeps the client locked until an admin
+// unlocks it with ChangeClientStatus.
+type LockoutPolicy struct {
+	MaxFailedAttempts int64
+	Window            time.Duration
+	LockDuration      time.Duration
+}
+
+var DefaultLockoutPolicy = LockoutPolicy{
+	MaxFailedAttempts: 5,
+	Window:            15 * time.Minute,
+	LockDuration:      30 * time.Minute,
+}
+
+var lockoutPolicy = DefaultLockoutPolicy
+
+func SetLockoutPolicy(policy LockoutPolicy) {
+	settingsMu.Lock()
+	defer settingsMu.Unlock()
+	lockoutPolicy = policy
+}
+
+// lockedError is the error refusing a login of a client locked until
+// lockedUntil, a zero time for a lock only an admin lifts.
+func lockedError(lockedUntil time.Time) error {
+	if lockedUntil.IsZero() {
+		return ErrClientIsLocked
+	}
+	return ErrClientTemporarilyLocked
+}
+
+// reserveLogin starts a login attempt. It unlocks a client whose temporary
+// lock has run out and refuses locked clients. Under the lockout policy the
+// attempt is counted as failed before the password is checked, so that
+// concurrent attempts can't get past the limit: a client with as many
+// attempts counted as the policy allows is refused as well.
+func (receiver *Bank) reserveLogin(ctx context.Context, login string) (client Client, attempts LoginAttempts, err error) {
+	tx, err := receiver.store.Begin(ctx, TxOptions{})
+	if err != nil {
+		return Client{}, LoginAttempts{}, err
+	}
+
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+			return
+		}
+		err = tx.Commit()
+	}()
+
+	client, err = tx.GetClientByLogin(ctx, login)
+	if err != nil {
+		return Client{}, LoginAttempts{}, err
+	}
+
+	attempts, err = tx.GetLoginAttempts(ctx, client.Id)
+	if err != nil {
+		return Client{}, LoginAttempts{}, err
+	}
+
+	current := receiver.now()
+	if client.Status == Locked {
+		if attempts.LockedUntil.IsZero() || current.Before(attempts.LockedUntil) {
+			return Client{}, LoginAttempts{}, lockedError(attempts.LockedUntil)
+		}
+
+		err = tx.SetClientStatus(ctx, client.Id, Active)
+		if err != nil {
+			return Client{}, LoginAttempts{}, err
+		}
+		receiver.logger.Printf("client %d unlocked after %v", client.Id, attempts.LockedUntil)
+
+		client.Status = Active
+		attempts.FailedCount = 0
+		attempts.FirstFailedAt = time.Time{}
+		attempts.LockedUntil = time.Time{}
+	}
+
+	policy := receiver.lockoutPolicy
+	if policy.MaxFailedAttempts <= 0 {
+		return client, attempts, nil
+	}
+
+	if attempts.FirstFailedAt.IsZero() || (policy.Window > 0 && current.Sub(attempts.FirstFailedAt) > policy.Window) {
+		attempts.FailedCount = 0
+		attempts.FirstFailedAt = current
+	}
+	if attempts.FailedCount >= policy.MaxFailedAttempts {
+		var lockedUntil time.Time
+		if policy.LockDuration > 0 {
+			lockedUntil = current.Add(policy.LockDuration)
+		}
+		return Client{}, LoginAttempts{}, lockedError(lockedUntil)
+	}
+	attempts.FailedCount++
+
+	err = tx.SaveFailedLogins(ctx, client.Id, attempts)
+	if err != nil {
+		return Client{}, LoginAttempts{}, err
+	}
+
+	return client, attempts, nil
+}
+
+// releaseLogin takes back the attempt reserveLogin counted for a login that
+// failed before the password could be checked.
+func (receiver *Bank) releaseLogin(ctx context.Context, clientId int64) (err error) {
+	if receiver.lockoutPolicy.MaxFailedAttempts <= 0 {
+		return nil
+	}
+
+	tx, err := receiver.store.Begin(ctx, TxOptions{})
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+			return
+		}
+		err = tx.Commit()
+	}()
+
+	attempts, err := tx.GetLoginAttempts(ctx, clientId)
+	if err != nil {
+		return err
+	}
+
+	if attempts.FailedCount == 0 {
+		return nil
+	}
+	attempts.FailedCount--
+
+	return tx.SaveFailedLogins(ctx, clientId, attempts)
+}
+
+// recordFailedLogin locks the client once the failed attempts reserved by
+// reserveLogin reach the limit of the lockout policy.
+func (receiver *Bank) recordFailedLogin(ctx context.Context, clientId int64) (err error) {
+	policy := receiver.lockoutPolicy
+	if policy.MaxFailedAttempts <= 0 {
+		return nil
+	}
+
+	tx, err := receiver.store.Begin(ctx, TxOptions{})
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+			return
+		}
+		err = tx.Commit()
+	}()
+
+	client, err := tx.GetClient(ctx, clientId)
+	if err != nil {
+		return err
+	}
+
+	attempts, err := tx.GetLoginAttempts(ctx, clientId)
+	if err != nil {
+		return err
+	}
+
+	if client.Status == Locked || attempts.FailedCount < policy.MaxFailedAttempts {
+		return nil
+	}
+
+	if policy.LockDuration > 0 {
+		attempts.LockedUntil = receiver.now().Add(policy.LockDuration)
+	}
+
+	err = tx.SetClientStatus(ctx, clientId, Locked)
+	if err != nil {
+		return err
+	}
+	receiver.logger.Printf("client %d locked after %d failed logins", clientId, attempts.FailedCount)
+
+	return tx.SaveFailedLogins(ctx, clientId, attempts)
+}
+
+// recordSuccessfulLogin remembers the login time and, when passwordHash is
+// set, replaces the client's stored password with it. A client locked while
+// the password was checked is refused.
+func (receiver *Bank) recordSuccessfulLogin(ctx context.Context, client Client, passwordHash string) (err error) {
+	tx, err := receiver.store.Begin(ctx, TxOptions{})
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+			return
+		}
+		err = tx.Commit()
+	}()
+
+	current, err := tx.GetClient(ctx, client.Id)
+	if err != nil {
+		return err
+	}
+
+	if current.Status == Locked {
+		attempts, err := tx.GetLoginAttempts(ctx, client.Id)
+		if err != nil {
+			return err
+		}
+		return lockedError(attempts.LockedUntil)
+	}
+
+	if passwordHash != "" {
+		err = tx.SetClientPassword(ctx, client.Login, passwordHash)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.SaveSuccessfulLogin(ctx, client.Id, receiver.now())
+}
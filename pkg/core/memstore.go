@@ -0,0 +1,1018 @@
+package core
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var ErrConstraintViolation = errors.New("constraint violation")
+
+// MemoryStore keeps the bank in memory, which suits tests and demos that
+// can't use cgo. It enforces the constraints of the SQLite schema and runs
+// one transaction at a time: Begin waits for the running one to finish and
+// works on a copy of the state that Commit puts in place.
+type MemoryStore struct {
+	// lock holds a value while a transaction runs.
+	lock  chan struct{}
+	state *memoryState
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{lock: make(chan struct{}, 1), state: &memoryState{
+		loginAttempts: make(map[int64]LoginAttempts),
+		sequences:     make(map[string]int64),
+	}}
+}
+
+func (receiver *MemoryStore) Init(ctx context.Context) error {
+	return ctx.Err()
+}
+
+// Begin starts a transaction, read-only ones too wait for the running one.
+// Like a database transaction it is discarded when ctx is done before
+// Commit, waiting for the running one stops then too.
+func (receiver *MemoryStore) Begin(ctx context.Context, options TxOptions) (Tx, error) {
+	err := ctx.Err()
+	if err != nil {
+		return nil, err
+	}
+
+	select {
+	case receiver.lock <- struct{}{}:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	return &memoryTx{ctx: ctx, store: receiver, state: receiver.state.copy()}, nil
+}
+
+type memoryState struct {
+	clients       []Client
+	accounts      []AccountWithClientId
+	services      []memoryService
+	providers     []Provider
+	atms          []ATM
+	journal       []memoryJournal
+	postings      []memoryPosting
+	loginAttempts map[int64]LoginAttempts
+	sessions      []memorySession
+	exchangeRates []ExchangeRate
+	// idempotencyKeys are keyed by client id and key.
+	idempotencyKeys map[memoryIdempotencyKey]IdempotencyKey
+	// reversals are keyed by the reversed journal entry.
+	reversals map[int64]JournalReversal
+	// sequences hold the last id given out per table, ids are never reused.
+	sequences map[string]int64
+}
+
+type memoryJournal struct {
+	clientId int64
+	journal  Journal
+}
+
+type memoryService struct {
+	service    CatalogService
+	providerId int64
+}
+
+type memoryPosting struct {
+	id      int64
+	posting Posting
+}
+
+type memoryIdempotencyKey struct {
+	clientId int64
+	key      string
+}
+
+type memorySession struct {
+	tokenHash string
+	session   Session
+	revokedAt time.Time
+}
+
+// copy returns a state that can be changed without affecting receiver.
+// Nested slices, like the entries of a posting, are never changed in place
+// and are shared.
+func (receiver *memoryState) copy() *memoryState {
+	state := &memoryState{
+		clients:       append([]Client(nil), receiver.clients...),
+		accounts:      append([]AccountWithClientId(nil), receiver.accounts...),
+		services:      append([]memoryService(nil), receiver.services...),
+		providers:     append([]Provider(nil), receiver.providers...),
+		atms:          append([]ATM(nil), receiver.atms...),
+		journal:       append([]memoryJournal(nil), receiver.journal...),
+		postings:      append([]memoryPosting(nil), receiver.postings...),
+		loginAttempts: make(map[int64]LoginAttempts, len(receiver.loginAttempts)),
+		sessions:      append([]memorySession(nil), receiver.sessions...),
+		exchangeRates: append([]ExchangeRate(nil), receiver.exchangeRates...),
+		sequences:     make(map[string]int64, len(receiver.sequences)),
+	}
+	state.idempotencyKeys = make(map[memoryIdempotencyKey]IdempotencyKey, len(receiver.idempotencyKeys))
+	for key, stored := range receiver.idempotencyKeys {
+		state.idempotencyKeys[key] = stored
+	}
+	state.reversals = make(map[int64]JournalReversal, len(receiver.reversals))
+	for journalId, reversal := range receiver.reversals {
+		state.reversals[journalId] = reversal
+	}
+	for clientId, attempts := range receiver.loginAttempts {
+		state.loginAttempts[clientId] = attempts
+	}
+	for table, id := range receiver.sequences {
+		state.sequences[table] = id
+	}
+	return state
+}
+
+// nextId returns id, or the next one of table when id is zero.
+func (receiver *memoryState) nextId(table string, id int64) int64 {
+	if id == 0 {
+		id = receiver.sequences[table] + 1
+	}
+	if id > receiver.sequences[table] {
+		receiver.sequences[table] = id
+	}
+	return id
+}
+
+func constraintError(constraint string) error {
+	return fmt.Errorf("%w: %s", ErrConstraintViolation, constraint)
+}
+
+func (receiver *MemoryStore) unlock() {
+	<-receiver.lock
+}
+
+type memoryTx struct {
+	ctx   context.Context
+	store *MemoryStore
+	state *memoryState
+	done  bool
+}
+
+func (receiver *memoryTx) Commit() error {
+	if receiver.done {
+		return sql.ErrTxDone
+	}
+	receiver.done = true
+	defer receiver.store.unlock()
+
+	err := receiver.ctx.Err()
+	if err != nil {
+		return err
+	}
+
+	receiver.store.state = receiver.state
+	return nil
+}
+
+func (receiver *memoryTx) Rollback() error {
+	if receiver.done {
+		return sql.ErrTxDone
+	}
+	receiver.done = true
+	receiver.store.unlock()
+	return nil
+}
+
+func (receiver *memoryTx) clientIndex(match func(client Client) bool) int {
+	for index, client := range receiver.state.clients {
+		if match(client) {
+			return index
+		}
+	}
+	return -1
+}
+
+// checkClient enforces the unique logins and phone numbers of clients other
+// than the one with client.Id.
+func (receiver *memoryTx) checkClient(client Client) error {
+	for _, other := range receiver.state.clients {
+		if other.Id == client.Id {
+			continue
+		}
+		if other.Login == client.Login {
+			return constraintError("clients.login")
+		}
+		if other.PhoneNumber == client.PhoneNumber {
+			return constraintError("clients.phone_number")
+		}
+	}
+	return nil
+}
+
+func (receiver *memoryTx) AddClient(ctx context.Context, client Client) (id int64, err error) {
+	client.Id = 0
+	err = receiver.checkClient(client)
+	if err != nil {
+		return 0, err
+	}
+
+	client.Id = receiver.state.nextId("clients", 0)
+	client.Status = Active
+	receiver.state.clients = append(receiver.state.clients, client)
+	return client.Id, nil
+}
+
+func (receiver *memoryTx) getClient(match func(client Client) bool, notFound error) (client Client, err error) {
+	index := receiver.clientIndex(match)
+	if index < 0 {
+		return Client{}, notFound
+	}
+	return receiver.state.clients[index], nil
+}
+
+func (receiver *memoryTx) GetClient(ctx context.Context, id int64) (client Client, err error) {
+	return receiver.getClient(func(client Client) bool {
+		return client.Id == id
+	}, ErrClientNotExist)
+}
+
+func (receiver *memoryTx) GetClientByLogin(ctx context.Context, login string) (client Client, err error) {
+	return receiver.getClient(func(client Client) bool {
+		return client.Login == login
+	}, ErrLoginNotFound)
+}
+
+func (receiver *memoryTx) GetClientByPhoneNumber(ctx context.Context, phoneNumber int64) (client Client, err error) {
+	return receiver.getClient(func(client Client) bool {
+		return client.PhoneNumber == phoneNumber
+	}, ErrPhoneNumberNotExist)
+}
+
+func (receiver *memoryTx) SetClientPassword(ctx context.Context, login, password string) error {
+	index := receiver.clientIndex(func(client Client) bool {
+		return client.Login == login
+	})
+	if index >= 0 {
+		receiver.state.clients[index].Password = password
+	}
+	return nil
+}
+
+func (receiver *memoryTx) SetClientStatus(ctx context.Context, id int64, status string) error {
+	index := receiver.clientIndex(func(client Client) bool {
+		return client.Id == id
+	})
+	if index >= 0 {
+		receiver.state.clients[index].Status = status
+	}
+	return nil
+}
+
+func (receiver *memoryTx) SaveClient(ctx context.Context, client Client) error {
+	err := receiver.checkClient(client)
+	if err != nil {
+		return err
+	}
+
+	client.Id = receiver.state.nextId("clients", client.Id)
+	index := receiver.clientIndex(func(other Client) bool {
+		return other.Id == client.Id
+	})
+	if index >= 0 {
+		receiver.state.clients[index] = client
+		return nil
+	}
+
+	clients := append(receiver.state.clients, client)
+	sort.SliceStable(clients, func(i, j int) bool {
+		return clients[i].Id < clients[j].Id
+	})
+	receiver.state.clients = clients
+	return nil
+}
+
+// listClients returns the clients matching filter in order of their ids.
+func (receiver *memoryTx) listClients(match func(client Client) bool) (clients []Client) {
+	for _, client := range receiver.state.clients {
+		if match(client) {
+			clients = append(clients, client)
+		}
+	}
+	return clients
+}
+
+// window returns the bounds of a LIMIT and OFFSET over count rows. A
+// negative limit has no bound, as in SQLite.
+func window(count int, limit, offset int64) (from, to int) {
+	if offset > int64(count) {
+		offset = int64(count)
+	}
+	if offset > 0 {
+		from = int(offset)
+	}
+	to = count
+	if limit >= 0 && limit < int64(to-from) {
+		to = from + int(limit)
+	}
+	return from, to
+}
+
+func (receiver *memoryTx) ListClients(ctx context.Context) (clients []Client, err error) {
+	return receiver.listClients(func(Client) bool {
+		return true
+	}), nil
+}
+
+func (receiver *memoryTx) ListClientsByName(ctx context.Context, limit, offset int64) (clients []Client, err error) {
+	clients = append(clients, receiver.state.clients...)
+	sort.SliceStable(clients, func(i, j int) bool {
+		return clients[i].Name > clients[j].Name
+	})
+
+	from, to := window(len(clients), limit, offset)
+	if from == to {
+		return nil, nil
+	}
+	return clients[from:to], nil
+}
+
+func (receiver *memoryTx) ListClientsAfter(ctx context.Context, id, limit int64) (clients []Client, err error) {
+	clients = receiver.listClients(func(client Client) bool {
+		return client.Id > id
+	})
+
+	_, to := window(len(clients), limit, 0)
+	if to == 0 {
+		return nil, nil
+	}
+	return clients[:to], nil
+}
+
+func (receiver *memoryTx) ListClientsBefore(ctx context.Context, id, limit int64) (clients []Client, err error) {
+	for index := len(receiver.state.clients) - 1; index >= 0; index-- {
+		if limit >= 0 && int64(len(clients)) == limit {
+			break
+		}
+		if client := receiver.state.clients[index]; client.Id < id {
+			clients = append(clients, client)
+		}
+	}
+	return clients, nil
+}
+
+func (receiver *memoryTx) CountClients(ctx context.Context) (count int64, err error) {
+	return int64(len(receiver.state.clients)), nil
+}
+
+// like matches substrings ignoring case, as LIKE '%substring%' does.
+func like(value, substring string) bool {
+	return strings.Contains(strings.ToLower(value), strings.ToLower(substring))
+}
+
+func (receiver *memoryTx) SearchClientsByName(ctx context.Context, name string) (clients []Client, err error) {
+	return receiver.listClients(func(client Client) bool {
+		return like(client.Name, name)
+	}), nil
+}
+
+func (receiver *memoryTx) SearchClientsByPhoneNumber(ctx context.Context, phoneNumber string) (clients []Client, err error) {
+	return receiver.listClients(func(client Client) bool {
+		return like(strconv.FormatInt(client.PhoneNumber, 10), phoneNumber)
+	}), nil
+}
+
+func (receiver *memoryTx) accountIndex(id int64) int {
+	for index, account := range receiver.state.accounts {
+		if account.Id == id {
+			return index
+		}
+	}
+	return -1
+}
+
+func (receiver *memoryTx) AddAccount(ctx context.Context, clientId int64, balance Money) (id int64, err error) {
+	if balance.Amount < 0 {
+		return 0, constraintError("accounts.balance")
+	}
+
+	id = receiver.state.nextId("accounts", 0)
+	receiver.state.accounts = append(receiver.state.accounts, AccountWithClientId{
+		Id:       id,
+		ClientId: clientId,
+		Balance:  balance,
+	})
+	return id, nil
+}
+
+func (receiver *memoryTx) GetAccount(ctx context.Context, id int64) (account AccountWithClientId, err error) {
+	index := receiver.accountIndex(id)
+	if index < 0 {
+		return AccountWithClientId{}, ErrAccountNotExist
+	}
+	return receiver.state.accounts[index], nil
+}
+
+func (receiver *memoryTx) FindClientAccount(ctx context.Context, clientId int64, currency string) (id int64, err error) {
+	for _, account := range receiver.state.accounts {
+		if account.ClientId == clientId && account.Balance.Currency == currency {
+			return account.Id, nil
+		}
+	}
+	return 0, ErrAccountNotExist
+}
+
+func (receiver *memoryTx) ListClientAccounts(ctx context.Context, clientId int64) (accounts []Account, err error) {
+	for _, account := range receiver.state.accounts {
+		if account.ClientId == clientId {
+			accounts = append(accounts, Account{Id: account.Id, Balance: account.Balance})
+		}
+	}
+	return accounts, nil
+}
+
+func (receiver *memoryTx) ListAccounts(ctx context.Context) (accounts []AccountWithClientId, err error) {
+	return append(accounts, receiver.state.accounts...), nil
+}
+
+func (receiver *memoryTx) UpdateBalance(ctx context.Context, id int64, amount int64) error {
+	index := receiver.accountIndex(id)
+	if index < 0 {
+		return ErrAccountNotExist
+	}
+
+	balance := &receiver.state.accounts[index].Balance
+	if balance.Amount+amount < 0 {
+		return constraintError("accounts.balance")
+	}
+	balance.Amount += amount
+	return nil
+}
+
+func (receiver *memoryTx) SaveAccount(ctx context.Context, account AccountWithClientId) error {
+	if account.Balance.Amount < 0 {
+		return constraintError("accounts.balance")
+	}
+
+	account.Id = receiver.state.nextId("accounts", account.Id)
+	index := receiver.accountIndex(account.Id)
+	if index >= 0 {
+		receiver.state.accounts[index] = account
+		return nil
+	}
+
+	accounts := append(receiver.state.accounts, account)
+	sort.SliceStable(accounts, func(i, j int) bool {
+		return accounts[i].Id < accounts[j].Id
+	})
+	receiver.state.accounts = accounts
+	return nil
+}
+
+func (receiver *memoryTx) AddService(ctx context.Context, name string) error {
+	exists, _ := receiver.ServiceExists(ctx, name)
+	if exists {
+		return constraintError("services.name")
+	}
+
+	receiver.state.services = append(receiver.state.services, memoryService{service: CatalogService{Name: name}})
+	return nil
+}
+
+func (receiver *memoryTx) AddCatalogService(ctx context.Context, service CatalogService) error {
+	exists, _ := receiver.ServiceExists(ctx, service.Name)
+	if exists {
+		return constraintError("services.name")
+	}
+
+	receiver.state.services = append(receiver.state.services, memoryService{service: service})
+	return nil
+}
+
+func (receiver *memoryTx) GetCatalogService(ctx context.Context, name string) (service CatalogService, err error) {
+	index := receiver.serviceIndex(name)
+	if index < 0 {
+		return CatalogService{}, ErrServiceNotExist
+	}
+	return receiver.state.services[index].service, nil
+}
+
+func (receiver *memoryTx) ListCatalogServices(ctx context.Context, category string) (services []CatalogService, err error) {
+	for _, stored := range receiver.state.services {
+		if category == "" || stored.service.Category == category {
+			services = append(services, stored.service)
+		}
+	}
+	return services, nil
+}
+
+func (receiver *memoryTx) serviceIndex(name string) int {
+	for index, service := range receiver.state.services {
+		if service.service.Name == name {
+			return index
+		}
+	}
+	return -1
+}
+
+func (receiver *memoryTx) ServiceExists(ctx context.Context, name string) (exists bool, err error) {
+	return receiver.serviceIndex(name) >= 0, nil
+}
+
+func (receiver *memoryTx) GetServiceProvider(ctx context.Context, name string) (providerId int64, err error) {
+	index := receiver.serviceIndex(name)
+	if index < 0 {
+		return 0, ErrServiceNotExist
+	}
+	return receiver.state.services[index].providerId, nil
+}
+
+func (receiver *memoryTx) SetServiceProvider(ctx context.Context, name string, providerId int64) error {
+	index := receiver.serviceIndex(name)
+	if index < 0 {
+		return ErrServiceNotExist
+	}
+	if providerId != 0 && receiver.providerIndex(providerId) < 0 {
+		return constraintError("services.provider_id")
+	}
+
+	receiver.state.services[index].providerId = providerId
+	return nil
+}
+
+func (receiver *memoryTx) providerIndex(id int64) int {
+	for index, provider := range receiver.state.providers {
+		if provider.Id == id {
+			return index
+		}
+	}
+	return -1
+}
+
+func (receiver *memoryTx) AddProvider(ctx context.Context, name, currency string) (id int64, err error) {
+	_, err = receiver.GetProviderByName(ctx, name)
+	if err == nil {
+		return 0, constraintError("providers.name")
+	}
+
+	id = receiver.state.nextId("providers", 0)
+	receiver.state.providers = append(receiver.state.providers, Provider{
+		Id:      id,
+		Name:    name,
+		Balance: NewMoney(0, currency),
+	})
+	return id, nil
+}
+
+func (receiver *memoryTx) GetProvider(ctx context.Context, id int64) (provider Provider, err error) {
+	index := receiver.providerIndex(id)
+	if index < 0 {
+		return Provider{}, ErrProviderNotExist
+	}
+	return receiver.state.providers[index], nil
+}
+
+func (receiver *memoryTx) GetProviderByName(ctx context.Context, name string) (provider Provider, err error) {
+	for _, provider := range receiver.state.providers {
+		if provider.Name == name {
+			return provider, nil
+		}
+	}
+	return Provider{}, ErrProviderNotExist
+}
+
+func (receiver *memoryTx) ListProviders(ctx context.Context) (providers []Provider, err error) {
+	return append(providers, receiver.state.providers...), nil
+}
+
+func (receiver *memoryTx) UpdateProviderBalance(ctx context.Context, id int64, amount int64) error {
+	index := receiver.providerIndex(id)
+	if index < 0 {
+		return ErrProviderNotExist
+	}
+
+	balance := &receiver.state.providers[index].Balance
+	if balance.Amount+amount < 0 {
+		return constraintError("providers.balance")
+	}
+	balance.Amount += amount
+	return nil
+}
+
+func (receiver *memoryTx) AddATM(ctx context.Context, atm ATM) error {
+	exists, _ := receiver.ATMExists(ctx, atm.Location)
+	if exists {
+		return constraintError("atms.location")
+	}
+
+	atm.Id = receiver.state.nextId("atms", 0)
+	receiver.state.atms = append(receiver.state.atms, atm)
+	return nil
+}
+
+func (receiver *memoryTx) ATMExists(ctx context.Context, location string) (exists bool, err error) {
+	for _, atm := range receiver.state.atms {
+		if atm.Location == location {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (receiver *memoryTx) ListATMs(ctx context.Context) (atms []ATM, err error) {
+	return append(atms, receiver.state.atms...), nil
+}
+
+// SaveATM replaces the ATMs with the same id or location, like INSERT OR
+// REPLACE does.
+func (receiver *memoryTx) SaveATM(ctx context.Context, atm ATM) error {
+	atm.Id = receiver.state.nextId("atms", atm.Id)
+
+	var atms []ATM
+	for _, other := range receiver.state.atms {
+		if other.Id != atm.Id && other.Location != atm.Location {
+			atms = append(atms, other)
+		}
+	}
+	atms = append(atms, atm)
+	sort.SliceStable(atms, func(i, j int) bool {
+		return atms[i].Id < atms[j].Id
+	})
+	receiver.state.atms = atms
+	return nil
+}
+
+func (receiver *memoryTx) AddJournal(ctx context.Context, clientId int64, journal Journal) (id int64, err error) {
+	if journal.Amount.Amount <= 0 {
+		return 0, constraintError("journal.amount")
+	}
+
+	journal.Id = receiver.state.nextId("journal", 0)
+	journal.Date = journal.Date.UTC()
+	journal.Fee = optionalMoney(journal.Fee.Amount, journal.Amount.Currency)
+	receiver.state.journal = append(receiver.state.journal, memoryJournal{clientId: clientId, journal: journal})
+	return journal.Id, nil
+}
+
+func (receiver *memoryTx) GetJournal(ctx context.Context, id int64) (clientId int64, journal Journal, err error) {
+	for _, stored := range receiver.state.journal {
+		if stored.journal.Id == id {
+			return stored.clientId, stored.journal, nil
+		}
+	}
+	return 0, Journal{}, ErrJournalNotFound
+}
+
+func (receiver memoryJournal) matches(filter JournalFilter) bool {
+	journal := receiver.journal
+	switch {
+	case receiver.clientId != filter.ClientId:
+		return false
+	case !filter.From.IsZero() && journal.Date.Before(filter.From):
+		return false
+	case !filter.To.IsZero() && !journal.Date.Before(filter.To):
+		return false
+	case filter.Counterparty != "" && journal.Counterparty != filter.Counterparty:
+		return false
+	case filter.AccountId != 0 && journal.AccountId != filter.AccountId:
+		return false
+	case filter.MinAmount != nil && (journal.Amount.Currency != filter.MinAmount.Currency || journal.Amount.Amount < filter.MinAmount.Amount):
+		return false
+	case filter.MaxAmount != nil && (journal.Amount.Currency != filter.MaxAmount.Currency || journal.Amount.Amount > filter.MaxAmount.Amount):
+		return false
+	}
+
+	if len(filter.Types) == 0 {
+		return true
+	}
+	for _, kind := range filter.Types {
+		if journal.Type == kind {
+			return true
+		}
+	}
+	return false
+}
+
+// comparePositions orders journal entries by date and then id.
+func comparePositions(a, b JournalPosition) int {
+	switch {
+	case a.Date.Before(b.Date):
+		return -1
+	case a.Date.After(b.Date):
+		return 1
+	case a.Id < b.Id:
+		return -1
+	case a.Id > b.Id:
+		return 1
+	}
+	return 0
+}
+
+func (receiver *memoryTx) listJournal(filter JournalFilter) (journals []Journal) {
+	for _, entry := range receiver.state.journal {
+		if entry.matches(filter) {
+			journals = append(journals, entry.journal)
+		}
+	}
+	return journals
+}
+
+func (receiver *memoryTx) ListJournal(ctx context.Context, filter JournalFilter) (journals []Journal, err error) {
+	order := 1
+	if filter.Descending {
+		order = -1
+	}
+
+	for _, journal := range receiver.listJournal(filter) {
+		position := JournalPosition{Date: journal.Date, Id: journal.Id}
+		if filter.After == nil || order*comparePositions(position, *filter.After) > 0 {
+			journals = append(journals, journal)
+		}
+	}
+	sort.Slice(journals, func(i, j int) bool {
+		a := JournalPosition{Date: journals[i].Date, Id: journals[i].Id}
+		b := JournalPosition{Date: journals[j].Date, Id: journals[j].Id}
+		return order*comparePositions(a, b) < 0
+	})
+
+	from, to := window(len(journals), filter.Limit, filter.Offset)
+	if from == to {
+		return nil, nil
+	}
+	return journals[from:to], nil
+}
+
+func (receiver *memoryTx) CountJournal(ctx context.Context, filter JournalFilter) (count int64, err error) {
+	return int64(len(receiver.listJournal(filter))), nil
+}
+
+func (receiver *memoryTx) AddPosting(ctx context.Context, posting Posting) (id int64, err error) {
+	for _, entry := range posting.Entries {
+		if entry.Amount.Amount == 0 {
+			return 0, constraintError("ledger_entries.amount")
+		}
+	}
+
+	posting.CreatedAt = posting.CreatedAt.UTC()
+	posting.Entries = append([]LedgerEntry(nil), posting.Entries...)
+	id = receiver.state.nextId("postings", 0)
+	receiver.state.postings = append(receiver.state.postings, memoryPosting{id: id, posting: posting})
+	return id, nil
+}
+
+func (receiver *memoryTx) GetJournalPosting(ctx context.Context, journalId int64) (posting Posting, err error) {
+	for _, stored := range receiver.state.postings {
+		if stored.posting.JournalId == journalId {
+			return stored.posting, nil
+		}
+	}
+	return Posting{}, ErrPostingNotFound
+}
+
+// sortedCurrencies returns the keys of sums in order, as GROUP BY currency
+// returns them.
+func sortedCurrencies(sums map[string]int64) (currencies []string) {
+	for currency := range sums {
+		currencies = append(currencies, currency)
+	}
+	sort.Strings(currencies)
+	return currencies
+}
+
+func (receiver *memoryTx) ledgerSums(accountId int64) map[string]int64 {
+	sums := make(map[string]int64)
+	for _, posting := range receiver.state.postings {
+		for _, entry := range posting.posting.Entries {
+			if entry.SystemAccount == "" && entry.AccountId == accountId {
+				sums[entry.Amount.Currency] += entry.Amount.Amount
+			}
+		}
+	}
+	return sums
+}
+
+func (receiver *memoryTx) GetLedgerBalances(ctx context.Context, accountId int64) (balances []Money, err error) {
+	sums := receiver.ledgerSums(accountId)
+	for _, currency := range sortedCurrencies(sums) {
+		balances = append(balances, NewMoney(sums[currency], currency))
+	}
+	return balances, nil
+}
+
+func (receiver *memoryTx) GetUnbalancedPosting(ctx context.Context) (postingId int64, sum Money, found bool, err error) {
+	for _, posting := range receiver.state.postings {
+		sums := make(map[string]int64)
+		for _, entry := range posting.posting.Entries {
+			sums[entry.Amount.Currency] += entry.Amount.Amount
+		}
+		for _, currency := range sortedCurrencies(sums) {
+			if sums[currency] != 0 {
+				return posting.id, NewMoney(sums[currency], currency), true, nil
+			}
+		}
+	}
+	return 0, Money{}, false, nil
+}
+
+func (receiver *memoryTx) GetAccountOutOfLedger(ctx context.Context) (account AccountWithClientId, ledger Money, found bool, err error) {
+	for _, current := range receiver.state.accounts {
+		sum := receiver.ledgerSums(current.Id)[current.Balance.Currency]
+		if sum != current.Balance.Amount {
+			return current, NewMoney(sum, current.Balance.Currency), true, nil
+		}
+	}
+	return AccountWithClientId{}, Money{}, false, nil
+}
+
+func (receiver *memoryTx) GetProviderOutOfLedger(ctx context.Context) (provider Provider, ledger Money, found bool, err error) {
+	for _, current := range receiver.state.providers {
+		var sum int64
+		for _, posting := range receiver.state.postings {
+			for _, entry := range posting.posting.Entries {
+				if entry.SystemAccount == providerAccount(current.Id) && entry.Amount.Currency == current.Balance.Currency {
+					sum += entry.Amount.Amount
+				}
+			}
+		}
+		if sum != current.Balance.Amount {
+			return current, NewMoney(sum, current.Balance.Currency), true, nil
+		}
+	}
+	return Provider{}, Money{}, false, nil
+}
+
+func (receiver *memoryTx) GetSystemAccountTotals(ctx context.Context, systemAccount string, from, to time.Time) (totals []LedgerTotal, err error) {
+	indexes := make(map[LedgerTotal]int)
+	for _, posting := range receiver.state.postings {
+		if posting.posting.CreatedAt.Before(from) || !posting.posting.CreatedAt.Before(to) {
+			continue
+		}
+		for _, entry := range posting.posting.Entries {
+			if entry.SystemAccount != systemAccount {
+				continue
+			}
+
+			key := LedgerTotal{Type: posting.posting.Type, Amount: Money{Currency: entry.Amount.Currency}}
+			index, ok := indexes[key]
+			if !ok {
+				index = len(totals)
+				indexes[key] = index
+				totals = append(totals, key)
+			}
+			totals[index].Count++
+			totals[index].Amount.Amount += entry.Amount.Amount
+		}
+	}
+
+	sort.Slice(totals, func(i, j int) bool {
+		if totals[i].Type != totals[j].Type {
+			return totals[i].Type < totals[j].Type
+		}
+		return totals[i].Amount.Currency < totals[j].Amount.Currency
+	})
+	return totals, nil
+}
+
+func (receiver *memoryTx) GetLoginAttempts(ctx context.Context, clientId int64) (attempts LoginAttempts, err error) {
+	return receiver.state.loginAttempts[clientId], nil
+}
+
+func (receiver *memoryTx) SaveFailedLogins(ctx context.Context, clientId int64, attempts LoginAttempts) error {
+	receiver.state.loginAttempts[clientId] = LoginAttempts{
+		FailedCount:   attempts.FailedCount,
+		FirstFailedAt: attempts.FirstFailedAt.UTC(),
+		LockedUntil:   attempts.LockedUntil.UTC(),
+		LastLoginAt:   receiver.state.loginAttempts[clientId].LastLoginAt,
+	}
+	return nil
+}
+
+func (receiver *memoryTx) SaveSuccessfulLogin(ctx context.Context, clientId int64, at time.Time) error {
+	receiver.state.loginAttempts[clientId] = LoginAttempts{LastLoginAt: at.UTC()}
+	return nil
+}
+
+func (receiver *memoryTx) ResetLoginAttempts(ctx context.Context, clientId int64) error {
+	attempts, ok := receiver.state.loginAttempts[clientId]
+	if ok {
+		receiver.state.loginAttempts[clientId] = LoginAttempts{LastLoginAt: attempts.LastLoginAt}
+	}
+	return nil
+}
+
+func (receiver *memoryTx) AddSession(ctx context.Context, tokenHash string, session Session) (id int64, err error) {
+	for _, other := range receiver.state.sessions {
+		if other.tokenHash == tokenHash {
+			return 0, constraintError("sessions.token_hash")
+		}
+	}
+
+	session.Id = receiver.state.nextId("sessions", 0)
+	session.Token = ""
+	session.CreatedAt = session.CreatedAt.UTC()
+	session.ExpiresAt = session.ExpiresAt.UTC()
+	receiver.state.sessions = append(receiver.state.sessions, memorySession{tokenHash: tokenHash, session: session})
+	return session.Id, nil
+}
+
+func (receiver *memoryTx) GetSession(ctx context.Context, tokenHash string) (session Session, revoked bool, err error) {
+	for _, stored := range receiver.state.sessions {
+		if stored.tokenHash == tokenHash {
+			return stored.session, !stored.revokedAt.IsZero(), nil
+		}
+	}
+	return Session{}, false, ErrSessionNotFound
+}
+
+func (receiver *memoryTx) revokeSessions(match func(session memorySession) bool, at time.Time) {
+	for index, session := range receiver.state.sessions {
+		if session.revokedAt.IsZero() && match(session) {
+			receiver.state.sessions[index].revokedAt = at.UTC()
+		}
+	}
+}
+
+func (receiver *memoryTx) RevokeSession(ctx context.Context, tokenHash string, at time.Time) error {
+	receiver.revokeSessions(func(session memorySession) bool {
+		return session.tokenHash == tokenHash
+	}, at)
+	return nil
+}
+
+func (receiver *memoryTx) RevokeClientSessions(ctx context.Context, clientId int64, at time.Time) error {
+	receiver.revokeSessions(func(session memorySession) bool {
+		return session.session.ClientId == clientId
+	}, at)
+	return nil
+}
+
+func (receiver *memoryTx) AddExchangeRate(ctx context.Context, rate ExchangeRate) error {
+	if rate.Buy <= 0 || rate.Sell <= 0 {
+		return constraintError("exchange_rates.rate")
+	}
+
+	rate.EffectiveAt = rate.EffectiveAt.UTC()
+	receiver.state.exchangeRates = append(receiver.state.exchangeRates, rate)
+	return nil
+}
+
+func (receiver *memoryTx) GetExchangeRate(ctx context.Context, base, quote string, at time.Time) (rate ExchangeRate, err error) {
+	found := false
+	for _, current := range receiver.state.exchangeRates {
+		if current.Base != base || current.Quote != quote || current.EffectiveAt.After(at) {
+			continue
+		}
+		if !found || !current.EffectiveAt.Before(rate.EffectiveAt) {
+			rate, found = current, true
+		}
+	}
+	if !found {
+		return ExchangeRate{}, ErrExchangeRateNotFound
+	}
+	return rate, nil
+}
+
+func (receiver *memoryTx) AddIdempotencyKey(ctx context.Context, clientId int64, key IdempotencyKey) error {
+	index := memoryIdempotencyKey{clientId: clientId, key: key.Key}
+	_, ok := receiver.state.idempotencyKeys[index]
+	if ok {
+		return constraintError("idempotency_keys.idempotency_key")
+	}
+
+	key.CreatedAt = key.CreatedAt.UTC()
+	receiver.state.idempotencyKeys[index] = key
+	return nil
+}
+
+func (receiver *memoryTx) GetIdempotencyKey(ctx context.Context, clientId int64, key string) (stored IdempotencyKey, err error) {
+	stored, ok := receiver.state.idempotencyKeys[memoryIdempotencyKey{clientId: clientId, key: key}]
+	if !ok {
+		return IdempotencyKey{}, ErrIdempotencyKeyNotFound
+	}
+	return stored, nil
+}
+
+func (receiver *memoryTx) DeleteIdempotencyKeysBefore(ctx context.Context, createdBefore time.Time) error {
+	for index, stored := range receiver.state.idempotencyKeys {
+		if stored.CreatedAt.Before(createdBefore) {
+			delete(receiver.state.idempotencyKeys, index)
+		}
+	}
+	return nil
+}
+
+func (receiver *memoryTx) AddReversal(ctx context.Context, reversal JournalReversal) error {
+	_, ok := receiver.state.reversals[reversal.JournalId]
+	if ok {
+		return constraintError("reversals.journal_id")
+	}
+
+	reversal.CreatedAt = reversal.CreatedAt.UTC()
+	receiver.state.reversals[reversal.JournalId] = reversal
+	return nil
+}
+
+func (receiver *memoryTx) GetReversal(ctx context.Context, journalId int64) (reversal JournalReversal, err error) {
+	reversal, ok := receiver.state.reversals[journalId]
+	if !ok {
+		return JournalReversal{}, ErrReversalNotFound
+	}
+	return reversal, nil
+}
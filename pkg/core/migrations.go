@@ -0,0 +1,540 @@
+package core
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/JAbduvohidov/apm-ibank-core/pkg/queries"
+	"time"
+)
+
+var ErrUnknownSchemaVersion = errors.New("unknown schema version")
+
+// migration moves the schema from version-1 to version and back. Steps of
+// migrations that predate the migration engine are idempotent, so databases
+// created by older releases are adopted as they are.
+type migration struct {
+	version     int64
+	description string
+	up          migrationStep
+	down        migrationStep
+}
+
+// migrationStep runs in the transaction of its migration, now dates the rows
+// it writes.
+type migrationStep func(ctx context.Context, tx *sqlTx, now func() time.Time) error
+
+var sqliteMigrations = []migration{
+	{
+		version:     1,
+		description: "clients, accounts, journal, services and atms",
+		up:          statements(queries.ClientsDDL, queries.AccountsDDL, queries.JournalDDL, queries.ServicesDDL, queries.AtmsDDL),
+		down:        statements(queries.DropInitialSchemaSQL),
+	},
+	{
+		version:     2,
+		description: "login attempts and sessions",
+		up:          statements(queries.LoginAttemptsDDL, queries.SessionsDDL),
+		down:        statements(queries.DropSessionsSQL),
+	},
+	{
+		version:     3,
+		description: "account currencies and exchange rates",
+		up: steps(
+			addColumn("accounts", "currency", queries.AccountsCurrencyColumnSQL),
+			addColumn("journal", "currency", queries.JournalCurrencyColumnSQL),
+			addColumn("journal", "rate", queries.JournalRateColumnSQL),
+			statements(queries.ExchangeRatesDDL, queries.ExchangeRatesIndexDDL),
+		),
+		down: statements(queries.DropCurrenciesSQL),
+	},
+	{
+		version:     4,
+		description: "double-entry ledger",
+		up: steps(
+			statements(queries.PostingsDDL, queries.LedgerEntriesDDL, queries.LedgerEntriesAccountIndexDDL),
+			backfillLedger,
+		),
+		down: statements(queries.DropLedgerSQL),
+	},
+	{
+		version:     5,
+		description: "journal directions and counterparties",
+		up: steps(
+			addColumn("journal", "direction", queries.JournalDirectionColumnSQL),
+			addColumn("journal", "account_id", queries.JournalAccountIdColumnSQL),
+			addColumn("journal", "counterparty", queries.JournalCounterpartyColumnSQL),
+			addColumn("journal", "counterparty_account_id", queries.JournalCounterpartyAccountIdColumnSQL),
+		),
+		down: statements(queries.DropJournalPartiesSQL),
+	},
+	{
+		version:     6,
+		description: "UTC journal dates",
+		up:          migrateJournalDates,
+		down:        restoreLegacyJournalDates,
+	},
+	{
+		version:     7,
+		description: "journal filter indexes",
+		up: statements(
+			queries.UpdateLegacyJournalCounterpartySQL,
+			queries.JournalClientDateIndexDDL,
+			queries.JournalClientTypeIndexDDL,
+			queries.JournalClientAccountIndexDDL,
+			queries.JournalClientCounterpartyIndexDDL,
+			queries.JournalClientAmountIndexDDL,
+		),
+		down: statements(queries.DropJournalIndexesSQL),
+	},
+	{
+		version:     8,
+		description: "idempotency keys",
+		up:          statements(queries.IdempotencyKeysDDL, queries.IdempotencyKeysCreatedAtIndexDDL),
+		down:        statements(queries.DropIdempotencyKeysSQL),
+	},
+	{
+		version:     9,
+		description: "reversals",
+		up:          statements(queries.ReversalsDDL, queries.PostingsJournalIndexDDL),
+		down:        statements(queries.DropReversalsSQL),
+	},
+	{
+		version:     10,
+		description: "service providers",
+		up: statements(
+			queries.ProvidersDDL,
+			queries.ServicesProviderIdColumnSQL,
+			queries.LedgerEntriesSystemAccountIndexDDL,
+		),
+		down: statements(queries.DropProvidersSQL),
+	},
+	{
+		version:     11,
+		description: "service catalog",
+		up:          statements(queries.ServiceCatalogColumnsSQL, queries.JournalPaymentColumnsSQL),
+		down: statements(
+			queries.DropServiceCatalogSQL,
+			queries.JournalClientDateIndexDDL,
+			queries.JournalClientTypeIndexDDL,
+			queries.JournalClientAccountIndexDDL,
+			queries.JournalClientCounterpartyIndexDDL,
+			queries.JournalClientAmountIndexDDL,
+		),
+	},
+}
+
+// postgresMigrations build the same schema versions as sqliteMigrations. No
+// Postgres database predates them, so there is nothing to adopt.
+var postgresMigrations = []migration{
+	{
+		version:     1,
+		description: "clients, accounts, journal, services and atms",
+		up: statements(
+			queries.PostgresClientsDDL,
+			queries.PostgresAccountsDDL,
+			queries.PostgresJournalDDL,
+			queries.PostgresServicesDDL,
+			queries.PostgresAtmsDDL,
+		),
+		down: statements(queries.DropInitialSchemaSQL),
+	},
+	{
+		version:     2,
+		description: "login attempts and sessions",
+		up:          statements(queries.PostgresLoginAttemptsDDL, queries.PostgresSessionsDDL),
+		down:        statements(queries.DropSessionsSQL),
+	},
+	{
+		version:     3,
+		description: "account currencies and exchange rates",
+		up: statements(
+			queries.AccountsCurrencyColumnSQL,
+			queries.JournalCurrencyColumnSQL,
+			queries.PostgresJournalRateColumnSQL,
+			queries.PostgresExchangeRatesDDL,
+			queries.ExchangeRatesIndexDDL,
+		),
+		down: statements(queries.PostgresDropCurrenciesSQL),
+	},
+	{
+		version:     4,
+		description: "double-entry ledger",
+		up: steps(
+			statements(queries.PostgresPostingsDDL, queries.PostgresLedgerEntriesDDL, queries.LedgerEntriesAccountIndexDDL),
+			backfillLedger,
+		),
+		down: statements(queries.DropLedgerSQL),
+	},
+	{
+		version:     5,
+		description: "journal directions and counterparties",
+		up: statements(
+			queries.JournalDirectionColumnSQL,
+			queries.PostgresJournalAccountIdColumnSQL,
+			queries.JournalCounterpartyColumnSQL,
+			queries.PostgresJournalCounterpartyAccountIdColumnSQL,
+		),
+		down: statements(queries.PostgresDropJournalPartiesSQL),
+	},
+	{
+		version:     6,
+		description: "UTC journal dates",
+		up:          statements(),
+		down:        statements(),
+	},
+	{
+		version:     7,
+		description: "journal filter indexes",
+		up: statements(
+			queries.UpdateLegacyJournalCounterpartySQL,
+			queries.JournalClientDateIndexDDL,
+			queries.JournalClientTypeIndexDDL,
+			queries.JournalClientAccountIndexDDL,
+			queries.JournalClientCounterpartyIndexDDL,
+			queries.JournalClientAmountIndexDDL,
+		),
+		down: statements(queries.DropJournalIndexesSQL),
+	},
+	{
+		version:     8,
+		description: "idempotency keys",
+		up:          statements(queries.PostgresIdempotencyKeysDDL, queries.IdempotencyKeysCreatedAtIndexDDL),
+		down:        statements(queries.DropIdempotencyKeysSQL),
+	},
+	{
+		version:     9,
+		description: "reversals",
+		up:          statements(queries.PostgresReversalsDDL, queries.PostingsJournalIndexDDL),
+		down:        statements(queries.DropReversalsSQL),
+	},
+	{
+		version:     10,
+		description: "service providers",
+		up: statements(
+			queries.PostgresProvidersDDL,
+			queries.PostgresServicesProviderIdColumnSQL,
+			queries.LedgerEntriesSystemAccountIndexDDL,
+		),
+		down: statements(queries.PostgresDropProvidersSQL),
+	},
+	{
+		version:     11,
+		description: "service catalog",
+		up:          statements(queries.PostgresServiceCatalogColumnsSQL, queries.PostgresJournalPaymentColumnsSQL),
+		down:        statements(queries.PostgresDropServiceCatalogSQL),
+	},
+}
+
+type SchemaStatus struct {
+	Current int64
+	Latest  int64
+	Pending []int64
+}
+
+func LatestSchemaVersion() int64 {
+	return sqliteMigrations[len(sqliteMigrations)-1].version
+}
+
+// Migrate applies all pending migrations.
+func Migrate(db *sql.DB) (err error) {
+	return MigrateContext(context.Background(), db)
+}
+
+func MigrateContext(ctx context.Context, db *sql.DB) (err error) {
+	return MigrateToContext(ctx, LatestSchemaVersion(), db)
+}
+
+// MigrateTo applies or reverts migrations until the schema is at version.
+// Each migration runs in its own transaction together with its
+// schema_version row.
+func MigrateTo(version int64, db *sql.DB) (err error) {
+	return MigrateToContext(context.Background(), version, db)
+}
+
+func MigrateToContext(ctx context.Context, version int64, db *sql.DB) (err error) {
+	return NewSQLiteStore(db).migrateTo(ctx, version, clock())
+}
+
+func (receiver *SQLStore) MigrateTo(ctx context.Context, version int64) (err error) {
+	return receiver.migrateTo(ctx, version, time.Now)
+}
+
+// migrateTo is MigrateTo dating the rows migrations write with now.
+func (receiver *SQLStore) migrateTo(ctx context.Context, version int64, now func() time.Time) (err error) {
+	if version < 0 || version > LatestSchemaVersion() {
+		return fmt.Errorf("%w: %d", ErrUnknownSchemaVersion, version)
+	}
+
+	current, err := receiver.currentSchemaVersion(ctx)
+	if err != nil {
+		return err
+	}
+
+	migrations := receiver.dialect.migrations
+	for _, migration := range migrations {
+		if migration.version > current && migration.version <= version {
+			err = receiver.applyMigration(ctx, migration, true, now)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	for i := len(migrations) - 1; i >= 0; i-- {
+		migration := migrations[i]
+		if migration.version <= current && migration.version > version {
+			err = receiver.applyMigration(ctx, migration, false, now)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func GetSchemaStatus(db *sql.DB) (status SchemaStatus, err error) {
+	return GetSchemaStatusContext(context.Background(), db)
+}
+
+func GetSchemaStatusContext(ctx context.Context, db *sql.DB) (status SchemaStatus, err error) {
+	return NewSQLiteStore(db).GetSchemaStatus(ctx)
+}
+
+func (receiver *SQLStore) GetSchemaStatus(ctx context.Context) (status SchemaStatus, err error) {
+	status.Current, err = receiver.currentSchemaVersion(ctx)
+	if err != nil {
+		return SchemaStatus{}, err
+	}
+
+	status.Latest = LatestSchemaVersion()
+	for _, migration := range receiver.dialect.migrations {
+		if migration.version > status.Current {
+			status.Pending = append(status.Pending, migration.version)
+		}
+	}
+
+	return status, nil
+}
+
+func (receiver *SQLStore) currentSchemaVersion(ctx context.Context) (version int64, err error) {
+	_, err = receiver.db.ExecContext(ctx, receiver.dialect.SchemaVersionDDL)
+	if err != nil {
+		return 0, dbError(err)
+	}
+
+	err = receiver.db.QueryRowContext(ctx, receiver.dialect.GetSchemaVersionSQL).Scan(&version)
+	if err != nil {
+		return 0, queryError(receiver.dialect.GetSchemaVersionSQL, err)
+	}
+
+	if version > LatestSchemaVersion() {
+		return 0, fmt.Errorf("%w: database is at %d", ErrUnknownSchemaVersion, version)
+	}
+
+	return version, nil
+}
+
+func (receiver *SQLStore) applyMigration(ctx context.Context, migration migration, up bool, now func() time.Time) (err error) {
+	tx, err := receiver.begin(ctx, TxOptions{})
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+			err = fmt.Errorf("migration %d (%s): %w", migration.version, migration.description, err)
+			return
+		}
+		err = tx.Commit()
+	}()
+
+	if !up {
+		err = migration.down(ctx, tx, now)
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.exec(ctx, receiver.dialect.DeleteSchemaVersionSQL, sql.Named("version", migration.version))
+		if err != nil {
+			return dbError(err)
+		}
+		return nil
+	}
+
+	err = migration.up(ctx, tx, now)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.exec(
+		ctx,
+		receiver.dialect.AddSchemaVersionSQL,
+		sql.Named("version", migration.version),
+		sql.Named("description", migration.description),
+		sql.Named("applied_at", formatTime(now())),
+	)
+	if err != nil {
+		return dbError(err)
+	}
+	return nil
+}
+
+func statements(list ...string) migrationStep {
+	return func(ctx context.Context, tx *sqlTx, now func() time.Time) error {
+		for _, statement := range list {
+			_, err := tx.tx.ExecContext(ctx, statement)
+			if err != nil {
+				return dbError(err)
+			}
+		}
+		return nil
+	}
+}
+
+func steps(list ...migrationStep) migrationStep {
+	return func(ctx context.Context, tx *sqlTx, now func() time.Time) error {
+		for _, step := range list {
+			err := step(ctx, tx, now)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+// addColumn adds a column unless a release predating migrations already did.
+func addColumn(table, column, ddl string) migrationStep {
+	return func(ctx context.Context, tx *sqlTx, now func() time.Time) error {
+		var count int64
+		err := tx.tx.QueryRowContext(ctx, queries.ColumnExistSQL, table, column).Scan(&count)
+		if err != nil {
+			return queryError(queries.ColumnExistSQL, err)
+		}
+
+		if count > 0 {
+			return nil
+		}
+
+		_, err = tx.tx.ExecContext(ctx, ddl)
+		if err != nil {
+			return dbError(err)
+		}
+		return nil
+	}
+}
+
+// backfillLedger opens the ledger of accounts created before it existed.
+func backfillLedger(ctx context.Context, tx *sqlTx, now func() time.Time) (err error) {
+	rows, err := tx.query(ctx, tx.dialect.GetAccountsWithoutLedgerSQL)
+	if err != nil {
+		return queryError(tx.dialect.GetAccountsWithoutLedgerSQL, err)
+	}
+
+	var accounts []AccountWithClientId
+	for rows.Next() {
+		account := AccountWithClientId{}
+		err = rows.Scan(&account.Id, &account.Balance.Amount, &account.Balance.Currency)
+		if err != nil {
+			_ = rows.Close()
+			return dbError(err)
+		}
+		accounts = append(accounts, account)
+	}
+	if rows.Err() != nil {
+		_ = rows.Close()
+		return dbError(rows.Err())
+	}
+	err = rows.Close()
+	if err != nil {
+		return dbError(err)
+	}
+
+	for _, account := range accounts {
+		err = post(ctx, Posting{
+			Type:      systemOpening,
+			CreatedAt: now(),
+			Entries: []LedgerEntry{
+				accountEntry(account.Id, account.Balance),
+				systemEntry(systemOpening, negate(account.Balance)),
+			},
+		}, tx)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// migrateJournalDates rewrites journal dates stored in the legacy local time
+// format as UTC timestamps.
+func migrateJournalDates(ctx context.Context, tx *sqlTx, now func() time.Time) (err error) {
+	return rewriteJournalDates(ctx, queries.GetLegacyJournalDatesSQL, func(date string) (string, error) {
+		parsed, err := time.ParseInLocation(legacyJournalLayout, date, time.Local)
+		if err != nil {
+			return "", err
+		}
+		return formatTime(parsed), nil
+	}, tx)
+}
+
+func restoreLegacyJournalDates(ctx context.Context, tx *sqlTx, now func() time.Time) (err error) {
+	return rewriteJournalDates(ctx, queries.GetJournalDatesSQL, func(date string) (string, error) {
+		parsed, err := parseTime(date)
+		if err != nil {
+			return "", err
+		}
+		return parsed.In(time.Local).Format(legacyJournalLayout), nil
+	}, tx)
+}
+
+func rewriteJournalDates(ctx context.Context, query string, rewrite func(date string) (string, error), tx *sqlTx) (err error) {
+	rows, err := tx.query(ctx, query)
+	if err != nil {
+		return queryError(query, err)
+	}
+
+	dates := make(map[int64]string)
+	for rows.Next() {
+		var id int64
+		var date string
+		err = rows.Scan(&id, &date)
+		if err != nil {
+			_ = rows.Close()
+			return dbError(err)
+		}
+
+		dates[id], err = rewrite(date)
+		if err != nil {
+			_ = rows.Close()
+			return dbError(err)
+		}
+	}
+	if rows.Err() != nil {
+		_ = rows.Close()
+		return dbError(rows.Err())
+	}
+	err = rows.Close()
+	if err != nil {
+		return dbError(err)
+	}
+
+	for id, date := range dates {
+		_, err = tx.exec(
+			ctx,
+			queries.UpdateJournalDateSQL,
+			sql.Named("date", date),
+			sql.Named("id", id),
+		)
+		if err != nil {
+			return dbError(err)
+		}
+	}
+
+	return nil
+}
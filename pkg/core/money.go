@@ -0,0 +1,168 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+var (
+	ErrInvalidMoney        = errors.New("invalid money value")
+	ErrFractionalCents     = errors.New("amount has fractional minor units")
+	ErrCurrencyMismatch    = errors.New("currency mismatch")
+	ErrUnsupportedCurrency = errors.New("unsupported currency")
+)
+
+const DefaultCurrency = "TJS"
+
+// currencies maps the supported ISO 4217 codes to their number of minor
+// unit digits.
+var currencies = map[string]int{
+	"TJS": 2,
+	"USD": 2,
+	"EUR": 2,
+	"RUB": 2,
+	"GBP": 2,
+	"CHF": 2,
+	"CNY": 2,
+	"KZT": 2,
+	"UZS": 2,
+	"KGS": 2,
+	"JPY": 0,
+}
+
+func IsSupportedCurrency(currency string) bool {
+	_, ok := currencies[currency]
+	return ok
+}
+
+func minorUnitDigits(currency string) int {
+	digits, ok := currencies[currency]
+	if !ok {
+		return 2
+	}
+	return digits
+}
+
+// Money is an exact amount in minor units (cents, dirams) of Currency.
+type Money struct {
+	Amount   int64
+	Currency string
+}
+
+func NewMoney(amount int64, currency string) Money {
+	return Money{Amount: amount, Currency: currency}
+}
+
+// ParseMoney reads a decimal string such as "12.34" in major units. Values
+// with more fractional digits than the currency has minor units are rejected.
+func ParseMoney(value, currency string) (money Money, err error) {
+	if !IsSupportedCurrency(currency) {
+		return Money{}, ErrUnsupportedCurrency
+	}
+
+	digits := minorUnitDigits(currency)
+	value = strings.TrimSpace(value)
+	negative := strings.HasPrefix(value, "-")
+	if negative {
+		value = value[1:]
+	}
+
+	whole, fraction := value, ""
+	if dot := strings.IndexByte(value, '.'); dot >= 0 {
+		whole, fraction = value[:dot], value[dot+1:]
+	}
+
+	if whole == "" || !isDigits(whole) || !isDigits(fraction) {
+		return Money{}, ErrInvalidMoney
+	}
+
+	fraction = strings.TrimRight(fraction, "0")
+	if len(fraction) > digits {
+		return Money{}, ErrFractionalCents
+	}
+	fraction += strings.Repeat("0", digits-len(fraction))
+
+	major, err := strconv.ParseInt(whole, 10, 64)
+	if err != nil {
+		return Money{}, ErrInvalidMoney
+	}
+	minor := int64(0)
+	if fraction != "" {
+		minor, err = strconv.ParseInt(fraction, 10, 64)
+		if err != nil {
+			return Money{}, ErrInvalidMoney
+		}
+	}
+
+	scale := pow10(digits)
+	if major > (math.MaxInt64-minor)/scale {
+		return Money{}, ErrInvalidMoney
+	}
+
+	amount := major*scale + minor
+	if negative {
+		amount = -amount
+	}
+
+	return Money{Amount: amount, Currency: currency}, nil
+}
+
+// Decimal formats the amount in major units, e.g. "12.34".
+func (receiver Money) Decimal() string {
+	amount := receiver.Amount
+	sign := ""
+	if amount < 0 {
+		sign = "-"
+	}
+
+	digits := minorUnitDigits(receiver.Currency)
+	scale := pow10(digits)
+	major := amount / scale
+	minor := amount % scale
+	if major < 0 {
+		major = -major
+	}
+	if minor < 0 {
+		minor = -minor
+	}
+
+	if digits == 0 {
+		return fmt.Sprintf("%s%d", sign, major)
+	}
+	return fmt.Sprintf("%s%d.%0*d", sign, major, digits, minor)
+}
+
+func (receiver Money) String() string {
+	return receiver.Decimal() + " " + receiver.Currency
+}
+
+func isDigits(value string) bool {
+	for _, r := range value {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+func pow10(n int) int64 {
+	result := int64(1)
+	for i := 0; i < n; i++ {
+		result *= 10
+	}
+	return result
+}
+
+// checkAmount validates amounts handed to the money-moving functions.
+func checkAmount(amount Money) (err error) {
+	if !IsSupportedCurrency(amount.Currency) {
+		return ErrUnsupportedCurrency
+	}
+	if amount.Amount <= 0 {
+		return ErrInvalidAmount
+	}
+	return nil
+}
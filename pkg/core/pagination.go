@@ -0,0 +1,163 @@
+package core
+
+import (
+	"context"
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+)
+
+var (
+	ErrInvalidCursor = errors.New("invalid page cursor")
+	ErrInvalidLimit  = errors.New("page limit must be positive")
+)
+
+// PageRequest asks for Limit items starting at Cursor, which is empty for
+// the first page or a NextCursor/PreviousCursor of an earlier page. Total is
+// only counted when WithTotal is set.
+type PageRequest struct {
+	Cursor    string
+	Limit     int64
+	WithTotal bool
+}
+
+type ClientsPage struct {
+	Clients        []Client
+	NextCursor     string
+	PreviousCursor string
+	Total          int64
+}
+
+type JournalPage struct {
+	Journals       []Journal
+	NextCursor     string
+	PreviousCursor string
+	Total          int64
+}
+
+const (
+	clientsCursor = "clients"
+	journalCursor = "journal"
+)
+
+// cursor is the keyset position a page starts from: the page holds the
+// items after the key, or before it when Before is set.
+type cursor struct {
+	Kind   string `json:"k"`
+	Before bool   `json:"b,omitempty"`
+	Id     int64  `json:"i"`
+	Date   string `json:"d,omitempty"`
+}
+
+func encodeCursor(position cursor) string {
+	data, _ := json.Marshal(position)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeCursor(kind, token string) (position cursor, err error) {
+	if token == "" {
+		return cursor{Kind: kind}, nil
+	}
+
+	data, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return cursor{}, ErrInvalidCursor
+	}
+
+	err = json.Unmarshal(data, &position)
+	if err != nil || position.Kind != kind {
+		return cursor{}, ErrInvalidCursor
+	}
+
+	return position, nil
+}
+
+// pageCursors returns the cursors of the pages around one read from
+// position. more reports that the read found items beyond the page, first
+// and last are the keys of the page's edge items.
+func pageCursors(token string, position cursor, more bool, first, last cursor) (next, previous string) {
+	first.Before, last.Before = true, false
+	if position.Before {
+		next = encodeCursor(last)
+		if more {
+			previous = encodeCursor(first)
+		}
+		return next, previous
+	}
+
+	if more {
+		next = encodeCursor(last)
+	}
+	if token != "" {
+		previous = encodeCursor(first)
+	}
+	return next, previous
+}
+
+func GetListOfClientsPage(request PageRequest, db *sql.DB) (page ClientsPage, err error) {
+	return GetListOfClientsPageContext(context.Background(), request, db)
+}
+
+func GetListOfClientsPageContext(ctx context.Context, request PageRequest, db *sql.DB) (page ClientsPage, err error) {
+	return sqliteBank(db).GetListOfClientsPage(ctx, request)
+}
+
+func (receiver *Bank) GetListOfClientsPage(ctx context.Context, request PageRequest) (page ClientsPage, err error) {
+	err = receiver.checkPageRequest(request)
+	if err != nil {
+		return ClientsPage{}, err
+	}
+
+	position, err := decodeCursor(clientsCursor, request.Cursor)
+	if err != nil {
+		return ClientsPage{}, err
+	}
+
+	tx, err := receiver.store.Begin(ctx, TxOptions{ReadOnly: true})
+	if err != nil {
+		return ClientsPage{}, err
+	}
+
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+			return
+		}
+		err = tx.Commit()
+	}()
+
+	if position.Before {
+		page.Clients, err = tx.ListClientsBefore(ctx, position.Id, request.Limit+1)
+	} else {
+		page.Clients, err = tx.ListClientsAfter(ctx, position.Id, request.Limit+1)
+	}
+	if err != nil {
+		return ClientsPage{}, err
+	}
+
+	more := int64(len(page.Clients)) > request.Limit
+	if more {
+		page.Clients = page.Clients[:request.Limit]
+	}
+	if position.Before {
+		for i, j := 0, len(page.Clients)-1; i < j; i, j = i+1, j-1 {
+			page.Clients[i], page.Clients[j] = page.Clients[j], page.Clients[i]
+		}
+	}
+
+	if len(page.Clients) > 0 {
+		first := cursor{Kind: clientsCursor, Id: page.Clients[0].Id}
+		last := cursor{Kind: clientsCursor, Id: page.Clients[len(page.Clients)-1].Id}
+		page.NextCursor, page.PreviousCursor = pageCursors(request.Cursor, position, more, first, last)
+	}
+
+	if request.WithTotal {
+		page.Total, err = tx.CountClients(ctx)
+		if err != nil {
+			return ClientsPage{}, err
+		}
+	}
+
+	return page, nil
+}
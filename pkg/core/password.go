@@ -0,0 +1,157 @@
+package core
+
+import (
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"golang.org/x/crypto/bcrypt"
+	"strings"
+)
+
+var ErrUnknownHashScheme = errors.New("unknown password hash scheme")
+
+// PasswordHasher turns passwords into storable hashes and checks them back.
+// Hashes are stored as "{scheme}encoded", the scheme prefix tells which
+// hasher produced the value and lets older formats be upgraded on login.
+type PasswordHasher interface {
+	Scheme() string
+	Hash(password string) (hash string, err error)
+	Verify(hash, password string) (ok bool, err error)
+	NeedsRehash(hash string) bool
+}
+
+const BcryptScheme = "bcrypt"
+
+type BcryptHasher struct {
+	Cost int
+}
+
+func NewBcryptHasher(cost int) *BcryptHasher {
+	return &BcryptHasher{Cost: cost}
+}
+
+func (receiver *BcryptHasher) Scheme() string {
+	return BcryptScheme
+}
+
+func (receiver *BcryptHasher) Hash(password string) (hash string, err error) {
+	encoded, err := bcrypt.GenerateFromPassword([]byte(password), receiver.Cost)
+	if err != nil {
+		return "", err
+	}
+	return formatPasswordHash(BcryptScheme, string(encoded)), nil
+}
+
+func (receiver *BcryptHasher) Verify(hash, password string) (ok bool, err error) {
+	scheme, encoded := parsePasswordHash(hash)
+	if scheme != BcryptScheme {
+		return false, ErrUnknownHashScheme
+	}
+
+	err = bcrypt.CompareHashAndPassword([]byte(encoded), []byte(password))
+	if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func (receiver *BcryptHasher) NeedsRehash(hash string) bool {
+	scheme, encoded := parsePasswordHash(hash)
+	if scheme != BcryptScheme {
+		return true
+	}
+	cost, err := bcrypt.Cost([]byte(encoded))
+	if err != nil {
+		return true
+	}
+	return cost != receiver.Cost
+}
+
+var DefaultPasswordHasher PasswordHasher = NewBcryptHasher(bcrypt.DefaultCost)
+
+var passwordHasher = DefaultPasswordHasher
+
+func SetPasswordHasher(hasher PasswordHasher) {
+	settingsMu.Lock()
+	defer settingsMu.Unlock()
+	passwordHasher = hasher
+}
+
+// passwordHashers verify the stored hashes by their scheme, whichever hasher
+// makes new ones.
+var passwordHashers = map[string]PasswordHasher{BcryptScheme: DefaultPasswordHasher}
+
+// RegisterPasswordHasher lets banks verify hashes of the hasher's scheme and
+// upgrade them to the current hasher on login. Bcrypt hashes are always
+// verified. Banks made before it is called don't see the new scheme.
+func RegisterPasswordHasher(hasher PasswordHasher) {
+	settingsMu.Lock()
+	defer settingsMu.Unlock()
+	passwordHashers[hasher.Scheme()] = hasher
+}
+
+func formatPasswordHash(scheme, encoded string) string {
+	return fmt.Sprintf("{%s}%s", scheme, encoded)
+}
+
+func parsePasswordHash(hash string) (scheme, encoded string) {
+	if !isPasswordHash(hash) {
+		return "", hash
+	}
+	end := strings.Index(hash, "}")
+	return hash[1:end], hash[end+1:]
+}
+
+func isPasswordHash(value string) bool {
+	return strings.HasPrefix(value, "{") && strings.Index(value, "}") > 1
+}
+
+// passwordVerifier returns the hasher verifying hashes of scheme, the current
+// one for its own scheme.
+func (receiver *Bank) passwordVerifier(scheme string) (hasher PasswordHasher, ok bool) {
+	if scheme == receiver.hasher.Scheme() {
+		return receiver.hasher, true
+	}
+	hasher, ok = receiver.hashers[scheme]
+	return hasher, ok
+}
+
+// storedHasher returns the hasher that made the stored password. Values
+// without the prefix of a known scheme are legacy plaintext, even when they
+// look like "{scheme}encoded".
+func (receiver *Bank) storedHasher(stored string) (hasher PasswordHasher, ok bool) {
+	if !isPasswordHash(stored) {
+		return nil, false
+	}
+	scheme, _ := parsePasswordHash(stored)
+	return receiver.passwordVerifier(scheme)
+}
+
+// ensurePasswordHash keeps values hashed with a known scheme and hashes
+// anything else.
+func (receiver *Bank) ensurePasswordHash(password string) (hash string, err error) {
+	if _, ok := receiver.storedHasher(password); ok {
+		return password, nil
+	}
+	return receiver.hasher.Hash(password)
+}
+
+// verifyPassword accepts both hashed and legacy plaintext values and reports
+// whether the stored value should be replaced with a fresh hash, which it
+// should when it is plaintext or another hasher made it.
+func (receiver *Bank) verifyPassword(stored, password string) (ok, rehash bool, err error) {
+	hasher, hashed := receiver.storedHasher(stored)
+	if !hashed {
+		ok = subtle.ConstantTimeCompare([]byte(stored), []byte(password)) == 1
+		return ok, ok, nil
+	}
+
+	ok, err = hasher.Verify(stored, password)
+	if err != nil {
+		return false, false, err
+	}
+	return ok, ok && (hasher.Scheme() != receiver.hasher.Scheme() || receiver.hasher.NeedsRehash(stored)), nil
+}
@@ -0,0 +1,107 @@
+package core
+
+import (
+	"database/sql"
+	"errors"
+	"github.com/JAbduvohidov/apm-ibank-core/pkg/queries"
+	"github.com/lib/pq"
+	"strconv"
+	"strings"
+)
+
+var postgresDialect = &sqlDialect{
+	Dialect:    queries.Postgres,
+	migrations: postgresMigrations,
+	bind:       BindPostgres,
+	returning:  true,
+	isolation:  sql.LevelSerializable,
+	conflict:   postgresConflict,
+}
+
+// NewPostgresStore keeps the bank in a PostgreSQL database opened with the
+// github.com/lib/pq driver. Its transactions are serializable.
+func NewPostgresStore(db *sql.DB) *SQLStore {
+	return &SQLStore{db: db, dialect: postgresDialect}
+}
+
+// BindPostgres rewrites ? and :name placeholders to $1, $2, ... and orders
+// the arguments to match, the way the Postgres store runs the queries of
+// package queries. ? takes the next argument that isn't a sql.NamedArg, a
+// name used twice is bound once. Missing arguments are bound as nil.
+func BindPostgres(query string, args []interface{}) (string, []interface{}) {
+	var builder strings.Builder
+	var bound []interface{}
+	var positional []interface{}
+	for _, arg := range args {
+		if _, ok := arg.(sql.NamedArg); !ok {
+			positional = append(positional, arg)
+		}
+	}
+	named := make(map[string]int)
+	next := 0
+
+	for i := 0; i < len(query); i++ {
+		char := query[i]
+		switch {
+		case char == '\'':
+			end := strings.IndexByte(query[i+1:], '\'')
+			if end < 0 {
+				builder.WriteString(query[i:])
+				return builder.String(), bound
+			}
+			builder.WriteString(query[i : i+end+2])
+			i += end + 1
+		case char == '?':
+			var arg interface{}
+			if next < len(positional) {
+				arg = positional[next]
+			}
+			next++
+			bound = append(bound, arg)
+			builder.WriteString("$" + strconv.Itoa(len(bound)))
+		case char == ':' && i+1 < len(query) && query[i+1] == ':':
+			builder.WriteString("::")
+			i++
+		case char == ':' && i+1 < len(query) && isNameStart(query[i+1]):
+			end := i + 1
+			for end < len(query) && (isNameStart(query[end]) || query[end] >= '0' && query[end] <= '9') {
+				end++
+			}
+			name := query[i+1 : end]
+			number, ok := named[name]
+			if !ok {
+				bound = append(bound, namedValue(name, args))
+				number = len(bound)
+				named[name] = number
+			}
+			builder.WriteString("$" + strconv.Itoa(number))
+			i = end - 1
+		default:
+			builder.WriteByte(char)
+		}
+	}
+
+	return builder.String(), bound
+}
+
+// postgresConflict reports serialization failures and deadlocks.
+func postgresConflict(err error) bool {
+	var pqErr *pq.Error
+	if !errors.As(err, &pqErr) {
+		return false
+	}
+	return pqErr.Code == "40001" || pqErr.Code == "40P01"
+}
+
+func isNameStart(char byte) bool {
+	return char == '_' || char >= 'a' && char <= 'z' || char >= 'A' && char <= 'Z'
+}
+
+func namedValue(name string, args []interface{}) interface{} {
+	for _, arg := range args {
+		if named, ok := arg.(sql.NamedArg); ok && named.Name == name {
+			return named.Value
+		}
+	}
+	return nil
+}
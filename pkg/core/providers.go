@@ -0,0 +1,292 @@
+package core
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	ErrProviderExist    = errors.New("provider exits")
+	ErrProviderNotExist = errors.New("provider not found")
+)
+
+// Provider collects the payments for its services in Balance until they are
+// paid out.
+type Provider struct {
+	Id      int64
+	Name    string
+	Balance Money
+}
+
+// ProviderSettlement sums the payments a provider collected, the payments
+// refunded and the payouts made within a period. The balance of Provider is
+// the current one.
+type ProviderSettlement struct {
+	Provider  Provider
+	Payments  int64
+	Collected Money
+	Refunds   int64
+	Refunded  Money
+	Payouts   int64
+	PaidOut   Money
+}
+
+// The settlement account of a provider is the system ledger account named
+// by the prefix and the provider id.
+const providerAccountPrefix = "provider:"
+
+func providerAccount(providerId int64) string {
+	return providerAccountPrefix + strconv.FormatInt(providerId, 10)
+}
+
+// settlementProvider returns the provider owning the system account.
+func settlementProvider(systemAccount string) (providerId int64, ok bool) {
+	if !strings.HasPrefix(systemAccount, providerAccountPrefix) {
+		return 0, false
+	}
+	providerId, err := strconv.ParseInt(strings.TrimPrefix(systemAccount, providerAccountPrefix), 10, 64)
+	return providerId, err == nil
+}
+
+// creditProvider puts amount on the settlement account of the provider and
+// returns the ledger entry booking it.
+func creditProvider(ctx context.Context, providerId int64, amount Money, tx Tx) (entry LedgerEntry, err error) {
+	provider, err := tx.GetProvider(ctx, providerId)
+	if err != nil {
+		return LedgerEntry{}, err
+	}
+
+	if provider.Balance.Currency != amount.Currency {
+		return LedgerEntry{}, ErrCurrencyMismatch
+	}
+
+	err = tx.UpdateProviderBalance(ctx, providerId, amount.Amount)
+	if err != nil {
+		return LedgerEntry{}, err
+	}
+
+	return systemEntry(providerAccount(providerId), amount), nil
+}
+
+func AddProvider(name, currency string, db *sql.DB) (err error) {
+	return AddProviderContext(context.Background(), name, currency, db)
+}
+
+func AddProviderContext(ctx context.Context, name, currency string, db *sql.DB) (err error) {
+	return sqliteBank(db).AddProvider(ctx, name, currency)
+}
+
+// AddProvider opens a provider with an empty settlement account in currency.
+func (receiver *Bank) AddProvider(ctx context.Context, name, currency string) (err error) {
+	if !IsSupportedCurrency(currency) {
+		return ErrUnsupportedCurrency
+	}
+
+	tx, err := receiver.store.Begin(ctx, TxOptions{})
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+			return
+		}
+		err = tx.Commit()
+	}()
+
+	_, err = tx.GetProviderByName(ctx, name)
+	if err == nil {
+		return ErrProviderExist
+	}
+	if !errors.Is(err, ErrProviderNotExist) {
+		return err
+	}
+
+	_, err = tx.AddProvider(ctx, name, currency)
+	return err
+}
+
+func SetServiceProvider(nameOfService, nameOfProvider string, db *sql.DB) (err error) {
+	return SetServiceProviderContext(context.Background(), nameOfService, nameOfProvider, db)
+}
+
+func SetServiceProviderContext(ctx context.Context, nameOfService, nameOfProvider string, db *sql.DB) (err error) {
+	return sqliteBank(db).SetServiceProvider(ctx, nameOfService, nameOfProvider)
+}
+
+// SetServiceProvider makes payments for the service go to the provider from
+// now on.
+func (receiver *Bank) SetServiceProvider(ctx context.Context, nameOfService, nameOfProvider string) (err error) {
+	tx, err := receiver.store.Begin(ctx, TxOptions{})
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+			return
+		}
+		err = tx.Commit()
+	}()
+
+	provider, err := tx.GetProviderByName(ctx, nameOfProvider)
+	if err != nil {
+		return err
+	}
+
+	return tx.SetServiceProvider(ctx, nameOfService, provider.Id)
+}
+
+func GetListOfProviders(db *sql.DB) (providers []Provider, err error) {
+	return GetListOfProvidersContext(context.Background(), db)
+}
+
+func GetListOfProvidersContext(ctx context.Context, db *sql.DB) (providers []Provider, err error) {
+	return sqliteBank(db).GetListOfProviders(ctx)
+}
+
+func (receiver *Bank) GetListOfProviders(ctx context.Context) (providers []Provider, err error) {
+	tx, err := receiver.store.Begin(ctx, TxOptions{ReadOnly: true})
+	if err != nil {
+		return nil, err
+	}
+
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+			return
+		}
+		err = tx.Commit()
+	}()
+
+	return tx.ListProviders(ctx)
+}
+
+func PayOutProvider(nameOfProvider string, db *sql.DB) (payout Money, err error) {
+	return PayOutProviderContext(context.Background(), nameOfProvider, db)
+}
+
+func PayOutProviderContext(ctx context.Context, nameOfProvider string, db *sql.DB) (payout Money, err error) {
+	return sqliteBank(db).PayOutProvider(ctx, nameOfProvider)
+}
+
+// PayOutProvider sweeps the whole balance of the provider's settlement
+// account out of the bank and returns the amount paid out.
+func (receiver *Bank) PayOutProvider(ctx context.Context, nameOfProvider string) (payout Money, err error) {
+	err = receiver.retry(ctx, func() (err error) {
+		payout, err = receiver.payOutProvider(ctx, nameOfProvider)
+		return err
+	})
+	return payout, err
+}
+
+func (receiver *Bank) payOutProvider(ctx context.Context, nameOfProvider string) (payout Money, err error) {
+	tx, err := receiver.store.Begin(ctx, TxOptions{})
+	if err != nil {
+		return Money{}, err
+	}
+
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+			return
+		}
+		err = tx.Commit()
+	}()
+
+	provider, err := tx.GetProviderByName(ctx, nameOfProvider)
+	if err != nil {
+		return Money{}, err
+	}
+
+	payout = provider.Balance
+	if payout.Amount == 0 {
+		return payout, nil
+	}
+
+	err = tx.UpdateProviderBalance(ctx, provider.Id, -payout.Amount)
+	if err != nil {
+		return Money{}, err
+	}
+
+	err = receiver.post(ctx, systemPayouts, 0, []LedgerEntry{
+		systemEntry(providerAccount(provider.Id), negate(payout)),
+		systemEntry(systemPayouts, payout),
+	}, tx)
+	if err != nil {
+		return Money{}, err
+	}
+
+	return payout, nil
+}
+
+func GetSettlementReport(from, to time.Time, db *sql.DB) (settlements []ProviderSettlement, err error) {
+	return GetSettlementReportContext(context.Background(), from, to, db)
+}
+
+func GetSettlementReportContext(ctx context.Context, from, to time.Time, db *sql.DB) (settlements []ProviderSettlement, err error) {
+	return sqliteBank(db).GetSettlementReport(ctx, from, to)
+}
+
+// GetSettlementReport settles every provider over the period from from until
+// to.
+func (receiver *Bank) GetSettlementReport(ctx context.Context, from, to time.Time) (settlements []ProviderSettlement, err error) {
+	tx, err := receiver.store.Begin(ctx, TxOptions{ReadOnly: true})
+	if err != nil {
+		return nil, err
+	}
+
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+			return
+		}
+		err = tx.Commit()
+	}()
+
+	providers, err := tx.ListProviders(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, provider := range providers {
+		totals, err := tx.GetSystemAccountTotals(ctx, providerAccount(provider.Id), from, to)
+		if err != nil {
+			return nil, err
+		}
+
+		currency := provider.Balance.Currency
+		settlement := ProviderSettlement{
+			Provider:  provider,
+			Collected: NewMoney(0, currency),
+			Refunded:  NewMoney(0, currency),
+			PaidOut:   NewMoney(0, currency),
+		}
+		for _, total := range totals {
+			if total.Amount.Currency != currency {
+				continue
+			}
+
+			switch total.Type {
+			case Service:
+				settlement.Payments += total.Count
+				settlement.Collected.Amount += total.Amount.Amount
+			case Reversal:
+				settlement.Refunds += total.Count
+				settlement.Refunded.Amount -= total.Amount.Amount
+			case systemPayouts:
+				settlement.Payouts += total.Count
+				settlement.PaidOut.Amount -= total.Amount.Amount
+			}
+		}
+		settlements = append(settlements, settlement)
+	}
+
+	return settlements, nil
+}
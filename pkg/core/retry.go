@@ -0,0 +1,58 @@
+package core
+
+import (
+	"context"
+	"math/rand"
+	"time"
+)
+
+// RetryPolicy describes how often an operation whose transaction collided
+// with a concurrent one, such as a busy SQLite database or a Postgres
+// serialization failure, is run again. The wait starts at Delay and doubles
+// after every attempt. MaxAttempts of one or less disables retries.
+type RetryPolicy struct {
+	MaxAttempts int
+	Delay       time.Duration
+}
+
+var DefaultRetryPolicy = RetryPolicy{
+	MaxAttempts: 5,
+	Delay:       10 * time.Millisecond,
+}
+
+var retryPolicy = DefaultRetryPolicy
+
+func SetRetryPolicy(policy RetryPolicy) {
+	settingsMu.Lock()
+	defer settingsMu.Unlock()
+	retryPolicy = policy
+}
+
+// conflictStore is implemented by stores whose transactions can collide.
+type conflictStore interface {
+	conflict(err error) bool
+}
+
+// retry runs operation, each run in a transaction of its own, until it
+// succeeds, fails for another reason than a collision or runs out of
+// attempts.
+func (receiver *Bank) retry(ctx context.Context, operation func() error) (err error) {
+	store, ok := receiver.store.(conflictStore)
+	delay := receiver.retryPolicy.Delay
+	for attempt := 1; ; attempt++ {
+		err = operation()
+		if err == nil || !ok || !store.conflict(err) || attempt >= receiver.retryPolicy.MaxAttempts {
+			return err
+		}
+
+		// The jitter keeps colliding operations from waking up together.
+		timer := time.NewTimer(delay + time.Duration(rand.Int63n(int64(delay)+1)))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		case <-timer.C:
+		}
+		delay *= 2
+	}
+}
@@ -0,0 +1,171 @@
+package core
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+var (
+	ErrJournalNotFound            = errors.New("journal entry not found")
+	ErrPostingNotFound            = errors.New("posting not found")
+	ErrReversalNotFound           = errors.New("reversal not found")
+	ErrAlreadyReversed            = errors.New("journal entry is already reversed")
+	ErrNotReversible              = errors.New("journal entry can't be reversed")
+	ErrRecipientInsufficientFunds = fmt.Errorf("recipient: %w", ErrInsufficientFunds)
+)
+
+// JournalReversal links a reversed journal entry to the entry journaling its
+// reversal.
+type JournalReversal struct {
+	JournalId         int64
+	ReversalJournalId int64
+	Reason            string
+	CreatedAt         time.Time
+}
+
+func ReverseTransaction(journalId int64, reason string, db *sql.DB) (reversal JournalReversal, err error) {
+	return ReverseTransactionContext(context.Background(), journalId, reason, db)
+}
+
+func ReverseTransactionContext(ctx context.Context, journalId int64, reason string, db *sql.DB) (reversal JournalReversal, err error) {
+	return sqliteBank(db).ReverseTransaction(ctx, journalId, reason)
+}
+
+// ReverseTransaction undoes the payment, transfer or conversion journaled
+// under the outgoing entry journalId. The amounts originally moved go back,
+// conversions at their original rate, payments with their fee, and the
+// reversal is journaled for both sides. A recipient who no longer has the
+// money, like a provider that was paid out, fails the reversal with
+// ErrRecipientInsufficientFunds.
+func (receiver *Bank) ReverseTransaction(ctx context.Context, journalId int64, reason string) (reversal JournalReversal, err error) {
+	err = receiver.retry(ctx, func() (err error) {
+		reversal, err = receiver.reverseTransaction(ctx, journalId, reason)
+		return err
+	})
+	return reversal, err
+}
+
+func (receiver *Bank) reverseTransaction(ctx context.Context, journalId int64, reason string) (reversal JournalReversal, err error) {
+	tx, err := receiver.store.Begin(ctx, TxOptions{})
+	if err != nil {
+		return JournalReversal{}, err
+	}
+
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+			return
+		}
+		err = tx.Commit()
+	}()
+
+	clientId, original, err := tx.GetJournal(ctx, journalId)
+	if err != nil {
+		return JournalReversal{}, err
+	}
+
+	if original.Direction != Outgoing || original.Type == Reversal {
+		return JournalReversal{}, ErrNotReversible
+	}
+
+	_, err = tx.GetReversal(ctx, journalId)
+	if err == nil {
+		return JournalReversal{}, ErrAlreadyReversed
+	}
+	if !errors.Is(err, ErrReversalNotFound) {
+		return JournalReversal{}, err
+	}
+
+	posting, err := tx.GetJournalPosting(ctx, journalId)
+	if errors.Is(err, ErrPostingNotFound) {
+		return JournalReversal{}, ErrNotReversible
+	}
+	if err != nil {
+		return JournalReversal{}, err
+	}
+
+	entries := make([]LedgerEntry, len(posting.Entries))
+	var returned Money
+	for index, entry := range posting.Entries {
+		entry.Amount = negate(entry.Amount)
+		entries[index] = entry
+		if entry.AccountId != 0 && entry.AccountId == original.CounterpartyAccountId {
+			returned = negate(entry.Amount)
+		}
+	}
+
+	// All balances are checked before any is changed, the entries of the
+	// recipient, a client or a provider, are the ones that take money.
+	for _, entry := range entries {
+		if entry.Amount.Amount >= 0 {
+			continue
+		}
+
+		balance, held, err := heldBalance(ctx, entry, tx)
+		if err != nil {
+			return JournalReversal{}, err
+		}
+		if held && balance.Amount < -entry.Amount.Amount {
+			return JournalReversal{}, ErrRecipientInsufficientFunds
+		}
+	}
+
+	for _, entry := range entries {
+		err = applyEntry(ctx, entry, tx)
+		if err != nil {
+			return JournalReversal{}, err
+		}
+	}
+
+	reversal = JournalReversal{JournalId: journalId, Reason: reason, CreatedAt: receiver.now()}
+	if original.CounterpartyAccountId == 0 {
+		reversal.ReversalJournalId, err = receiver.addToJournal(ctx, clientId, Journal{
+			Type:          Reversal,
+			Direction:     Incoming,
+			AccountId:     original.AccountId,
+			Counterparty:  original.Counterparty,
+			TransferredTo: original.TransferredTo,
+			Amount:        original.Amount,
+			Reference:     original.Reference,
+			Fee:           original.Fee,
+		}, tx)
+	} else {
+		reversal.ReversalJournalId, err = receiver.addReversalToJournal(ctx, original, returned, tx)
+	}
+	if err != nil {
+		return JournalReversal{}, err
+	}
+
+	err = receiver.post(ctx, Reversal, reversal.ReversalJournalId, entries, tx)
+	if err != nil {
+		return JournalReversal{}, err
+	}
+
+	err = tx.AddReversal(ctx, reversal)
+	if err != nil {
+		return JournalReversal{}, err
+	}
+
+	return reversal, nil
+}
+
+// addReversalToJournal journals the reversal of a transfer or a conversion
+// as one going the other way: the recipient sends returned, the sender gets
+// the original amount back.
+func (receiver *Bank) addReversalToJournal(ctx context.Context, original Journal, returned Money, tx Tx) (journalId int64, err error) {
+	sender, err := getAccountState(ctx, original.AccountId, tx)
+	if err != nil {
+		return 0, err
+	}
+
+	recipient, err := getAccountState(ctx, original.CounterpartyAccountId, tx)
+	if err != nil {
+		return 0, err
+	}
+
+	return receiver.addTransferToJournal(ctx, Reversal, strconv.FormatInt(sender.phoneNumber, 10), recipient, sender, returned, original.Amount, original.Rate, tx)
+}
@@ -0,0 +1,218 @@
+package core
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"time"
+)
+
+var (
+	ErrSessionNotFound = errors.New("session not found")
+	ErrSessionExpired  = errors.New("session expired")
+	ErrSessionRevoked  = errors.New("session revoked")
+)
+
+// Session is an authenticated client. Token is the opaque value handed to
+// the caller, only its hash is kept in the database.
+type Session struct {
+	Id        int64
+	Token     string
+	ClientId  int64
+	Device    string
+	CreatedAt time.Time
+	ExpiresAt time.Time
+}
+
+const DefaultSessionTTL = 30 * time.Minute
+
+var sessionTTL = DefaultSessionTTL
+
+func SetSessionTTL(ttl time.Duration) {
+	settingsMu.Lock()
+	defer settingsMu.Unlock()
+	sessionTTL = ttl
+}
+
+// StartSession authenticates the client like Authenticate does and issues a
+// new session token for the given device.
+func StartSession(login, password, device string, db *sql.DB) (session Session, err error) {
+	return StartSessionContext(context.Background(), login, password, device, db)
+}
+
+func StartSessionContext(ctx context.Context, login, password, device string, db *sql.DB) (session Session, err error) {
+	return sqliteBank(db).StartSession(ctx, login, password, device)
+}
+
+func (receiver *Bank) StartSession(ctx context.Context, login, password, device string) (session Session, err error) {
+	result, err := receiver.Authenticate(ctx, login, password)
+	if err != nil {
+		return Session{}, err
+	}
+
+	token, err := newSessionToken()
+	if err != nil {
+		return Session{}, err
+	}
+
+	current := receiver.now()
+	session = Session{
+		Token:     token,
+		ClientId:  result.ClientId,
+		Device:    device,
+		CreatedAt: current.UTC(),
+		ExpiresAt: current.Add(receiver.sessionTTL).UTC(),
+	}
+
+	tx, err := receiver.store.Begin(ctx, TxOptions{})
+	if err != nil {
+		return Session{}, err
+	}
+
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+			return
+		}
+		err = tx.Commit()
+	}()
+
+	session.Id, err = tx.AddSession(ctx, hashSessionToken(token), session)
+	if err != nil {
+		return Session{}, err
+	}
+
+	return session, nil
+}
+
+func ValidateSession(token string, db *sql.DB) (session Session, err error) {
+	return ValidateSessionContext(context.Background(), token, db)
+}
+
+func ValidateSessionContext(ctx context.Context, token string, db *sql.DB) (session Session, err error) {
+	return sqliteBank(db).ValidateSession(ctx, token)
+}
+
+func (receiver *Bank) ValidateSession(ctx context.Context, token string) (session Session, err error) {
+	tx, err := receiver.store.Begin(ctx, TxOptions{ReadOnly: true})
+	if err != nil {
+		return Session{}, err
+	}
+
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+			return
+		}
+		err = tx.Commit()
+	}()
+
+	return receiver.getSession(ctx, token, tx)
+}
+
+func Logout(token string, db *sql.DB) (err error) {
+	return LogoutContext(context.Background(), token, db)
+}
+
+func LogoutContext(ctx context.Context, token string, db *sql.DB) (err error) {
+	return sqliteBank(db).Logout(ctx, token)
+}
+
+func (receiver *Bank) Logout(ctx context.Context, token string) (err error) {
+	tx, err := receiver.store.Begin(ctx, TxOptions{})
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+			return
+		}
+		err = tx.Commit()
+	}()
+
+	return tx.RevokeSession(ctx, hashSessionToken(token), receiver.now())
+}
+
+func RevokeAllSessions(login string, db *sql.DB) (err error) {
+	return RevokeAllSessionsContext(context.Background(), login, db)
+}
+
+func RevokeAllSessionsContext(ctx context.Context, login string, db *sql.DB) (err error) {
+	return sqliteBank(db).RevokeAllSessions(ctx, login)
+}
+
+func (receiver *Bank) RevokeAllSessions(ctx context.Context, login string) (err error) {
+	tx, err := receiver.store.Begin(ctx, TxOptions{})
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+			return
+		}
+		err = tx.Commit()
+	}()
+
+	client, err := tx.GetClientByLogin(ctx, login)
+	if err != nil {
+		return err
+	}
+
+	err = tx.RevokeClientSessions(ctx, client.Id, receiver.now())
+	if err != nil {
+		return err
+	}
+
+	receiver.logger.Printf("all sessions of client %d revoked", client.Id)
+	return nil
+}
+
+// sessionClientId validates the session inside tx so the money-moving calls
+// see the same state they are about to change.
+func (receiver *Bank) sessionClientId(ctx context.Context, session Session, tx Tx) (clientId int64, err error) {
+	session, err = receiver.getSession(ctx, session.Token, tx)
+	if err != nil {
+		return 0, err
+	}
+	return session.ClientId, nil
+}
+
+func (receiver *Bank) getSession(ctx context.Context, token string, tx Tx) (session Session, err error) {
+	session, revoked, err := tx.GetSession(ctx, hashSessionToken(token))
+	if err != nil {
+		return Session{}, err
+	}
+
+	if revoked {
+		return Session{}, ErrSessionRevoked
+	}
+
+	if !receiver.now().Before(session.ExpiresAt) {
+		return Session{}, ErrSessionExpired
+	}
+
+	session.Token = token
+	return session, nil
+}
+
+func newSessionToken() (token string, err error) {
+	buf := make([]byte, 32)
+	_, err = rand.Read(buf)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(buf), nil
+}
+
+func hashSessionToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
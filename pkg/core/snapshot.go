@@ -0,0 +1,91 @@
+package core
+
+import (
+	"context"
+	"database/sql"
+)
+
+// Snapshot is the import/export contract for clients, accounts and ATMs.
+// Balances are exact Money values and passwords are exported as stored
+// hashes, so importing an exported snapshot into an empty database
+// reproduces the same rows.
+type Snapshot struct {
+	Clients  []Client
+	Accounts []AccountWithClientId
+	ATMs     []ATM
+}
+
+func ExportSnapshot(db *sql.DB) (snapshot Snapshot, err error) {
+	return ExportSnapshotContext(context.Background(), db)
+}
+
+func ExportSnapshotContext(ctx context.Context, db *sql.DB) (snapshot Snapshot, err error) {
+	return sqliteBank(db).ExportSnapshot(ctx)
+}
+
+func (receiver *Bank) ExportSnapshot(ctx context.Context) (snapshot Snapshot, err error) {
+	tx, err := receiver.store.Begin(ctx, TxOptions{ReadOnly: true})
+	if err != nil {
+		return Snapshot{}, err
+	}
+
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+			return
+		}
+		err = tx.Commit()
+	}()
+
+	snapshot.Clients, err = tx.ListClients(ctx)
+	if err != nil {
+		return Snapshot{}, err
+	}
+
+	snapshot.Accounts, err = tx.ListAccounts(ctx)
+	if err != nil {
+		return Snapshot{}, err
+	}
+
+	snapshot.ATMs, err = tx.ListATMs(ctx)
+	if err != nil {
+		return Snapshot{}, err
+	}
+
+	return snapshot, nil
+}
+
+func ImportSnapshot(snapshot Snapshot, db *sql.DB) (err error) {
+	return ImportSnapshotContext(context.Background(), snapshot, db)
+}
+
+func ImportSnapshotContext(ctx context.Context, snapshot Snapshot, db *sql.DB) (err error) {
+	return sqliteBank(db).ImportSnapshot(ctx, snapshot)
+}
+
+func (receiver *Bank) ImportSnapshot(ctx context.Context, snapshot Snapshot) (err error) {
+	tx, err := receiver.store.Begin(ctx, TxOptions{})
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+			return
+		}
+		err = tx.Commit()
+	}()
+
+	err = receiver.importClients(ctx, snapshot.Clients, tx)
+	if err != nil {
+		return err
+	}
+
+	err = receiver.importAccounts(ctx, snapshot.Accounts, tx)
+	if err != nil {
+		return err
+	}
+
+	return importATMs(ctx, snapshot.ATMs, tx)
+}
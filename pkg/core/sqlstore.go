@@ -0,0 +1,1326 @@
+package core
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/JAbduvohidov/apm-ibank-core/pkg/queries"
+	"github.com/mattn/go-sqlite3"
+	"strings"
+	"time"
+)
+
+// SQLStore keeps the bank in a SQL database, see Migrate for its schema.
+type SQLStore struct {
+	db      *sql.DB
+	dialect *sqlDialect
+}
+
+// sqlDialect adapts SQLStore to a database engine.
+type sqlDialect struct {
+	queries.Dialect
+	migrations []migration
+	// bind rewrites the placeholders of a statement for the driver, it is
+	// nil when the driver takes them as they are.
+	bind func(query string, args []interface{}) (string, []interface{})
+	// returning is set when inserts return the new id as a row.
+	returning bool
+	isolation sql.IsolationLevel
+	// conflict tells the errors of transactions that collided with
+	// concurrent ones and may succeed when run again.
+	conflict func(err error) bool
+}
+
+// sqliteDialect starts the transactions that write with the write lock
+// taken, so they wait for each other up to the busy timeout of the
+// connection instead of failing when they try to write. Read-only ones are
+// deferred and, in WAL mode, read alongside the writer.
+var sqliteDialect = &sqlDialect{
+	Dialect:    queries.SQLite,
+	migrations: sqliteMigrations,
+	conflict:   sqliteConflict,
+}
+
+func sqliteConflict(err error) bool {
+	var sqliteErr sqlite3.Error
+	if !errors.As(err, &sqliteErr) {
+		return false
+	}
+	return sqliteErr.Code == sqlite3.ErrBusy || sqliteErr.Code == sqlite3.ErrLocked
+}
+
+func NewSQLiteStore(db *sql.DB) *SQLStore {
+	return &SQLStore{db: db, dialect: sqliteDialect}
+}
+
+func (receiver *SQLStore) Init(ctx context.Context) error {
+	return receiver.MigrateTo(ctx, LatestSchemaVersion())
+}
+
+func (receiver *SQLStore) initAt(ctx context.Context, now func() time.Time) error {
+	return receiver.migrateTo(ctx, LatestSchemaVersion(), now)
+}
+
+func (receiver *SQLStore) Begin(ctx context.Context, options TxOptions) (Tx, error) {
+	return receiver.begin(ctx, options)
+}
+
+func (receiver *SQLStore) begin(ctx context.Context, options TxOptions) (*sqlTx, error) {
+	if !options.ReadOnly && receiver.dialect.BeginWriteSQL != "" {
+		tx, err := beginConnTx(ctx, receiver.db, receiver.dialect.BeginWriteSQL)
+		if err != nil {
+			return nil, err
+		}
+		return &sqlTx{tx: tx, dialect: receiver.dialect}, nil
+	}
+
+	tx, err := receiver.db.BeginTx(ctx, &sql.TxOptions{Isolation: receiver.dialect.isolation, ReadOnly: options.ReadOnly})
+	if err != nil {
+		return nil, dbError(err)
+	}
+
+	return &sqlTx{tx: tx, dialect: receiver.dialect}, nil
+}
+
+// sqlConn runs the statements of a sqlTx, it is a *sql.Tx or a *connTx.
+type sqlConn interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+	Commit() error
+	Rollback() error
+}
+
+// connTx is a transaction begun by a statement of its own on a connection
+// taken from the pool, for transactions the driver can't begin. Like a
+// *sql.Tx it can't be committed once ctx is done.
+type connTx struct {
+	*sql.Conn
+	ctx  context.Context
+	done bool
+}
+
+func beginConnTx(ctx context.Context, db *sql.DB, begin string) (*connTx, error) {
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return nil, dbError(err)
+	}
+
+	_, err = conn.ExecContext(ctx, begin)
+	if err != nil {
+		_ = conn.Close()
+		return nil, queryError(begin, err)
+	}
+
+	return &connTx{Conn: conn, ctx: ctx}, nil
+}
+
+func (receiver *connTx) Commit() error {
+	if receiver.done {
+		return sql.ErrTxDone
+	}
+
+	err := receiver.ctx.Err()
+	if err != nil {
+		_ = receiver.Rollback()
+		return err
+	}
+
+	receiver.done = true
+	_, err = receiver.Conn.ExecContext(context.Background(), queries.CommitSQL)
+	if err != nil {
+		_, _ = receiver.Conn.ExecContext(context.Background(), queries.RollbackSQL)
+	}
+
+	closeErr := receiver.Conn.Close()
+	if err != nil {
+		return queryError(queries.CommitSQL, err)
+	}
+	return closeErr
+}
+
+func (receiver *connTx) Rollback() error {
+	if receiver.done {
+		return sql.ErrTxDone
+	}
+	receiver.done = true
+
+	_, err := receiver.Conn.ExecContext(context.Background(), queries.RollbackSQL)
+	closeErr := receiver.Conn.Close()
+	if err != nil {
+		return queryError(queries.RollbackSQL, err)
+	}
+	return closeErr
+}
+
+func (receiver *SQLStore) conflict(err error) bool {
+	return receiver.dialect.conflict != nil && receiver.dialect.conflict(err)
+}
+
+type sqlTx struct {
+	tx      sqlConn
+	dialect *sqlDialect
+}
+
+func (receiver *sqlTx) Commit() error {
+	return receiver.tx.Commit()
+}
+
+func (receiver *sqlTx) Rollback() error {
+	return receiver.tx.Rollback()
+}
+
+func (receiver *sqlTx) bind(query string, args []interface{}) (string, []interface{}) {
+	if receiver.dialect.bind == nil {
+		return query, args
+	}
+	return receiver.dialect.bind(query, args)
+}
+
+func (receiver *sqlTx) exec(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	query, args = receiver.bind(query, args)
+	return receiver.tx.ExecContext(ctx, query, args...)
+}
+
+func (receiver *sqlTx) query(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	query, args = receiver.bind(query, args)
+	return receiver.tx.QueryContext(ctx, query, args...)
+}
+
+func (receiver *sqlTx) queryRow(ctx context.Context, query string, args ...interface{}) *sql.Row {
+	query, args = receiver.bind(query, args)
+	return receiver.tx.QueryRowContext(ctx, query, args...)
+}
+
+// insert runs an INSERT into a table with generated ids and returns the id
+// of the new row.
+func (receiver *sqlTx) insert(ctx context.Context, query string, args ...interface{}) (id int64, err error) {
+	if !receiver.dialect.returning {
+		return lastInsertId(receiver.exec(ctx, query, args...))
+	}
+
+	err = receiver.queryRow(ctx, query, args...).Scan(&id)
+	if err != nil {
+		return 0, err
+	}
+	return id, nil
+}
+
+// syncId lets the id generator of table catch up with rows saved with
+// explicit ids.
+func (receiver *sqlTx) syncId(ctx context.Context, table string) error {
+	if receiver.dialect.SyncIdSQL == "" {
+		return nil
+	}
+
+	query := fmt.Sprintf(receiver.dialect.SyncIdSQL, table)
+	_, err := receiver.exec(ctx, query)
+	if err != nil {
+		return queryError(query, err)
+	}
+	return nil
+}
+
+func (receiver *sqlTx) AddClient(ctx context.Context, client Client) (id int64, err error) {
+	return receiver.insert(
+		ctx,
+		receiver.dialect.AddClientSQL,
+		sql.Named("name", client.Name),
+		sql.Named("login", client.Login),
+		sql.Named("password", client.Password),
+		sql.Named("phone_number", client.PhoneNumber),
+	)
+}
+
+func (receiver *sqlTx) getClient(ctx context.Context, query string, arg interface{}, notFound error) (client Client, err error) {
+	err = receiver.queryRow(ctx, query, arg).Scan(
+		&client.Id, &client.Name, &client.Login, &client.Password, &client.PhoneNumber, &client.Status)
+	if errors.Is(err, sql.ErrNoRows) {
+		return Client{}, notFound
+	}
+	if err != nil {
+		return Client{}, queryError(query, err)
+	}
+	return client, nil
+}
+
+func (receiver *sqlTx) GetClient(ctx context.Context, id int64) (client Client, err error) {
+	return receiver.getClient(ctx, receiver.dialect.GetClientSQL, id, ErrClientNotExist)
+}
+
+func (receiver *sqlTx) GetClientByLogin(ctx context.Context, login string) (client Client, err error) {
+	return receiver.getClient(ctx, receiver.dialect.GetClientByLoginSQL, login, ErrLoginNotFound)
+}
+
+func (receiver *sqlTx) GetClientByPhoneNumber(ctx context.Context, phoneNumber int64) (client Client, err error) {
+	return receiver.getClient(ctx, receiver.dialect.GetClientByPhoneNumberSQL, phoneNumber, ErrPhoneNumberNotExist)
+}
+
+func (receiver *sqlTx) SetClientPassword(ctx context.Context, login, password string) error {
+	_, err := receiver.exec(ctx, receiver.dialect.UpdateClientPasswordSQL,
+		sql.Named("password", password),
+		sql.Named("login", login),
+	)
+	if err != nil {
+		return queryError(receiver.dialect.UpdateClientPasswordSQL, err)
+	}
+	return nil
+}
+
+func (receiver *sqlTx) SetClientStatus(ctx context.Context, id int64, status string) error {
+	_, err := receiver.exec(
+		ctx,
+		receiver.dialect.ChangeClientStatusByIdSQL,
+		sql.Named("status", status),
+		sql.Named("id", id),
+	)
+	if err != nil {
+		return dbError(err)
+	}
+	return nil
+}
+
+func (receiver *sqlTx) SaveClient(ctx context.Context, client Client) error {
+	_, err := receiver.exec(
+		ctx,
+		receiver.dialect.UpdateListOfClientsSQL,
+		sql.Named("id", client.Id),
+		sql.Named("name", client.Name),
+		sql.Named("login", client.Login),
+		sql.Named("password", client.Password),
+		sql.Named("phone_number", client.PhoneNumber),
+		sql.Named("status", client.Status),
+	)
+	if err != nil {
+		return err
+	}
+	return receiver.syncId(ctx, "clients")
+}
+
+func (receiver *sqlTx) listClients(ctx context.Context, query string, args ...interface{}) (clients []Client, err error) {
+	rows, err := receiver.query(ctx, query, args...)
+	if err != nil {
+		return nil, queryError(query, err)
+	}
+	defer func() {
+		if innerErr := rows.Close(); innerErr != nil {
+			clients, err = nil, dbError(innerErr)
+		}
+	}()
+
+	for rows.Next() {
+		client := Client{}
+		err = rows.Scan(&client.Id, &client.Name, &client.Login, &client.Password, &client.PhoneNumber, &client.Status)
+		if err != nil {
+			return nil, dbError(err)
+		}
+		clients = append(clients, client)
+	}
+	if rows.Err() != nil {
+		return nil, dbError(rows.Err())
+	}
+
+	return clients, nil
+}
+
+func (receiver *sqlTx) ListClients(ctx context.Context) (clients []Client, err error) {
+	return receiver.listClients(ctx, receiver.dialect.GetListOfClientsSQL)
+}
+
+func (receiver *sqlTx) ListClientsByName(ctx context.Context, limit, offset int64) (clients []Client, err error) {
+	return receiver.listClients(ctx, receiver.dialect.GetListOfClientsFormattedSQL, limit, offset)
+}
+
+func (receiver *sqlTx) ListClientsAfter(ctx context.Context, id, limit int64) (clients []Client, err error) {
+	return receiver.listClients(ctx, receiver.dialect.GetClientsAfterSQL, id, limit)
+}
+
+func (receiver *sqlTx) ListClientsBefore(ctx context.Context, id, limit int64) (clients []Client, err error) {
+	return receiver.listClients(ctx, receiver.dialect.GetClientsBeforeSQL, id, limit)
+}
+
+func (receiver *sqlTx) CountClients(ctx context.Context) (count int64, err error) {
+	err = receiver.queryRow(ctx, receiver.dialect.CountClientsSQL).Scan(&count)
+	if err != nil {
+		return 0, queryError(receiver.dialect.CountClientsSQL, err)
+	}
+	return count, nil
+}
+
+func (receiver *sqlTx) SearchClientsByName(ctx context.Context, name string) (clients []Client, err error) {
+	return receiver.listClients(ctx, receiver.dialect.SearchClientByName, "%"+name+"%")
+}
+
+func (receiver *sqlTx) SearchClientsByPhoneNumber(ctx context.Context, phoneNumber string) (clients []Client, err error) {
+	return receiver.listClients(ctx, receiver.dialect.SearchClientByPhoneNumber, "%"+phoneNumber+"%")
+}
+
+func (receiver *sqlTx) AddAccount(ctx context.Context, clientId int64, balance Money) (id int64, err error) {
+	return receiver.insert(
+		ctx,
+		receiver.dialect.AddAccountSQL,
+		sql.Named("client_id", clientId),
+		sql.Named("balance", balance.Amount),
+		sql.Named("currency", balance.Currency),
+	)
+}
+
+func (receiver *sqlTx) GetAccount(ctx context.Context, id int64) (account AccountWithClientId, err error) {
+	err = receiver.queryRow(ctx, receiver.dialect.GetAccountSQL, id).Scan(
+		&account.Id, &account.ClientId, &account.Balance.Amount, &account.Balance.Currency)
+	if errors.Is(err, sql.ErrNoRows) {
+		return AccountWithClientId{}, ErrAccountNotExist
+	}
+	if err != nil {
+		return AccountWithClientId{}, queryError(receiver.dialect.GetAccountSQL, err)
+	}
+	return account, nil
+}
+
+func (receiver *sqlTx) FindClientAccount(ctx context.Context, clientId int64, currency string) (id int64, err error) {
+	err = receiver.queryRow(
+		ctx,
+		receiver.dialect.GetClientAccountIdByCurrencySQL,
+		clientId,
+		currency,
+	).Scan(&id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, ErrAccountNotExist
+	}
+	if err != nil {
+		return 0, queryError(receiver.dialect.GetClientAccountIdByCurrencySQL, err)
+	}
+	return id, nil
+}
+
+func (receiver *sqlTx) ListClientAccounts(ctx context.Context, clientId int64) (accounts []Account, err error) {
+	rows, err := receiver.query(ctx, receiver.dialect.GetClientAccountsSQL, clientId)
+	if err != nil {
+		return nil, queryError(receiver.dialect.GetClientAccountsSQL, err)
+	}
+	defer func() {
+		if innerErr := rows.Close(); innerErr != nil {
+			accounts, err = nil, dbError(innerErr)
+		}
+	}()
+
+	for rows.Next() {
+		account := Account{}
+		err = rows.Scan(&account.Id, &account.Balance.Amount, &account.Balance.Currency)
+		if err != nil {
+			return nil, dbError(err)
+		}
+		accounts = append(accounts, account)
+	}
+	if rows.Err() != nil {
+		return nil, dbError(rows.Err())
+	}
+
+	return accounts, nil
+}
+
+func (receiver *sqlTx) ListAccounts(ctx context.Context) (accounts []AccountWithClientId, err error) {
+	rows, err := receiver.query(ctx, receiver.dialect.GetListOfAccountsSQL)
+	if err != nil {
+		return nil, queryError(receiver.dialect.GetListOfAccountsSQL, err)
+	}
+	defer func() {
+		if innerErr := rows.Close(); innerErr != nil {
+			accounts, err = nil, dbError(innerErr)
+		}
+	}()
+
+	for rows.Next() {
+		account := AccountWithClientId{}
+		err = rows.Scan(&account.Id, &account.ClientId, &account.Balance.Amount, &account.Balance.Currency)
+		if err != nil {
+			return nil, dbError(err)
+		}
+		accounts = append(accounts, account)
+	}
+	if rows.Err() != nil {
+		return nil, dbError(rows.Err())
+	}
+
+	return accounts, nil
+}
+
+func (receiver *sqlTx) UpdateBalance(ctx context.Context, id int64, amount int64) error {
+	result, err := receiver.exec(
+		ctx,
+		receiver.dialect.UpdateClientBalanceSQL,
+		sql.Named("id", id),
+		sql.Named("amount", amount),
+	)
+	if err != nil {
+		return err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return dbError(err)
+	}
+	if affected != 1 {
+		return ErrAccountNotExist
+	}
+
+	return nil
+}
+
+func (receiver *sqlTx) SaveAccount(ctx context.Context, account AccountWithClientId) error {
+	_, err := receiver.exec(
+		ctx,
+		receiver.dialect.UpdateListOfAccountsWithClientIdsSQL,
+		sql.Named("id", account.Id),
+		sql.Named("client_id", account.ClientId),
+		sql.Named("balance", account.Balance.Amount),
+		sql.Named("currency", account.Balance.Currency),
+	)
+	if err != nil {
+		return err
+	}
+	return receiver.syncId(ctx, "accounts")
+}
+
+func (receiver *sqlTx) AddService(ctx context.Context, name string) error {
+	_, err := receiver.exec(
+		ctx,
+		receiver.dialect.AddServiceSQL,
+		sql.Named("name", name),
+	)
+	return err
+}
+
+func (receiver *sqlTx) exists(ctx context.Context, query string, arg interface{}) (exists bool, err error) {
+	var value string
+	err = receiver.queryRow(ctx, query, arg).Scan(&value)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+	if err != nil {
+		return false, queryError(query, err)
+	}
+	return true, nil
+}
+
+func (receiver *sqlTx) ServiceExists(ctx context.Context, name string) (exists bool, err error) {
+	return receiver.exists(ctx, receiver.dialect.ServiceExistSQL, name)
+}
+
+func (receiver *sqlTx) GetServiceProvider(ctx context.Context, name string) (providerId int64, err error) {
+	err = receiver.queryRow(ctx, receiver.dialect.GetServiceProviderSQL, name).Scan(&providerId)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, ErrServiceNotExist
+	}
+	if err != nil {
+		return 0, queryError(receiver.dialect.GetServiceProviderSQL, err)
+	}
+	return providerId, nil
+}
+
+func (receiver *sqlTx) SetServiceProvider(ctx context.Context, name string, providerId int64) error {
+	result, err := receiver.exec(
+		ctx,
+		receiver.dialect.SetServiceProviderSQL,
+		sql.Named("name", name),
+		sql.Named("provider_id", sql.NullInt64{Int64: providerId, Valid: providerId != 0}),
+	)
+	if err != nil {
+		return err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return dbError(err)
+	}
+	if affected != 1 {
+		return ErrServiceNotExist
+	}
+
+	return nil
+}
+
+func (receiver *sqlTx) AddCatalogService(ctx context.Context, service CatalogService) error {
+	currency := service.Currency()
+	_, err := receiver.exec(
+		ctx,
+		receiver.dialect.AddCatalogServiceSQL,
+		sql.Named("name", service.Name),
+		sql.Named("category", service.Category),
+		sql.Named("currency", sql.NullString{String: currency, Valid: currency != ""}),
+		sql.Named("min_amount", sql.NullInt64{Int64: service.MinAmount.Amount, Valid: service.MinAmount.Amount != 0}),
+		sql.Named("max_amount", sql.NullInt64{Int64: service.MaxAmount.Amount, Valid: service.MaxAmount.Amount != 0}),
+		sql.Named("fixed_fee", sql.NullInt64{Int64: service.FixedFee.Amount, Valid: service.FixedFee.Amount != 0}),
+		sql.Named("fee_percent", sql.NullInt64{Int64: int64(service.FeePercent), Valid: service.FeePercent != 0}),
+		sql.Named("reference_pattern", sql.NullString{String: service.ReferencePattern, Valid: service.ReferencePattern != ""}),
+	)
+	return err
+}
+
+// scanCatalogService reads a service selected by the catalog queries.
+func scanCatalogService(scan func(dest ...interface{}) error) (service CatalogService, err error) {
+	var currency string
+	var minAmount, maxAmount, fixedFee, feePercent int64
+	err = scan(&service.Name, &service.Category, &currency, &minAmount, &maxAmount, &fixedFee, &feePercent,
+		&service.ReferencePattern)
+	if err != nil {
+		return CatalogService{}, err
+	}
+
+	service.MinAmount = optionalMoney(minAmount, currency)
+	service.MaxAmount = optionalMoney(maxAmount, currency)
+	service.FixedFee = optionalMoney(fixedFee, currency)
+	service.FeePercent = Rate(feePercent)
+	return service, nil
+}
+
+func (receiver *sqlTx) GetCatalogService(ctx context.Context, name string) (service CatalogService, err error) {
+	service, err = scanCatalogService(receiver.queryRow(ctx, receiver.dialect.GetCatalogServiceSQL, name).Scan)
+	if errors.Is(err, sql.ErrNoRows) {
+		return CatalogService{}, ErrServiceNotExist
+	}
+	if err != nil {
+		return CatalogService{}, queryError(receiver.dialect.GetCatalogServiceSQL, err)
+	}
+	return service, nil
+}
+
+func (receiver *sqlTx) ListCatalogServices(ctx context.Context, category string) (services []CatalogService, err error) {
+	query, args := receiver.dialect.GetCatalogServicesSQL, []interface{}(nil)
+	if category != "" {
+		query, args = receiver.dialect.GetCatalogServicesByCategorySQL, []interface{}{category}
+	}
+
+	rows, err := receiver.query(ctx, query, args...)
+	if err != nil {
+		return nil, queryError(query, err)
+	}
+	defer func() {
+		if innerErr := rows.Close(); innerErr != nil {
+			services, err = nil, dbError(innerErr)
+		}
+	}()
+
+	for rows.Next() {
+		service, err := scanCatalogService(rows.Scan)
+		if err != nil {
+			return nil, dbError(err)
+		}
+		services = append(services, service)
+	}
+	if rows.Err() != nil {
+		return nil, dbError(rows.Err())
+	}
+
+	return services, nil
+}
+
+func (receiver *sqlTx) AddProvider(ctx context.Context, name, currency string) (id int64, err error) {
+	return receiver.insert(
+		ctx,
+		receiver.dialect.AddProviderSQL,
+		sql.Named("name", name),
+		sql.Named("currency", currency),
+	)
+}
+
+func (receiver *sqlTx) getProvider(ctx context.Context, query string, arg interface{}) (provider Provider, err error) {
+	err = receiver.queryRow(ctx, query, arg).Scan(
+		&provider.Id, &provider.Name, &provider.Balance.Amount, &provider.Balance.Currency)
+	if errors.Is(err, sql.ErrNoRows) {
+		return Provider{}, ErrProviderNotExist
+	}
+	if err != nil {
+		return Provider{}, queryError(query, err)
+	}
+	return provider, nil
+}
+
+func (receiver *sqlTx) GetProvider(ctx context.Context, id int64) (provider Provider, err error) {
+	return receiver.getProvider(ctx, receiver.dialect.GetProviderSQL, id)
+}
+
+func (receiver *sqlTx) GetProviderByName(ctx context.Context, name string) (provider Provider, err error) {
+	return receiver.getProvider(ctx, receiver.dialect.GetProviderByNameSQL, name)
+}
+
+func (receiver *sqlTx) ListProviders(ctx context.Context) (providers []Provider, err error) {
+	rows, err := receiver.query(ctx, receiver.dialect.GetListOfProvidersSQL)
+	if err != nil {
+		return nil, queryError(receiver.dialect.GetListOfProvidersSQL, err)
+	}
+	defer func() {
+		if innerErr := rows.Close(); innerErr != nil {
+			providers, err = nil, dbError(innerErr)
+		}
+	}()
+
+	for rows.Next() {
+		provider := Provider{}
+		err = rows.Scan(&provider.Id, &provider.Name, &provider.Balance.Amount, &provider.Balance.Currency)
+		if err != nil {
+			return nil, dbError(err)
+		}
+		providers = append(providers, provider)
+	}
+	if rows.Err() != nil {
+		return nil, dbError(rows.Err())
+	}
+
+	return providers, nil
+}
+
+func (receiver *sqlTx) UpdateProviderBalance(ctx context.Context, id int64, amount int64) error {
+	result, err := receiver.exec(
+		ctx,
+		receiver.dialect.UpdateProviderBalanceSQL,
+		sql.Named("id", id),
+		sql.Named("amount", amount),
+	)
+	if err != nil {
+		return err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return dbError(err)
+	}
+	if affected != 1 {
+		return ErrProviderNotExist
+	}
+
+	return nil
+}
+
+func (receiver *sqlTx) AddATM(ctx context.Context, atm ATM) error {
+	_, err := receiver.exec(
+		ctx,
+		receiver.dialect.AddAtmSQL,
+		sql.Named("name", atm.Name),
+		sql.Named("location", atm.Location),
+	)
+	return err
+}
+
+func (receiver *sqlTx) ATMExists(ctx context.Context, location string) (exists bool, err error) {
+	return receiver.exists(ctx, receiver.dialect.AtmExistSQL, location)
+}
+
+func (receiver *sqlTx) ListATMs(ctx context.Context) (atms []ATM, err error) {
+	rows, err := receiver.query(ctx, receiver.dialect.GetAllATMsSQL)
+	if err != nil {
+		return nil, queryError(receiver.dialect.GetAllATMsSQL, err)
+	}
+	defer func() {
+		if innerErr := rows.Close(); innerErr != nil {
+			atms, err = nil, dbError(innerErr)
+		}
+	}()
+
+	for rows.Next() {
+		atm := ATM{}
+		err = rows.Scan(&atm.Id, &atm.Name, &atm.Location)
+		if err != nil {
+			return nil, dbError(err)
+		}
+		atms = append(atms, atm)
+	}
+	if rows.Err() != nil {
+		return nil, dbError(rows.Err())
+	}
+
+	return atms, nil
+}
+
+func (receiver *sqlTx) SaveATM(ctx context.Context, atm ATM) error {
+	_, err := receiver.exec(
+		ctx,
+		receiver.dialect.UpdateListOfATMsSQL,
+		sql.Named("id", atm.Id),
+		sql.Named("name", atm.Name),
+		sql.Named("location", atm.Location),
+	)
+	if err != nil {
+		return err
+	}
+	return receiver.syncId(ctx, "atms")
+}
+
+func (receiver *sqlTx) AddJournal(ctx context.Context, clientId int64, journal Journal) (id int64, err error) {
+	return receiver.insert(
+		ctx,
+		receiver.dialect.AddToJournalSQL,
+		sql.Named("date", formatTime(journal.Date)),
+		sql.Named("client_id", clientId),
+		sql.Named("type", journal.Type),
+		sql.Named("direction", journal.Direction),
+		sql.Named("account_id", sql.NullInt64{Int64: journal.AccountId, Valid: journal.AccountId != 0}),
+		sql.Named("counterparty", journal.Counterparty),
+		sql.Named("counterparty_account_id", sql.NullInt64{Int64: journal.CounterpartyAccountId, Valid: journal.CounterpartyAccountId != 0}),
+		sql.Named("transferred_to", journal.TransferredTo),
+		sql.Named("amount", journal.Amount.Amount),
+		sql.Named("currency", journal.Amount.Currency),
+		sql.Named("rate", sql.NullInt64{Int64: int64(journal.Rate), Valid: journal.Rate != 0}),
+		sql.Named("reference", sql.NullString{String: journal.Reference, Valid: journal.Reference != ""}),
+		sql.Named("fee", sql.NullInt64{Int64: journal.Fee.Amount, Valid: journal.Fee.Amount != 0}),
+	)
+}
+
+func (receiver *sqlTx) GetJournal(ctx context.Context, id int64) (clientId int64, journal Journal, err error) {
+	var date string
+	var rate, fee sql.NullInt64
+	err = receiver.queryRow(ctx, receiver.dialect.GetJournalSQL, id).Scan(&clientId, &journal.Id, &date, &journal.Type,
+		&journal.Direction, &journal.AccountId, &journal.Counterparty, &journal.CounterpartyAccountId, &journal.TransferredTo,
+		&journal.Amount.Amount, &journal.Amount.Currency, &rate, &journal.Reference, &fee)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, Journal{}, ErrJournalNotFound
+	}
+	if err != nil {
+		return 0, Journal{}, queryError(receiver.dialect.GetJournalSQL, err)
+	}
+
+	journal.Date, err = parseTime(date)
+	if err != nil {
+		return 0, Journal{}, dbError(err)
+	}
+	journal.Rate = Rate(rate.Int64)
+	journal.Fee = optionalMoney(fee.Int64, journal.Amount.Currency)
+
+	return clientId, journal, nil
+}
+
+// journalFilter appends the filter's conditions to query, a statement over
+// the journal rows of filter.ClientId.
+func (receiver *sqlTx) journalFilter(query string, filter JournalFilter) (string, []interface{}) {
+	var builder strings.Builder
+	builder.WriteString(query)
+	args := []interface{}{filter.ClientId}
+
+	if !filter.From.IsZero() {
+		builder.WriteString(receiver.dialect.JournalFromFilterSQL)
+		args = append(args, formatTime(filter.From))
+	}
+
+	if !filter.To.IsZero() {
+		builder.WriteString(receiver.dialect.JournalToFilterSQL)
+		args = append(args, formatTime(filter.To))
+	}
+
+	if len(filter.Types) > 0 {
+		placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(filter.Types)), ", ")
+		builder.WriteString(fmt.Sprintf(receiver.dialect.JournalTypeFilterSQL, placeholders))
+		for _, kind := range filter.Types {
+			args = append(args, kind)
+		}
+	}
+
+	if filter.Counterparty != "" {
+		builder.WriteString(receiver.dialect.JournalCounterpartyFilterSQL)
+		args = append(args, filter.Counterparty)
+	}
+
+	if filter.AccountId != 0 {
+		builder.WriteString(receiver.dialect.JournalAccountFilterSQL)
+		args = append(args, filter.AccountId)
+	}
+
+	if filter.MinAmount != nil {
+		builder.WriteString(receiver.dialect.JournalMinAmountFilterSQL)
+		args = append(args, filter.MinAmount.Currency, filter.MinAmount.Amount)
+	}
+
+	if filter.MaxAmount != nil {
+		builder.WriteString(receiver.dialect.JournalMaxAmountFilterSQL)
+		args = append(args, filter.MaxAmount.Currency, filter.MaxAmount.Amount)
+	}
+
+	return builder.String(), args
+}
+
+func (receiver *sqlTx) ListJournal(ctx context.Context, filter JournalFilter) (journals []Journal, err error) {
+	query, args := receiver.journalFilter(receiver.dialect.GetJournalListSQL, filter)
+
+	if filter.After != nil {
+		if filter.Descending {
+			query += receiver.dialect.JournalBeforeSQL
+		} else {
+			query += receiver.dialect.JournalAfterSQL
+		}
+		args = append(args, formatTime(filter.After.Date), filter.After.Id)
+	}
+
+	if filter.Descending {
+		query += receiver.dialect.JournalOrderDescSQL
+	} else {
+		query += receiver.dialect.JournalOrderAscSQL
+	}
+	query += receiver.dialect.JournalPageSQL
+	args = append(args, filter.Limit, filter.Offset)
+
+	rows, err := receiver.query(ctx, query, args...)
+	if err != nil {
+		return nil, queryError(query, err)
+	}
+	defer func() {
+		if innerErr := rows.Close(); innerErr != nil {
+			journals, err = nil, dbError(innerErr)
+		}
+	}()
+
+	for rows.Next() {
+		journal := Journal{}
+		var date string
+		var rate, fee sql.NullInt64
+		err = rows.Scan(&journal.Id, &date, &journal.Type, &journal.Direction, &journal.AccountId, &journal.Counterparty,
+			&journal.CounterpartyAccountId, &journal.TransferredTo, &journal.Amount.Amount, &journal.Amount.Currency, &rate,
+			&journal.Reference, &fee)
+		if err != nil {
+			return nil, dbError(err)
+		}
+		journal.Date, err = parseTime(date)
+		if err != nil {
+			return nil, dbError(err)
+		}
+		journal.Rate = Rate(rate.Int64)
+		journal.Fee = optionalMoney(fee.Int64, journal.Amount.Currency)
+		journals = append(journals, journal)
+	}
+	if rows.Err() != nil {
+		return nil, dbError(rows.Err())
+	}
+
+	return journals, nil
+}
+
+func (receiver *sqlTx) CountJournal(ctx context.Context, filter JournalFilter) (count int64, err error) {
+	query, args := receiver.journalFilter(receiver.dialect.CountJournalSQL, filter)
+	err = receiver.queryRow(ctx, query, args...).Scan(&count)
+	if err != nil {
+		return 0, queryError(query, err)
+	}
+	return count, nil
+}
+
+func (receiver *sqlTx) AddPosting(ctx context.Context, posting Posting) (id int64, err error) {
+	id, err = receiver.insert(
+		ctx,
+		receiver.dialect.AddPostingSQL,
+		sql.Named("journal_id", sql.NullInt64{Int64: posting.JournalId, Valid: posting.JournalId != 0}),
+		sql.Named("type", posting.Type),
+		sql.Named("created_at", formatTime(posting.CreatedAt)),
+	)
+	if err != nil {
+		return 0, err
+	}
+
+	for _, entry := range posting.Entries {
+		_, err = receiver.exec(
+			ctx,
+			receiver.dialect.AddLedgerEntrySQL,
+			sql.Named("posting_id", id),
+			sql.Named("account_id", sql.NullInt64{Int64: entry.AccountId, Valid: entry.SystemAccount == ""}),
+			sql.Named("system_account", sql.NullString{String: entry.SystemAccount, Valid: entry.SystemAccount != ""}),
+			sql.Named("amount", entry.Amount.Amount),
+			sql.Named("currency", entry.Amount.Currency),
+		)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	return id, nil
+}
+
+func (receiver *sqlTx) GetJournalPosting(ctx context.Context, journalId int64) (posting Posting, err error) {
+	var id int64
+	var createdAt string
+	err = receiver.queryRow(ctx, receiver.dialect.GetJournalPostingSQL, journalId).Scan(&id, &posting.Type, &createdAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return Posting{}, ErrPostingNotFound
+	}
+	if err != nil {
+		return Posting{}, queryError(receiver.dialect.GetJournalPostingSQL, err)
+	}
+
+	posting.JournalId = journalId
+	posting.CreatedAt, err = parseTime(createdAt)
+	if err != nil {
+		return Posting{}, dbError(err)
+	}
+
+	rows, err := receiver.query(ctx, receiver.dialect.GetLedgerEntriesSQL, id)
+	if err != nil {
+		return Posting{}, queryError(receiver.dialect.GetLedgerEntriesSQL, err)
+	}
+	defer func() {
+		if innerErr := rows.Close(); innerErr != nil {
+			posting, err = Posting{}, dbError(innerErr)
+		}
+	}()
+
+	for rows.Next() {
+		entry := LedgerEntry{}
+		err = rows.Scan(&entry.AccountId, &entry.SystemAccount, &entry.Amount.Amount, &entry.Amount.Currency)
+		if err != nil {
+			return Posting{}, dbError(err)
+		}
+		posting.Entries = append(posting.Entries, entry)
+	}
+	if rows.Err() != nil {
+		return Posting{}, dbError(rows.Err())
+	}
+
+	return posting, nil
+}
+
+func (receiver *sqlTx) GetLedgerBalances(ctx context.Context, accountId int64) (balances []Money, err error) {
+	rows, err := receiver.query(ctx, receiver.dialect.GetAccountLedgerBalancesSQL, accountId)
+	if err != nil {
+		return nil, queryError(receiver.dialect.GetAccountLedgerBalancesSQL, err)
+	}
+	defer func() {
+		if innerErr := rows.Close(); innerErr != nil {
+			balances, err = nil, dbError(innerErr)
+		}
+	}()
+
+	for rows.Next() {
+		var balance Money
+		err = rows.Scan(&balance.Currency, &balance.Amount)
+		if err != nil {
+			return nil, dbError(err)
+		}
+		balances = append(balances, balance)
+	}
+	if rows.Err() != nil {
+		return nil, dbError(rows.Err())
+	}
+
+	return balances, nil
+}
+
+func (receiver *sqlTx) GetUnbalancedPosting(ctx context.Context) (postingId int64, sum Money, found bool, err error) {
+	err = receiver.queryRow(ctx, receiver.dialect.GetUnbalancedPostingsSQL).Scan(&postingId, &sum.Currency, &sum.Amount)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, Money{}, false, nil
+	}
+	if err != nil {
+		return 0, Money{}, false, queryError(receiver.dialect.GetUnbalancedPostingsSQL, err)
+	}
+	return postingId, sum, true, nil
+}
+
+func (receiver *sqlTx) GetAccountOutOfLedger(ctx context.Context) (account AccountWithClientId, ledger Money, found bool, err error) {
+	err = receiver.queryRow(ctx, receiver.dialect.GetAccountsOutOfLedgerSQL).Scan(
+		&account.Id, &account.ClientId, &account.Balance.Amount, &account.Balance.Currency, &ledger.Amount)
+	if errors.Is(err, sql.ErrNoRows) {
+		return AccountWithClientId{}, Money{}, false, nil
+	}
+	if err != nil {
+		return AccountWithClientId{}, Money{}, false, queryError(receiver.dialect.GetAccountsOutOfLedgerSQL, err)
+	}
+	ledger.Currency = account.Balance.Currency
+	return account, ledger, true, nil
+}
+
+func (receiver *sqlTx) GetProviderOutOfLedger(ctx context.Context) (provider Provider, ledger Money, found bool, err error) {
+	err = receiver.queryRow(ctx, receiver.dialect.GetProvidersOutOfLedgerSQL).Scan(
+		&provider.Id, &provider.Name, &provider.Balance.Amount, &provider.Balance.Currency, &ledger.Amount)
+	if errors.Is(err, sql.ErrNoRows) {
+		return Provider{}, Money{}, false, nil
+	}
+	if err != nil {
+		return Provider{}, Money{}, false, queryError(receiver.dialect.GetProvidersOutOfLedgerSQL, err)
+	}
+	ledger.Currency = provider.Balance.Currency
+	return provider, ledger, true, nil
+}
+
+func (receiver *sqlTx) GetSystemAccountTotals(ctx context.Context, systemAccount string, from, to time.Time) (totals []LedgerTotal, err error) {
+	rows, err := receiver.query(ctx, receiver.dialect.GetSystemAccountTotalsSQL, systemAccount, formatTime(from), formatTime(to))
+	if err != nil {
+		return nil, queryError(receiver.dialect.GetSystemAccountTotalsSQL, err)
+	}
+	defer func() {
+		if innerErr := rows.Close(); innerErr != nil {
+			totals, err = nil, dbError(innerErr)
+		}
+	}()
+
+	for rows.Next() {
+		total := LedgerTotal{}
+		err = rows.Scan(&total.Type, &total.Amount.Currency, &total.Count, &total.Amount.Amount)
+		if err != nil {
+			return nil, dbError(err)
+		}
+		totals = append(totals, total)
+	}
+	if rows.Err() != nil {
+		return nil, dbError(rows.Err())
+	}
+
+	return totals, nil
+}
+
+func nullTime(t time.Time) sql.NullString {
+	if t.IsZero() {
+		return sql.NullString{}
+	}
+	return sql.NullString{String: formatTime(t), Valid: true}
+}
+
+func parseNullTime(value sql.NullString) (t time.Time, err error) {
+	if !value.Valid {
+		return time.Time{}, nil
+	}
+	return parseTime(value.String)
+}
+
+func (receiver *sqlTx) GetLoginAttempts(ctx context.Context, clientId int64) (attempts LoginAttempts, err error) {
+	var firstFailedAt, lockedUntil, lastLoginAt sql.NullString
+	err = receiver.queryRow(
+		ctx,
+		receiver.dialect.GetLoginAttemptsSQL,
+		clientId,
+	).Scan(&attempts.FailedCount, &firstFailedAt, &lockedUntil, &lastLoginAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return LoginAttempts{}, nil
+	}
+	if err != nil {
+		return LoginAttempts{}, queryError(receiver.dialect.GetLoginAttemptsSQL, err)
+	}
+
+	for _, field := range []struct {
+		value sql.NullString
+		t     *time.Time
+	}{
+		{firstFailedAt, &attempts.FirstFailedAt},
+		{lockedUntil, &attempts.LockedUntil},
+		{lastLoginAt, &attempts.LastLoginAt},
+	} {
+		*field.t, err = parseNullTime(field.value)
+		if err != nil {
+			return LoginAttempts{}, dbError(err)
+		}
+	}
+
+	return attempts, nil
+}
+
+func (receiver *sqlTx) SaveFailedLogins(ctx context.Context, clientId int64, attempts LoginAttempts) error {
+	_, err := receiver.exec(
+		ctx,
+		receiver.dialect.SaveFailedLoginSQL,
+		sql.Named("client_id", clientId),
+		sql.Named("failed_count", attempts.FailedCount),
+		sql.Named("first_failed_at", nullTime(attempts.FirstFailedAt)),
+		sql.Named("locked_until", nullTime(attempts.LockedUntil)),
+	)
+	return err
+}
+
+func (receiver *sqlTx) SaveSuccessfulLogin(ctx context.Context, clientId int64, at time.Time) error {
+	_, err := receiver.exec(
+		ctx,
+		receiver.dialect.SaveSuccessfulLoginSQL,
+		sql.Named("client_id", clientId),
+		sql.Named("last_login_at", formatTime(at)),
+	)
+	if err != nil {
+		return queryError(receiver.dialect.SaveSuccessfulLoginSQL, err)
+	}
+	return nil
+}
+
+func (receiver *sqlTx) ResetLoginAttempts(ctx context.Context, clientId int64) error {
+	_, err := receiver.exec(
+		ctx,
+		receiver.dialect.ResetLoginAttemptsSQL,
+		sql.Named("client_id", clientId),
+	)
+	if err != nil {
+		return dbError(err)
+	}
+	return nil
+}
+
+func (receiver *sqlTx) AddSession(ctx context.Context, tokenHash string, session Session) (id int64, err error) {
+	id, err = receiver.insert(
+		ctx,
+		receiver.dialect.AddSessionSQL,
+		sql.Named("token_hash", tokenHash),
+		sql.Named("client_id", session.ClientId),
+		sql.Named("device", session.Device),
+		sql.Named("created_at", formatTime(session.CreatedAt)),
+		sql.Named("expires_at", formatTime(session.ExpiresAt)),
+	)
+	if err != nil {
+		return 0, queryError(receiver.dialect.AddSessionSQL, err)
+	}
+	return id, nil
+}
+
+func (receiver *sqlTx) GetSession(ctx context.Context, tokenHash string) (session Session, revoked bool, err error) {
+	var createdAt, expiresAt string
+	var revokedAt sql.NullString
+
+	err = receiver.queryRow(ctx, receiver.dialect.GetSessionByTokenHashSQL, tokenHash).Scan(
+		&session.Id, &session.ClientId, &session.Device, &createdAt, &expiresAt, &revokedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return Session{}, false, ErrSessionNotFound
+	}
+	if err != nil {
+		return Session{}, false, queryError(receiver.dialect.GetSessionByTokenHashSQL, err)
+	}
+
+	session.CreatedAt, err = parseTime(createdAt)
+	if err != nil {
+		return Session{}, false, dbError(err)
+	}
+
+	session.ExpiresAt, err = parseTime(expiresAt)
+	if err != nil {
+		return Session{}, false, dbError(err)
+	}
+
+	return session, revokedAt.Valid, nil
+}
+
+func (receiver *sqlTx) RevokeSession(ctx context.Context, tokenHash string, at time.Time) error {
+	_, err := receiver.exec(
+		ctx,
+		receiver.dialect.RevokeSessionSQL,
+		sql.Named("revoked_at", formatTime(at)),
+		sql.Named("token_hash", tokenHash),
+	)
+	if err != nil {
+		return queryError(receiver.dialect.RevokeSessionSQL, err)
+	}
+	return nil
+}
+
+func (receiver *sqlTx) RevokeClientSessions(ctx context.Context, clientId int64, at time.Time) error {
+	_, err := receiver.exec(
+		ctx,
+		receiver.dialect.RevokeAllClientSessionsSQL,
+		sql.Named("revoked_at", formatTime(at)),
+		sql.Named("client_id", clientId),
+	)
+	if err != nil {
+		return queryError(receiver.dialect.RevokeAllClientSessionsSQL, err)
+	}
+	return nil
+}
+
+func (receiver *sqlTx) AddExchangeRate(ctx context.Context, rate ExchangeRate) error {
+	_, err := receiver.exec(
+		ctx,
+		receiver.dialect.AddExchangeRateSQL,
+		sql.Named("base_currency", rate.Base),
+		sql.Named("quote_currency", rate.Quote),
+		sql.Named("buy_rate", int64(rate.Buy)),
+		sql.Named("sell_rate", int64(rate.Sell)),
+		sql.Named("effective_at", formatTime(rate.EffectiveAt)),
+	)
+	return err
+}
+
+func (receiver *sqlTx) GetExchangeRate(ctx context.Context, base, quote string, at time.Time) (rate ExchangeRate, err error) {
+	var effectiveAt string
+	err = receiver.queryRow(ctx, receiver.dialect.GetExchangeRateSQL, base, quote, formatTime(at)).Scan(
+		&rate.Base, &rate.Quote, &rate.Buy, &rate.Sell, &effectiveAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ExchangeRate{}, ErrExchangeRateNotFound
+	}
+	if err != nil {
+		return ExchangeRate{}, queryError(receiver.dialect.GetExchangeRateSQL, err)
+	}
+
+	rate.EffectiveAt, err = parseTime(effectiveAt)
+	if err != nil {
+		return ExchangeRate{}, dbError(err)
+	}
+
+	return rate, nil
+}
+
+func (receiver *sqlTx) AddIdempotencyKey(ctx context.Context, clientId int64, key IdempotencyKey) error {
+	_, err := receiver.exec(
+		ctx,
+		receiver.dialect.AddIdempotencyKeySQL,
+		sql.Named("client_id", clientId),
+		sql.Named("idempotency_key", key.Key),
+		sql.Named("request", key.Request),
+		sql.Named("journal_id", key.JournalId),
+		sql.Named("amount", key.Result.Amount),
+		sql.Named("currency", key.Result.Currency),
+		sql.Named("created_at", formatTime(key.CreatedAt)),
+	)
+	if err != nil {
+		return queryError(receiver.dialect.AddIdempotencyKeySQL, err)
+	}
+	return nil
+}
+
+func (receiver *sqlTx) GetIdempotencyKey(ctx context.Context, clientId int64, key string) (stored IdempotencyKey, err error) {
+	var createdAt string
+	err = receiver.queryRow(ctx, receiver.dialect.GetIdempotencyKeySQL, clientId, key).Scan(
+		&stored.Request, &stored.JournalId, &stored.Result.Amount, &stored.Result.Currency, &createdAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return IdempotencyKey{}, ErrIdempotencyKeyNotFound
+	}
+	if err != nil {
+		return IdempotencyKey{}, queryError(receiver.dialect.GetIdempotencyKeySQL, err)
+	}
+
+	stored.CreatedAt, err = parseTime(createdAt)
+	if err != nil {
+		return IdempotencyKey{}, dbError(err)
+	}
+
+	stored.Key = key
+	return stored, nil
+}
+
+func (receiver *sqlTx) DeleteIdempotencyKeysBefore(ctx context.Context, createdBefore time.Time) error {
+	_, err := receiver.exec(ctx, receiver.dialect.DeleteIdempotencyKeysBeforeSQL, formatTime(createdBefore))
+	if err != nil {
+		return queryError(receiver.dialect.DeleteIdempotencyKeysBeforeSQL, err)
+	}
+	return nil
+}
+
+func (receiver *sqlTx) AddReversal(ctx context.Context, reversal JournalReversal) error {
+	_, err := receiver.exec(
+		ctx,
+		receiver.dialect.AddReversalSQL,
+		sql.Named("journal_id", reversal.JournalId),
+		sql.Named("reversal_journal_id", reversal.ReversalJournalId),
+		sql.Named("reason", reversal.Reason),
+		sql.Named("created_at", formatTime(reversal.CreatedAt)),
+	)
+	if err != nil {
+		return queryError(receiver.dialect.AddReversalSQL, err)
+	}
+	return nil
+}
+
+func (receiver *sqlTx) GetReversal(ctx context.Context, journalId int64) (reversal JournalReversal, err error) {
+	var createdAt string
+	err = receiver.queryRow(ctx, receiver.dialect.GetReversalSQL, journalId).Scan(&reversal.ReversalJournalId, &reversal.Reason, &createdAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return JournalReversal{}, ErrReversalNotFound
+	}
+	if err != nil {
+		return JournalReversal{}, queryError(receiver.dialect.GetReversalSQL, err)
+	}
+
+	reversal.CreatedAt, err = parseTime(createdAt)
+	if err != nil {
+		return JournalReversal{}, dbError(err)
+	}
+
+	reversal.JournalId = journalId
+	return reversal, nil
+}
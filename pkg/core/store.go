@@ -0,0 +1,200 @@
+package core
+
+import (
+	"context"
+	"time"
+)
+
+// Store keeps the state of a Bank. All reads and writes go through a Tx, so
+// every Bank operation sees and changes the state atomically.
+type Store interface {
+	// Init prepares the storage for use, creating or upgrading its schema.
+	Init(ctx context.Context) error
+	Begin(ctx context.Context, options TxOptions) (Tx, error)
+}
+
+// datedInitStore is implemented by stores whose Init writes rows dated with
+// the clock of the Bank.
+type datedInitStore interface {
+	initAt(ctx context.Context, now func() time.Time) error
+}
+
+// TxOptions describe how a transaction uses the Store.
+type TxOptions struct {
+	// ReadOnly transactions change nothing, stores may run them alongside
+	// the ones that write instead of waiting for the write lock.
+	ReadOnly bool
+}
+
+// Tx is a unit of work on a Store. Lookups of missing rows fail with the
+// matching sentinel error (ErrLoginNotFound, ErrAccountNotExist, ...), lists
+// are nil when empty.
+type Tx interface {
+	Commit() error
+	Rollback() error
+
+	AddClient(ctx context.Context, client Client) (id int64, err error)
+	GetClient(ctx context.Context, id int64) (client Client, err error)
+	GetClientByLogin(ctx context.Context, login string) (client Client, err error)
+	GetClientByPhoneNumber(ctx context.Context, phoneNumber int64) (client Client, err error)
+	SetClientPassword(ctx context.Context, login, password string) error
+	SetClientStatus(ctx context.Context, id int64, status string) error
+	// SaveClient inserts the client or replaces the one with the same id.
+	SaveClient(ctx context.Context, client Client) error
+	ListClients(ctx context.Context) (clients []Client, err error)
+	// ListClientsByName orders clients by name, descending.
+	ListClientsByName(ctx context.Context, limit, offset int64) (clients []Client, err error)
+	// ListClientsAfter returns clients with ids above id in ascending order,
+	// ListClientsBefore the ones below it in descending order.
+	ListClientsAfter(ctx context.Context, id, limit int64) (clients []Client, err error)
+	ListClientsBefore(ctx context.Context, id, limit int64) (clients []Client, err error)
+	CountClients(ctx context.Context) (count int64, err error)
+	// SearchClientsByName and SearchClientsByPhoneNumber match substrings.
+	SearchClientsByName(ctx context.Context, name string) (clients []Client, err error)
+	SearchClientsByPhoneNumber(ctx context.Context, phoneNumber string) (clients []Client, err error)
+
+	AddAccount(ctx context.Context, clientId int64, balance Money) (id int64, err error)
+	GetAccount(ctx context.Context, id int64) (account AccountWithClientId, err error)
+	// FindClientAccount returns the client's first account in currency.
+	FindClientAccount(ctx context.Context, clientId int64, currency string) (id int64, err error)
+	ListClientAccounts(ctx context.Context, clientId int64) (accounts []Account, err error)
+	ListAccounts(ctx context.Context) (accounts []AccountWithClientId, err error)
+	// UpdateBalance adds amount, in minor units of the account's currency,
+	// to the balance. Balances never go below zero.
+	UpdateBalance(ctx context.Context, id int64, amount int64) error
+	SaveAccount(ctx context.Context, account AccountWithClientId) error
+
+	AddService(ctx context.Context, name string) error
+	ServiceExists(ctx context.Context, name string) (exists bool, err error)
+	// GetServiceProvider returns the provider payments for the service go
+	// to, zero when the service has none.
+	GetServiceProvider(ctx context.Context, name string) (providerId int64, err error)
+	SetServiceProvider(ctx context.Context, name string, providerId int64) error
+	AddCatalogService(ctx context.Context, service CatalogService) error
+	GetCatalogService(ctx context.Context, name string) (service CatalogService, err error)
+	// ListCatalogServices lists the services of category in the order they
+	// were added, all of them when category is empty.
+	ListCatalogServices(ctx context.Context, category string) (services []CatalogService, err error)
+
+	AddProvider(ctx context.Context, name, currency string) (id int64, err error)
+	GetProvider(ctx context.Context, id int64) (provider Provider, err error)
+	GetProviderByName(ctx context.Context, name string) (provider Provider, err error)
+	ListProviders(ctx context.Context) (providers []Provider, err error)
+	// UpdateProviderBalance adds amount, in minor units of the provider's
+	// currency, to the balance. Balances never go below zero.
+	UpdateProviderBalance(ctx context.Context, id int64, amount int64) error
+
+	AddATM(ctx context.Context, atm ATM) error
+	ATMExists(ctx context.Context, location string) (exists bool, err error)
+	ListATMs(ctx context.Context) (atms []ATM, err error)
+	SaveATM(ctx context.Context, atm ATM) error
+
+	AddJournal(ctx context.Context, clientId int64, journal Journal) (id int64, err error)
+	GetJournal(ctx context.Context, id int64) (clientId int64, journal Journal, err error)
+	ListJournal(ctx context.Context, filter JournalFilter) (journals []Journal, err error)
+	// CountJournal counts the entries matching filter regardless of its
+	// After, Limit and Offset.
+	CountJournal(ctx context.Context, filter JournalFilter) (count int64, err error)
+
+	AddPosting(ctx context.Context, posting Posting) (id int64, err error)
+	// GetJournalPosting returns the posting of a journal entry together with
+	// its entries.
+	GetJournalPosting(ctx context.Context, journalId int64) (posting Posting, err error)
+	// GetLedgerBalances sums the ledger entries of an account per currency,
+	// in order of the currency codes.
+	GetLedgerBalances(ctx context.Context, accountId int64) (balances []Money, err error)
+	// GetUnbalancedPosting finds a posting whose entries don't sum to zero
+	// in some currency.
+	GetUnbalancedPosting(ctx context.Context) (postingId int64, sum Money, found bool, err error)
+	// GetAccountOutOfLedger finds an account whose balance differs from the
+	// sum of its ledger entries.
+	GetAccountOutOfLedger(ctx context.Context) (account AccountWithClientId, ledger Money, found bool, err error)
+	// GetProviderOutOfLedger finds a provider whose balance differs from the
+	// sum of the entries of its settlement account.
+	GetProviderOutOfLedger(ctx context.Context) (provider Provider, ledger Money, found bool, err error)
+	// GetSystemAccountTotals sums the entries of a system account per
+	// posting type and currency, over postings made from from until to.
+	GetSystemAccountTotals(ctx context.Context, systemAccount string, from, to time.Time) (totals []LedgerTotal, err error)
+
+	// GetLoginAttempts returns zero attempts for clients that never logged in.
+	GetLoginAttempts(ctx context.Context, clientId int64) (attempts LoginAttempts, err error)
+	SaveFailedLogins(ctx context.Context, clientId int64, attempts LoginAttempts) error
+	SaveSuccessfulLogin(ctx context.Context, clientId int64, at time.Time) error
+	ResetLoginAttempts(ctx context.Context, clientId int64) error
+
+	AddSession(ctx context.Context, tokenHash string, session Session) (id int64, err error)
+	GetSession(ctx context.Context, tokenHash string) (session Session, revoked bool, err error)
+	RevokeSession(ctx context.Context, tokenHash string, at time.Time) error
+	RevokeClientSessions(ctx context.Context, clientId int64, at time.Time) error
+
+	AddExchangeRate(ctx context.Context, rate ExchangeRate) error
+	// GetExchangeRate returns the latest rate of the pair effective at at.
+	GetExchangeRate(ctx context.Context, base, quote string, at time.Time) (rate ExchangeRate, err error)
+
+	// AddIdempotencyKey fails when clientId already used the key.
+	AddIdempotencyKey(ctx context.Context, clientId int64, key IdempotencyKey) error
+	GetIdempotencyKey(ctx context.Context, clientId int64, key string) (stored IdempotencyKey, err error)
+	DeleteIdempotencyKeysBefore(ctx context.Context, createdBefore time.Time) error
+
+	// AddReversal fails when the journal entry is already reversed.
+	AddReversal(ctx context.Context, reversal JournalReversal) error
+	GetReversal(ctx context.Context, journalId int64) (reversal JournalReversal, err error)
+}
+
+// LoginAttempts tracks the failed logins of a client since FirstFailedAt.
+// LockedUntil is zero unless the client is temporarily locked.
+type LoginAttempts struct {
+	FailedCount   int64
+	FirstFailedAt time.Time
+	LockedUntil   time.Time
+	LastLoginAt   time.Time
+}
+
+// Posting is a set of ledger entries that sum to zero in every currency. A
+// JournalId of zero leaves the posting unlinked.
+type Posting struct {
+	JournalId int64
+	Type      string
+	CreatedAt time.Time
+	Entries   []LedgerEntry
+}
+
+// LedgerEntry changes the balance of a client account or, when
+// SystemAccount is set, of a system account. Amounts are signed.
+type LedgerEntry struct {
+	AccountId     int64
+	SystemAccount string
+	Amount        Money
+}
+
+// LedgerTotal sums Count ledger entries of postings of one type in one
+// currency.
+type LedgerTotal struct {
+	Type   string
+	Count  int64
+	Amount Money
+}
+
+// JournalFilter is a JournalQuery resolved for a Store. Zero fields don't
+// filter and a negative Limit returns all entries.
+type JournalFilter struct {
+	ClientId     int64
+	From         time.Time
+	To           time.Time
+	Types        []string
+	Counterparty string
+	AccountId    int64
+	MinAmount    *Money
+	MaxAmount    *Money
+	Descending   bool
+	// After keeps the entries that follow it in the filter's order.
+	After  *JournalPosition
+	Limit  int64
+	Offset int64
+}
+
+// JournalPosition is the sort key of a journal entry.
+type JournalPosition struct {
+	Date time.Time
+	Id   int64
+}
@@ -0,0 +1,37 @@
+package core
+
+import "time"
+
+const timeLayout = "2006-01-02T15:04:05.000000000Z"
+
+// legacyJournalLayout is the local time format journal dates were written in
+// before they were stored as UTC timestamps.
+const legacyJournalLayout = "01-02-2006 15:04:05"
+
+var now = time.Now
+
+// SetClock replaces the source of the current time used for journal dates,
+// sessions, lockouts and exchange rates. A nil clock restores time.Now.
+func SetClock(clock func() time.Time) {
+	if clock == nil {
+		clock = time.Now
+	}
+	settingsMu.Lock()
+	defer settingsMu.Unlock()
+	now = clock
+}
+
+// clock is the clock of the package level functions.
+func clock() func() time.Time {
+	settingsMu.RLock()
+	defer settingsMu.RUnlock()
+	return now
+}
+
+func formatTime(t time.Time) string {
+	return t.UTC().Format(timeLayout)
+}
+
+func parseTime(value string) (t time.Time, err error) {
+	return time.Parse(timeLayout, value)
+}
@@ -0,0 +1,209 @@
+package queries
+
+// Dialect holds the statements a store runs against one database engine.
+// Statements use ? and :name placeholders; engines with other placeholders
+// rewrite them before execution. Inserts into tables with generated ids
+// either report the id through LastInsertId or end with RETURNING id.
+type Dialect struct {
+	// BeginWriteSQL, when set, begins the transactions that write instead of
+	// the driver, which begins them without taking the write lock.
+	BeginWriteSQL string
+
+	SchemaVersionDDL       string
+	GetSchemaVersionSQL    string
+	AddSchemaVersionSQL    string
+	DeleteSchemaVersionSQL string
+
+	AddClientSQL                 string
+	GetClientSQL                 string
+	GetClientByLoginSQL          string
+	GetClientByPhoneNumberSQL    string
+	UpdateClientPasswordSQL      string
+	ChangeClientStatusByIdSQL    string
+	UpdateListOfClientsSQL       string
+	GetListOfClientsSQL          string
+	GetListOfClientsFormattedSQL string
+	GetClientsAfterSQL           string
+	GetClientsBeforeSQL          string
+	CountClientsSQL              string
+	SearchClientByName           string
+	SearchClientByPhoneNumber    string
+
+	AddAccountSQL                        string
+	GetAccountSQL                        string
+	GetClientAccountIdByCurrencySQL      string
+	GetClientAccountsSQL                 string
+	GetListOfAccountsSQL                 string
+	UpdateClientBalanceSQL               string
+	UpdateListOfAccountsWithClientIdsSQL string
+
+	AddServiceSQL                   string
+	ServiceExistSQL                 string
+	GetServiceProviderSQL           string
+	SetServiceProviderSQL           string
+	AddCatalogServiceSQL            string
+	GetCatalogServiceSQL            string
+	GetCatalogServicesSQL           string
+	GetCatalogServicesByCategorySQL string
+	AddAtmSQL                       string
+	AtmExistSQL                     string
+	GetAllATMsSQL                   string
+	UpdateListOfATMsSQL             string
+
+	AddToJournalSQL              string
+	GetJournalListSQL            string
+	CountJournalSQL              string
+	JournalFromFilterSQL         string
+	JournalToFilterSQL           string
+	JournalTypeFilterSQL         string
+	JournalCounterpartyFilterSQL string
+	JournalAccountFilterSQL      string
+	JournalMinAmountFilterSQL    string
+	JournalMaxAmountFilterSQL    string
+	JournalBeforeSQL             string
+	JournalAfterSQL              string
+	JournalOrderAscSQL           string
+	JournalOrderDescSQL          string
+	JournalPageSQL               string
+
+	AddPostingSQL               string
+	AddLedgerEntrySQL           string
+	GetAccountLedgerBalancesSQL string
+	GetUnbalancedPostingsSQL    string
+	GetAccountsOutOfLedgerSQL   string
+	GetAccountsWithoutLedgerSQL string
+	GetProvidersOutOfLedgerSQL  string
+	GetSystemAccountTotalsSQL   string
+
+	GetLoginAttemptsSQL    string
+	SaveFailedLoginSQL     string
+	SaveSuccessfulLoginSQL string
+	ResetLoginAttemptsSQL  string
+
+	AddSessionSQL              string
+	GetSessionByTokenHashSQL   string
+	RevokeSessionSQL           string
+	RevokeAllClientSessionsSQL string
+
+	AddExchangeRateSQL string
+	GetExchangeRateSQL string
+
+	AddIdempotencyKeySQL           string
+	GetIdempotencyKeySQL           string
+	DeleteIdempotencyKeysBeforeSQL string
+
+	GetJournalSQL        string
+	GetJournalPostingSQL string
+	GetLedgerEntriesSQL  string
+	AddReversalSQL       string
+	GetReversalSQL       string
+
+	AddProviderSQL           string
+	GetProviderSQL           string
+	GetProviderByNameSQL     string
+	GetListOfProvidersSQL    string
+	UpdateProviderBalanceSQL string
+
+	// SyncIdSQL, when set, is a format string taking a table name that moves
+	// the id generator of the table past rows inserted with explicit ids.
+	SyncIdSQL string
+}
+
+var SQLite = Dialect{
+	BeginWriteSQL: BeginImmediateSQL,
+
+	SchemaVersionDDL:       SchemaVersionDDL,
+	GetSchemaVersionSQL:    GetSchemaVersionSQL,
+	AddSchemaVersionSQL:    AddSchemaVersionSQL,
+	DeleteSchemaVersionSQL: DeleteSchemaVersionSQL,
+
+	AddClientSQL:                 AddClientSQL,
+	GetClientSQL:                 GetClientSQL,
+	GetClientByLoginSQL:          GetClientByLoginSQL,
+	GetClientByPhoneNumberSQL:    GetClientByPhoneNumberSQL,
+	UpdateClientPasswordSQL:      UpdateClientPasswordSQL,
+	ChangeClientStatusByIdSQL:    ChangeClientStatusByIdSQL,
+	UpdateListOfClientsSQL:       UpdateListOfClientsSQL,
+	GetListOfClientsSQL:          GetListOfClientsSQL,
+	GetListOfClientsFormattedSQL: GetListOfClientsFormattedSQL,
+	GetClientsAfterSQL:           GetClientsAfterSQL,
+	GetClientsBeforeSQL:          GetClientsBeforeSQL,
+	CountClientsSQL:              CountClientsSQL,
+	SearchClientByName:           SearchClientByName,
+	SearchClientByPhoneNumber:    SearchClientByPhoneNumber,
+
+	AddAccountSQL:                        AddAccountSQL,
+	GetAccountSQL:                        GetAccountSQL,
+	GetClientAccountIdByCurrencySQL:      GetClientAccountIdByCurrencySQL,
+	GetClientAccountsSQL:                 GetClientAccountsSQL,
+	GetListOfAccountsSQL:                 GetListOfAccountsSQL,
+	UpdateClientBalanceSQL:               UpdateClientBalanceSQL,
+	UpdateListOfAccountsWithClientIdsSQL: UpdateListOfAccountsWithClientIdsSQL,
+
+	AddServiceSQL:                   AddServiceSQL,
+	ServiceExistSQL:                 ServiceExistSQL,
+	GetServiceProviderSQL:           GetServiceProviderSQL,
+	SetServiceProviderSQL:           SetServiceProviderSQL,
+	AddCatalogServiceSQL:            AddCatalogServiceSQL,
+	GetCatalogServiceSQL:            GetCatalogServiceSQL,
+	GetCatalogServicesSQL:           GetCatalogServicesSQL,
+	GetCatalogServicesByCategorySQL: GetCatalogServicesByCategorySQL,
+	AddAtmSQL:                       AddAtmSQL,
+	AtmExistSQL:                     AtmExistSQL,
+	GetAllATMsSQL:                   GetAllATMsSQL,
+	UpdateListOfATMsSQL:             UpdateListOfATMsSQL,
+
+	AddToJournalSQL:              AddToJournalSQL,
+	GetJournalListSQL:            GetJournalListSQL,
+	CountJournalSQL:              CountJournalSQL,
+	JournalFromFilterSQL:         JournalFromFilterSQL,
+	JournalToFilterSQL:           JournalToFilterSQL,
+	JournalTypeFilterSQL:         JournalTypeFilterSQL,
+	JournalCounterpartyFilterSQL: JournalCounterpartyFilterSQL,
+	JournalAccountFilterSQL:      JournalAccountFilterSQL,
+	JournalMinAmountFilterSQL:    JournalMinAmountFilterSQL,
+	JournalMaxAmountFilterSQL:    JournalMaxAmountFilterSQL,
+	JournalBeforeSQL:             JournalBeforeSQL,
+	JournalAfterSQL:              JournalAfterSQL,
+	JournalOrderAscSQL:           JournalOrderAscSQL,
+	JournalOrderDescSQL:          JournalOrderDescSQL,
+	JournalPageSQL:               JournalPageSQL,
+
+	AddPostingSQL:               AddPostingSQL,
+	AddLedgerEntrySQL:           AddLedgerEntrySQL,
+	GetAccountLedgerBalancesSQL: GetAccountLedgerBalancesSQL,
+	GetUnbalancedPostingsSQL:    GetUnbalancedPostingsSQL,
+	GetAccountsOutOfLedgerSQL:   GetAccountsOutOfLedgerSQL,
+	GetAccountsWithoutLedgerSQL: GetAccountsWithoutLedgerSQL,
+	GetProvidersOutOfLedgerSQL:  GetProvidersOutOfLedgerSQL,
+	GetSystemAccountTotalsSQL:   GetSystemAccountTotalsSQL,
+
+	GetLoginAttemptsSQL:    GetLoginAttemptsSQL,
+	SaveFailedLoginSQL:     SaveFailedLoginSQL,
+	SaveSuccessfulLoginSQL: SaveSuccessfulLoginSQL,
+	ResetLoginAttemptsSQL:  ResetLoginAttemptsSQL,
+
+	AddSessionSQL:              AddSessionSQL,
+	GetSessionByTokenHashSQL:   GetSessionByTokenHashSQL,
+	RevokeSessionSQL:           RevokeSessionSQL,
+	RevokeAllClientSessionsSQL: RevokeAllClientSessionsSQL,
+
+	AddExchangeRateSQL: AddExchangeRateSQL,
+	GetExchangeRateSQL: GetExchangeRateSQL,
+
+	AddIdempotencyKeySQL:           AddIdempotencyKeySQL,
+	GetIdempotencyKeySQL:           GetIdempotencyKeySQL,
+	DeleteIdempotencyKeysBeforeSQL: DeleteIdempotencyKeysBeforeSQL,
+
+	GetJournalSQL:        GetJournalSQL,
+	GetJournalPostingSQL: GetJournalPostingSQL,
+	GetLedgerEntriesSQL:  GetLedgerEntriesSQL,
+	AddReversalSQL:       AddReversalSQL,
+	GetReversalSQL:       GetReversalSQL,
+
+	AddProviderSQL:           AddProviderSQL,
+	GetProviderSQL:           GetProviderSQL,
+	GetProviderByNameSQL:     GetProviderByNameSQL,
+	GetListOfProvidersSQL:    GetListOfProvidersSQL,
+	UpdateProviderBalanceSQL: UpdateProviderBalanceSQL,
+}
@@ -0,0 +1,344 @@
+package queries
+
+// PostgreSQL versions of the statements whose SQLite syntax Postgres doesn't
+// accept or runs differently. Dates stay ISO 8601 text, so they compare the
+// same way in both engines.
+
+const PostgresClientsDDL = `CREATE TABLE IF NOT EXISTS clients
+(
+    id           BIGSERIAL PRIMARY KEY,
+    name         TEXT   NOT NULL,
+    login        TEXT   NOT NULL UNIQUE,
+    password     TEXT   NOT NULL,
+    phone_number BIGINT NOT NULL UNIQUE,
+    status       TEXT   NOT NULL
+);`
+
+const PostgresJournalDDL = `CREATE TABLE IF NOT EXISTS journal
+(
+    id             BIGSERIAL PRIMARY KEY,
+    date           TEXT   NOT NULL,
+    client_id      BIGINT NOT NULL REFERENCES clients,
+    type           TEXT   NOT NULL,
+    transferred_to TEXT   NOT NULL,
+    amount         BIGINT NOT NULL check ( amount > 0 )
+);`
+
+const PostgresAccountsDDL = `CREATE TABLE IF NOT EXISTS accounts
+(
+    id        BIGSERIAL PRIMARY KEY,
+    client_id BIGINT NOT NULL REFERENCES clients,
+    balance   BIGINT NOT NULL check ( balance >= 0 )
+);`
+
+const PostgresServicesDDL = `CREATE TABLE IF NOT EXISTS services
+(
+    id   BIGSERIAL PRIMARY KEY,
+    name TEXT NOT NULL UNIQUE
+);`
+
+const PostgresAtmsDDL = `CREATE TABLE IF NOT EXISTS atms
+(
+    id       BIGSERIAL PRIMARY KEY,
+    name     TEXT NOT NULL,
+    location TEXT NOT NULL UNIQUE
+);`
+
+const PostgresLoginAttemptsDDL = `CREATE TABLE IF NOT EXISTS login_attempts
+(
+    client_id       BIGINT PRIMARY KEY REFERENCES clients,
+    failed_count    BIGINT NOT NULL DEFAULT 0,
+    first_failed_at TEXT,
+    locked_until    TEXT,
+    last_login_at   TEXT
+);`
+
+const PostgresSessionsDDL = `CREATE TABLE IF NOT EXISTS sessions
+(
+    id         BIGSERIAL PRIMARY KEY,
+    token_hash TEXT   NOT NULL UNIQUE,
+    client_id  BIGINT NOT NULL REFERENCES clients,
+    device     TEXT   NOT NULL,
+    created_at TEXT   NOT NULL,
+    expires_at TEXT   NOT NULL,
+    revoked_at TEXT
+);`
+
+const PostgresJournalRateColumnSQL = `ALTER TABLE journal
+    ADD COLUMN IF NOT EXISTS rate BIGINT;`
+
+const PostgresExchangeRatesDDL = `CREATE TABLE IF NOT EXISTS exchange_rates
+(
+    id             BIGSERIAL PRIMARY KEY,
+    base_currency  TEXT   NOT NULL,
+    quote_currency TEXT   NOT NULL,
+    buy_rate       BIGINT NOT NULL check ( buy_rate > 0 ),
+    sell_rate      BIGINT NOT NULL check ( sell_rate > 0 ),
+    effective_at   TEXT   NOT NULL
+);`
+
+const PostgresDropCurrenciesSQL = `DROP TABLE exchange_rates;
+ALTER TABLE accounts
+    DROP COLUMN currency;
+ALTER TABLE journal
+    DROP COLUMN currency,
+    DROP COLUMN rate;`
+
+const PostgresPostingsDDL = `CREATE TABLE IF NOT EXISTS postings
+(
+    id         BIGSERIAL PRIMARY KEY,
+    journal_id BIGINT REFERENCES journal,
+    type       TEXT NOT NULL,
+    created_at TEXT NOT NULL
+);`
+
+const PostgresLedgerEntriesDDL = `CREATE TABLE IF NOT EXISTS ledger_entries
+(
+    id             BIGSERIAL PRIMARY KEY,
+    posting_id     BIGINT NOT NULL REFERENCES postings,
+    account_id     BIGINT REFERENCES accounts,
+    system_account TEXT,
+    amount         BIGINT NOT NULL check ( amount <> 0 ),
+    currency       TEXT   NOT NULL,
+    check ( (account_id IS NULL) <> (system_account IS NULL) )
+);`
+
+const PostgresJournalAccountIdColumnSQL = `ALTER TABLE journal
+    ADD COLUMN IF NOT EXISTS account_id BIGINT REFERENCES accounts;`
+
+const PostgresJournalCounterpartyAccountIdColumnSQL = `ALTER TABLE journal
+    ADD COLUMN IF NOT EXISTS counterparty_account_id BIGINT REFERENCES accounts;`
+
+const PostgresDropJournalPartiesSQL = `UPDATE postings
+SET journal_id = NULL
+WHERE journal_id IN (SELECT id FROM journal WHERE direction <> 'outgoing');
+DELETE
+FROM journal
+WHERE direction <> 'outgoing';
+ALTER TABLE journal
+    DROP COLUMN direction,
+    DROP COLUMN account_id,
+    DROP COLUMN counterparty,
+    DROP COLUMN counterparty_account_id;`
+
+const PostgresIdempotencyKeysDDL = `CREATE TABLE IF NOT EXISTS idempotency_keys
+(
+    client_id       BIGINT NOT NULL REFERENCES clients,
+    idempotency_key TEXT   NOT NULL,
+    request         TEXT   NOT NULL,
+    journal_id      BIGINT NOT NULL REFERENCES journal,
+    amount          BIGINT NOT NULL,
+    currency        TEXT   NOT NULL,
+    created_at      TEXT   NOT NULL,
+    PRIMARY KEY (client_id, idempotency_key)
+);`
+
+const PostgresReversalsDDL = `CREATE TABLE IF NOT EXISTS reversals
+(
+    journal_id          BIGINT PRIMARY KEY REFERENCES journal,
+    reversal_journal_id BIGINT NOT NULL REFERENCES journal,
+    reason              TEXT   NOT NULL,
+    created_at          TEXT   NOT NULL
+);`
+
+const PostgresProvidersDDL = `CREATE TABLE IF NOT EXISTS providers
+(
+    id       BIGSERIAL PRIMARY KEY,
+    name     TEXT   NOT NULL UNIQUE,
+    balance  BIGINT NOT NULL DEFAULT 0 check ( balance >= 0 ),
+    currency TEXT   NOT NULL
+);`
+
+const PostgresServicesProviderIdColumnSQL = `ALTER TABLE services
+    ADD COLUMN IF NOT EXISTS provider_id BIGINT REFERENCES providers;`
+
+const PostgresDropProvidersSQL = `DROP INDEX ledger_entries_system_account_idx;
+ALTER TABLE services
+    DROP COLUMN provider_id;
+DROP TABLE providers;`
+
+const PostgresServiceCatalogColumnsSQL = `ALTER TABLE services
+    ADD COLUMN IF NOT EXISTS category          TEXT NOT NULL DEFAULT '',
+    ADD COLUMN IF NOT EXISTS currency          TEXT,
+    ADD COLUMN IF NOT EXISTS min_amount        BIGINT,
+    ADD COLUMN IF NOT EXISTS max_amount        BIGINT,
+    ADD COLUMN IF NOT EXISTS fixed_fee         BIGINT,
+    ADD COLUMN IF NOT EXISTS fee_percent       BIGINT,
+    ADD COLUMN IF NOT EXISTS reference_pattern TEXT;`
+
+const PostgresJournalPaymentColumnsSQL = `ALTER TABLE journal
+    ADD COLUMN IF NOT EXISTS reference TEXT,
+    ADD COLUMN IF NOT EXISTS fee       BIGINT;`
+
+const PostgresDropServiceCatalogSQL = `ALTER TABLE services
+    DROP COLUMN category,
+    DROP COLUMN currency,
+    DROP COLUMN min_amount,
+    DROP COLUMN max_amount,
+    DROP COLUMN fixed_fee,
+    DROP COLUMN fee_percent,
+    DROP COLUMN reference_pattern;
+ALTER TABLE journal
+    DROP COLUMN reference,
+    DROP COLUMN fee;`
+
+const PostgresAddClientSQL = `INSERT INTO clients(name, login, password, phone_number, status)
+VALUES (:name, :login, :password, :phone_number, 'active')
+RETURNING id;`
+
+const PostgresAddAccountSQL = `INSERT INTO accounts(client_id, balance, currency)
+VALUES (:client_id, :balance, :currency)
+RETURNING id;`
+
+const PostgresAddToJournalSQL = `INSERT INTO journal(date, client_id, type, direction, account_id, counterparty, counterparty_account_id,
+                    transferred_to, amount, currency, rate, reference, fee)
+VALUES (:date, :client_id, :type, :direction, :account_id, :counterparty, :counterparty_account_id,
+        :transferred_to, :amount, :currency, :rate, :reference, :fee)
+RETURNING id;`
+
+const PostgresAddPostingSQL = `INSERT INTO postings(journal_id, type, created_at)
+VALUES (:journal_id, :type, :created_at)
+RETURNING id;`
+
+const PostgresAddProviderSQL = `INSERT INTO providers(name, currency)
+VALUES (:name, :currency)
+RETURNING id;`
+
+const PostgresAddSessionSQL = `INSERT INTO sessions(token_hash, client_id, device, created_at, expires_at)
+VALUES (:token_hash, :client_id, :device, :created_at, :expires_at)
+RETURNING id;`
+
+const PostgresSearchClientByName = `SELECT id, name, login, password, phone_number, status
+FROM clients
+WHERE name ILIKE ?
+ORDER BY id;`
+
+const PostgresSearchClientByPhoneNumber = `SELECT id, name, login, password, phone_number, status
+FROM clients
+WHERE CAST(phone_number AS TEXT) LIKE ?
+ORDER BY id;`
+
+// PostgresUpdateListOfATMsSQL drops the ATMs the new one replaces, like
+// INSERT OR REPLACE does in SQLite.
+const PostgresUpdateListOfATMsSQL = `WITH replaced AS (
+    DELETE
+    FROM atms
+    WHERE location = :location
+      AND id <> :id
+)
+INSERT
+INTO atms (id, name, location)
+VALUES (:id, :name, :location)
+ON CONFLICT (id)
+    DO UPDATE SET name=excluded.name,
+                  location=excluded.location;`
+
+const PostgresGetListOfClientsFormattedSQL = `SELECT id, name, login, password, phone_number, status
+FROM clients ORDER BY name DESC LIMIT NULLIF(GREATEST(?, -1), -1) OFFSET ?;`
+
+// PostgresJournalPageSQL treats a negative limit as no limit, as SQLite does.
+const PostgresJournalPageSQL = `
+LIMIT NULLIF(GREATEST(?, -1), -1) OFFSET ?;`
+
+const PostgresSyncIdSQL = `SELECT setval(pg_get_serial_sequence('%[1]s', 'id'), MAX(id))
+FROM %[1]s
+HAVING MAX(id) > (SELECT last_value FROM %[1]s_id_seq);`
+
+var Postgres = Dialect{
+	SchemaVersionDDL:       SchemaVersionDDL,
+	GetSchemaVersionSQL:    GetSchemaVersionSQL,
+	AddSchemaVersionSQL:    AddSchemaVersionSQL,
+	DeleteSchemaVersionSQL: DeleteSchemaVersionSQL,
+
+	AddClientSQL:                 PostgresAddClientSQL,
+	GetClientSQL:                 GetClientSQL,
+	GetClientByLoginSQL:          GetClientByLoginSQL,
+	GetClientByPhoneNumberSQL:    GetClientByPhoneNumberSQL,
+	UpdateClientPasswordSQL:      UpdateClientPasswordSQL,
+	ChangeClientStatusByIdSQL:    ChangeClientStatusByIdSQL,
+	UpdateListOfClientsSQL:       UpdateListOfClientsSQL,
+	GetListOfClientsSQL:          GetListOfClientsSQL,
+	GetListOfClientsFormattedSQL: PostgresGetListOfClientsFormattedSQL,
+	GetClientsAfterSQL:           GetClientsAfterSQL,
+	GetClientsBeforeSQL:          GetClientsBeforeSQL,
+	CountClientsSQL:              CountClientsSQL,
+	SearchClientByName:           PostgresSearchClientByName,
+	SearchClientByPhoneNumber:    PostgresSearchClientByPhoneNumber,
+
+	AddAccountSQL:                        PostgresAddAccountSQL,
+	GetAccountSQL:                        GetAccountSQL,
+	GetClientAccountIdByCurrencySQL:      GetClientAccountIdByCurrencySQL,
+	GetClientAccountsSQL:                 GetClientAccountsSQL,
+	GetListOfAccountsSQL:                 GetListOfAccountsSQL,
+	UpdateClientBalanceSQL:               UpdateClientBalanceSQL,
+	UpdateListOfAccountsWithClientIdsSQL: UpdateListOfAccountsWithClientIdsSQL,
+
+	AddServiceSQL:                   AddServiceSQL,
+	ServiceExistSQL:                 ServiceExistSQL,
+	GetServiceProviderSQL:           GetServiceProviderSQL,
+	SetServiceProviderSQL:           SetServiceProviderSQL,
+	AddCatalogServiceSQL:            AddCatalogServiceSQL,
+	GetCatalogServiceSQL:            GetCatalogServiceSQL,
+	GetCatalogServicesSQL:           GetCatalogServicesSQL,
+	GetCatalogServicesByCategorySQL: GetCatalogServicesByCategorySQL,
+	AddAtmSQL:                       AddAtmSQL,
+	AtmExistSQL:                     AtmExistSQL,
+	GetAllATMsSQL:                   GetAllATMsSQL,
+	UpdateListOfATMsSQL:             PostgresUpdateListOfATMsSQL,
+
+	AddToJournalSQL:              PostgresAddToJournalSQL,
+	GetJournalListSQL:            GetJournalListSQL,
+	CountJournalSQL:              CountJournalSQL,
+	JournalFromFilterSQL:         JournalFromFilterSQL,
+	JournalToFilterSQL:           JournalToFilterSQL,
+	JournalTypeFilterSQL:         JournalTypeFilterSQL,
+	JournalCounterpartyFilterSQL: JournalCounterpartyFilterSQL,
+	JournalAccountFilterSQL:      JournalAccountFilterSQL,
+	JournalMinAmountFilterSQL:    JournalMinAmountFilterSQL,
+	JournalMaxAmountFilterSQL:    JournalMaxAmountFilterSQL,
+	JournalBeforeSQL:             JournalBeforeSQL,
+	JournalAfterSQL:              JournalAfterSQL,
+	JournalOrderAscSQL:           JournalOrderAscSQL,
+	JournalOrderDescSQL:          JournalOrderDescSQL,
+	JournalPageSQL:               PostgresJournalPageSQL,
+
+	AddPostingSQL:               PostgresAddPostingSQL,
+	AddLedgerEntrySQL:           AddLedgerEntrySQL,
+	GetAccountLedgerBalancesSQL: GetAccountLedgerBalancesSQL,
+	GetUnbalancedPostingsSQL:    GetUnbalancedPostingsSQL,
+	GetAccountsOutOfLedgerSQL:   GetAccountsOutOfLedgerSQL,
+	GetAccountsWithoutLedgerSQL: GetAccountsWithoutLedgerSQL,
+	GetProvidersOutOfLedgerSQL:  GetProvidersOutOfLedgerSQL,
+	GetSystemAccountTotalsSQL:   GetSystemAccountTotalsSQL,
+
+	GetLoginAttemptsSQL:    GetLoginAttemptsSQL,
+	SaveFailedLoginSQL:     SaveFailedLoginSQL,
+	SaveSuccessfulLoginSQL: SaveSuccessfulLoginSQL,
+	ResetLoginAttemptsSQL:  ResetLoginAttemptsSQL,
+
+	AddSessionSQL:              PostgresAddSessionSQL,
+	GetSessionByTokenHashSQL:   GetSessionByTokenHashSQL,
+	RevokeSessionSQL:           RevokeSessionSQL,
+	RevokeAllClientSessionsSQL: RevokeAllClientSessionsSQL,
+
+	AddExchangeRateSQL: AddExchangeRateSQL,
+	GetExchangeRateSQL: GetExchangeRateSQL,
+
+	AddIdempotencyKeySQL:           AddIdempotencyKeySQL,
+	GetIdempotencyKeySQL:           GetIdempotencyKeySQL,
+	DeleteIdempotencyKeysBeforeSQL: DeleteIdempotencyKeysBeforeSQL,
+
+	GetJournalSQL:        GetJournalSQL,
+	GetJournalPostingSQL: GetJournalPostingSQL,
+	GetLedgerEntriesSQL:  GetLedgerEntriesSQL,
+	AddReversalSQL:       AddReversalSQL,
+	GetReversalSQL:       GetReversalSQL,
+
+	AddProviderSQL:           PostgresAddProviderSQL,
+	GetProviderSQL:           GetProviderSQL,
+	GetProviderByNameSQL:     GetProviderByNameSQL,
+	GetListOfProvidersSQL:    GetListOfProvidersSQL,
+	UpdateProviderBalanceSQL: UpdateProviderBalanceSQL,
+
+	SyncIdSQL: PostgresSyncIdSQL,
+}
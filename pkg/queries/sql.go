@@ -40,11 +40,321 @@ const AtmsDDL = `CREATE TABLE IF NOT EXISTS atms
     location TEXT NOT NULL UNIQUE
 );`
 
+const LoginAttemptsDDL = `CREATE TABLE IF NOT EXISTS login_attempts
+(
+    client_id       INTEGER PRIMARY KEY REFERENCES clients,
+    failed_count    INTEGER NOT NULL DEFAULT 0,
+    first_failed_at TEXT,
+    locked_until    TEXT,
+    last_login_at   TEXT
+);`
+
+const SessionsDDL = `CREATE TABLE IF NOT EXISTS sessions
+(
+    id         INTEGER PRIMARY KEY AUTOINCREMENT,
+    token_hash TEXT    NOT NULL UNIQUE,
+    client_id  INTEGER NOT NULL REFERENCES clients,
+    device     TEXT    NOT NULL,
+    created_at TEXT    NOT NULL,
+    expires_at TEXT    NOT NULL,
+    revoked_at TEXT
+);`
+
+const ExchangeRatesDDL = `CREATE TABLE IF NOT EXISTS exchange_rates
+(
+    id             INTEGER PRIMARY KEY AUTOINCREMENT,
+    base_currency  TEXT    NOT NULL,
+    quote_currency TEXT    NOT NULL,
+    buy_rate       INTEGER NOT NULL check ( buy_rate > 0 ),
+    sell_rate      INTEGER NOT NULL check ( sell_rate > 0 ),
+    effective_at   TEXT    NOT NULL
+);`
+
+const ExchangeRatesIndexDDL = `CREATE INDEX IF NOT EXISTS exchange_rates_pair_idx
+    ON exchange_rates (base_currency, quote_currency, effective_at);`
+
+const PostingsDDL = `CREATE TABLE IF NOT EXISTS postings
+(
+    id         INTEGER PRIMARY KEY AUTOINCREMENT,
+    journal_id INTEGER REFERENCES journal,
+    type       TEXT    NOT NULL,
+    created_at TEXT    NOT NULL
+);`
+
+const LedgerEntriesDDL = `CREATE TABLE IF NOT EXISTS ledger_entries
+(
+    id             INTEGER PRIMARY KEY AUTOINCREMENT,
+    posting_id     INTEGER NOT NULL REFERENCES postings,
+    account_id     INTEGER REFERENCES accounts,
+    system_account TEXT,
+    amount         INTEGER NOT NULL check ( amount <> 0 ),
+    currency       TEXT    NOT NULL,
+    check ( (account_id IS NULL) <> (system_account IS NULL) )
+);`
+
+const LedgerEntriesAccountIndexDDL = `CREATE INDEX IF NOT EXISTS ledger_entries_account_idx
+    ON ledger_entries (account_id);`
+
+const JournalClientDateIndexDDL = `CREATE INDEX IF NOT EXISTS journal_client_date_idx
+    ON journal (client_id, date, id);`
+
+const JournalClientTypeIndexDDL = `CREATE INDEX IF NOT EXISTS journal_client_type_idx
+    ON journal (client_id, type, date);`
+
+const JournalClientAccountIndexDDL = `CREATE INDEX IF NOT EXISTS journal_client_account_idx
+    ON journal (client_id, account_id, date);`
+
+const JournalClientCounterpartyIndexDDL = `CREATE INDEX IF NOT EXISTS journal_client_counterparty_idx
+    ON journal (client_id, counterparty, date);`
+
+const JournalClientAmountIndexDDL = `CREATE INDEX IF NOT EXISTS journal_client_amount_idx
+    ON journal (client_id, currency, amount);`
+
+const IdempotencyKeysDDL = `CREATE TABLE IF NOT EXISTS idempotency_keys
+(
+    client_id       INTEGER NOT NULL REFERENCES clients,
+    idempotency_key TEXT    NOT NULL,
+    request         TEXT    NOT NULL,
+    journal_id      INTEGER NOT NULL REFERENCES journal,
+    amount          INTEGER NOT NULL,
+    currency        TEXT    NOT NULL,
+    created_at      TEXT    NOT NULL,
+    PRIMARY KEY (client_id, idempotency_key)
+);`
+
+const IdempotencyKeysCreatedAtIndexDDL = `CREATE INDEX IF NOT EXISTS idempotency_keys_created_at_idx
+    ON idempotency_keys (created_at);`
+
+const ReversalsDDL = `CREATE TABLE IF NOT EXISTS reversals
+(
+    journal_id          INTEGER PRIMARY KEY REFERENCES journal,
+    reversal_journal_id INTEGER NOT NULL REFERENCES journal,
+    reason              TEXT    NOT NULL,
+    created_at          TEXT    NOT NULL
+);`
+
+const PostingsJournalIndexDDL = `CREATE INDEX IF NOT EXISTS postings_journal_idx
+    ON postings (journal_id);`
+
+const ProvidersDDL = `CREATE TABLE IF NOT EXISTS providers
+(
+    id       INTEGER PRIMARY KEY AUTOINCREMENT,
+    name     TEXT    NOT NULL UNIQUE,
+    balance  INTEGER NOT NULL DEFAULT 0 check ( balance >= 0 ),
+    currency TEXT    NOT NULL
+);`
+
+const LedgerEntriesSystemAccountIndexDDL = `CREATE INDEX IF NOT EXISTS ledger_entries_system_account_idx
+    ON ledger_entries (system_account);`
+
+const SchemaVersionDDL = `CREATE TABLE IF NOT EXISTS schema_version
+(
+    version     INTEGER PRIMARY KEY,
+    description TEXT NOT NULL,
+    applied_at  TEXT NOT NULL
+);`
+
+const GetSchemaVersionSQL = `SELECT COALESCE(MAX(version), 0)
+FROM schema_version;`
+
+const AddSchemaVersionSQL = `INSERT INTO schema_version(version, description, applied_at)
+VALUES (:version, :description, :applied_at);`
+
+const DeleteSchemaVersionSQL = `DELETE
+FROM schema_version
+WHERE version = :version;`
+
+// BeginImmediateSQL starts a transaction holding the write lock of the
+// database, CommitSQL or RollbackSQL ends it.
+const BeginImmediateSQL = `BEGIN IMMEDIATE;`
+
+const CommitSQL = `COMMIT;`
+
+const RollbackSQL = `ROLLBACK;`
+
+const DropInitialSchemaSQL = `DROP TABLE atms;
+DROP TABLE services;
+DROP TABLE journal;
+DROP TABLE accounts;
+DROP TABLE clients;`
+
+const DropSessionsSQL = `DROP TABLE sessions;
+DROP TABLE login_attempts;`
+
+const DropCurrenciesSQL = `DROP TABLE exchange_rates;
+CREATE TABLE accounts_new
+(
+    id        INTEGER PRIMARY KEY AUTOINCREMENT,
+    client_id INTEGER NOT NULL REFERENCES clients,
+    balance   INTEGER NOT NULL check ( balance >= 0 )
+);
+INSERT INTO accounts_new(id, client_id, balance)
+SELECT id, client_id, balance
+FROM accounts;
+DROP TABLE accounts;
+ALTER TABLE accounts_new RENAME TO accounts;
+CREATE TABLE journal_new
+(
+    id             INTEGER PRIMARY KEY AUTOINCREMENT,
+    date           TEXT    NOT NULL,
+    client_id      INTEGER NOT NULL REFERENCES clients,
+    type           TEXT    NOT NULL,
+    transferred_to TEXT    NOT NULL,
+    amount         INTEGER NOT NULL check ( amount > 0 )
+);
+INSERT INTO journal_new(id, date, client_id, type, transferred_to, amount)
+SELECT id, date, client_id, type, transferred_to, amount
+FROM journal;
+DROP TABLE journal;
+ALTER TABLE journal_new RENAME TO journal;`
+
+const DropLedgerSQL = `DROP TABLE ledger_entries;
+DROP TABLE postings;`
+
+const DropJournalPartiesSQL = `CREATE TABLE journal_new
+(
+    id             INTEGER PRIMARY KEY AUTOINCREMENT,
+    date           TEXT    NOT NULL,
+    client_id      INTEGER NOT NULL REFERENCES clients,
+    type           TEXT    NOT NULL,
+    transferred_to TEXT    NOT NULL,
+    amount         INTEGER NOT NULL check ( amount > 0 ),
+    currency       TEXT    NOT NULL DEFAULT 'TJS',
+    rate           INTEGER
+);
+INSERT INTO journal_new(id, date, client_id, type, transferred_to, amount, currency, rate)
+SELECT id, date, client_id, type, transferred_to, amount, currency, rate
+FROM journal
+WHERE direction = 'outgoing';
+DROP TABLE journal;
+ALTER TABLE journal_new RENAME TO journal;`
+
+const DropJournalIndexesSQL = `DROP INDEX journal_client_date_idx;
+DROP INDEX journal_client_type_idx;
+DROP INDEX journal_client_account_idx;
+DROP INDEX journal_client_counterparty_idx;
+DROP INDEX journal_client_amount_idx;`
+
+const DropIdempotencyKeysSQL = `DROP TABLE idempotency_keys;`
+
+const DropReversalsSQL = `DROP INDEX postings_journal_idx;
+DROP TABLE reversals;`
+
+const DropProvidersSQL = `DROP INDEX ledger_entries_system_account_idx;
+CREATE TABLE services_new
+(
+    id   INTEGER PRIMARY KEY AUTOINCREMENT,
+    name TEXT    NOT NULL UNIQUE
+);
+INSERT INTO services_new(id, name)
+SELECT id, name
+FROM services;
+DROP TABLE services;
+ALTER TABLE services_new RENAME TO services;
+DROP TABLE providers;`
+
+const DropServiceCatalogSQL = `CREATE TABLE services_new
+(
+    id          INTEGER PRIMARY KEY AUTOINCREMENT,
+    name        TEXT    NOT NULL UNIQUE,
+    provider_id INTEGER REFERENCES providers
+);
+INSERT INTO services_new(id, name, provider_id)
+SELECT id, name, provider_id
+FROM services;
+DROP TABLE services;
+ALTER TABLE services_new RENAME TO services;
+CREATE TABLE journal_new
+(
+    id                      INTEGER PRIMARY KEY AUTOINCREMENT,
+    date                    TEXT    NOT NULL,
+    client_id               INTEGER NOT NULL REFERENCES clients,
+    type                    TEXT    NOT NULL,
+    transferred_to          TEXT    NOT NULL,
+    amount                  INTEGER NOT NULL check ( amount > 0 ),
+    currency                TEXT    NOT NULL DEFAULT 'TJS',
+    rate                    INTEGER,
+    direction               TEXT    NOT NULL DEFAULT 'outgoing',
+    account_id              INTEGER REFERENCES accounts,
+    counterparty            TEXT,
+    counterparty_account_id INTEGER REFERENCES accounts
+);
+INSERT INTO journal_new(id, date, client_id, type, transferred_to, amount, currency, rate, direction, account_id,
+                        counterparty, counterparty_account_id)
+SELECT id, date, client_id, type, transferred_to, amount, currency, rate, direction, account_id,
+       counterparty, counterparty_account_id
+FROM journal;
+DROP TABLE journal;
+ALTER TABLE journal_new RENAME TO journal;`
+
+const ColumnExistSQL = `SELECT COUNT(*)
+FROM pragma_table_info(?)
+WHERE name = ?;`
+
+const AccountsCurrencyColumnSQL = `ALTER TABLE accounts
+    ADD COLUMN currency TEXT NOT NULL DEFAULT 'TJS';`
+
+const JournalCurrencyColumnSQL = `ALTER TABLE journal
+    ADD COLUMN currency TEXT NOT NULL DEFAULT 'TJS';`
+
+const JournalRateColumnSQL = `ALTER TABLE journal
+    ADD COLUMN rate INTEGER;`
+
+const ServicesProviderIdColumnSQL = `ALTER TABLE services
+    ADD COLUMN provider_id INTEGER REFERENCES providers;`
+
+const ServiceCatalogColumnsSQL = `ALTER TABLE services
+    ADD COLUMN category TEXT NOT NULL DEFAULT '';
+ALTER TABLE services
+    ADD COLUMN currency TEXT;
+ALTER TABLE services
+    ADD COLUMN min_amount INTEGER;
+ALTER TABLE services
+    ADD COLUMN max_amount INTEGER;
+ALTER TABLE services
+    ADD COLUMN fixed_fee INTEGER;
+ALTER TABLE services
+    ADD COLUMN fee_percent INTEGER;
+ALTER TABLE services
+    ADD COLUMN reference_pattern TEXT;`
+
+const JournalPaymentColumnsSQL = `ALTER TABLE journal
+    ADD COLUMN reference TEXT;
+ALTER TABLE journal
+    ADD COLUMN fee INTEGER;`
+
+const JournalDirectionColumnSQL = `ALTER TABLE journal
+    ADD COLUMN direction TEXT NOT NULL DEFAULT 'outgoing';`
+
+const JournalAccountIdColumnSQL = `ALTER TABLE journal
+    ADD COLUMN account_id INTEGER REFERENCES accounts;`
+
+const JournalCounterpartyColumnSQL = `ALTER TABLE journal
+    ADD COLUMN counterparty TEXT;`
+
+const JournalCounterpartyAccountIdColumnSQL = `ALTER TABLE journal
+    ADD COLUMN counterparty_account_id INTEGER REFERENCES accounts;`
+
+const GetLegacyJournalDatesSQL = `SELECT id, date
+FROM journal
+WHERE date GLOB '[0-9][0-9]-[0-9][0-9]-[0-9][0-9][0-9][0-9] [0-9][0-9]:[0-9][0-9]:[0-9][0-9]';`
+
+const UpdateLegacyJournalCounterpartySQL = `UPDATE journal
+SET counterparty = transferred_to
+WHERE counterparty IS NULL;`
+
+const GetJournalDatesSQL = `SELECT id, date
+FROM journal;`
+
+const UpdateJournalDateSQL = `UPDATE journal
+SET date = :date
+WHERE id = :id;`
+
 const AddClientSQL = `INSERT INTO clients(name, login, password, phone_number, status)
 VALUES (:name, :login, :password, :phone_number, 'active');`
 
-const AddAccountSQL = `INSERT INTO accounts(client_id, balance)
-VALUES (:client_id, :balance);`
+const AddAccountSQL = `INSERT INTO accounts(client_id, balance, currency)
+VALUES (:client_id, :balance, :currency);`
 
 const AddServiceSQL = `INSERT INTO services(name)
 VALUES (:name);`
@@ -52,18 +362,32 @@ VALUES (:name);`
 const AddAtmSQL = `INSERT INTO atms(name, location)
 VALUES (:name, :location);`
 
-const AddToJournalSQL = `INSERT INTO journal(date, client_id, type, transferred_to, amount)
-VALUES (:date, :client_id, :type, :transferred_to, :amount);`
+const AddToJournalSQL = `INSERT INTO journal(date, client_id, type, direction, account_id, counterparty, counterparty_account_id,
+                    transferred_to, amount, currency, rate, reference, fee)
+VALUES (:date, :client_id, :type, :direction, :account_id, :counterparty, :counterparty_account_id,
+        :transferred_to, :amount, :currency, :rate, :reference, :fee);`
+
+const AddPostingSQL = `INSERT INTO postings(journal_id, type, created_at)
+VALUES (:journal_id, :type, :created_at);`
+
+const AddLedgerEntrySQL = `INSERT INTO ledger_entries(posting_id, account_id, system_account, amount, currency)
+VALUES (:posting_id, :account_id, :system_account, :amount, :currency);`
+
+const AddExchangeRateSQL = `INSERT INTO exchange_rates(base_currency, quote_currency, buy_rate, sell_rate, effective_at)
+VALUES (:base_currency, :quote_currency, :buy_rate, :sell_rate, :effective_at);`
+
+const AddSessionSQL = `INSERT INTO sessions(token_hash, client_id, device, created_at, expires_at)
+VALUES (:token_hash, :client_id, :device, :created_at, :expires_at);`
 
-const LoginSQL = `SELECT login, password, phone_number, status
+const GetClientSQL = `SELECT id, name, login, password, phone_number, status
 FROM clients
-WHERE login = ?;`
+WHERE id = ?;`
 
-const LoginExistSQL = `SELECT login
+const GetClientByLoginSQL = `SELECT id, name, login, password, phone_number, status
 FROM clients
 WHERE login = ?;`
 
-const PhoneNumberExistSQL = `SELECT phone_number
+const GetClientByPhoneNumberSQL = `SELECT id, name, login, password, phone_number, status
 FROM clients
 WHERE phone_number = ?;`
 
@@ -75,50 +399,132 @@ const AtmExistSQL = `SELECT location
 FROM atms
 WHERE location = ?;`
 
-const GetClientIdByPhoneNumberSQL = `SELECT id
-FROM clients
-WHERE phone_number = ?;`
+const GetAccountSQL = `SELECT id, client_id, balance, currency
+FROM accounts
+WHERE id = ?;`
 
-const GetClientIdByLoginSQL = `SELECT id
-FROM clients
-WHERE login = ?;`
+const GetAccountLedgerBalancesSQL = `SELECT currency, SUM(amount)
+FROM ledger_entries
+WHERE account_id = ?
+GROUP BY currency
+ORDER BY currency;`
+
+const GetUnbalancedPostingsSQL = `SELECT posting_id, currency, SUM(amount)
+FROM ledger_entries
+GROUP BY posting_id, currency
+HAVING SUM(amount) <> 0
+ORDER BY posting_id;`
+
+const GetAccountsOutOfLedgerSQL = `SELECT a.id, a.client_id, a.balance, a.currency, COALESCE(SUM(e.amount), 0)
+FROM accounts a
+         LEFT JOIN ledger_entries e ON e.account_id = a.id AND e.currency = a.currency
+GROUP BY a.id, a.client_id, a.balance, a.currency
+HAVING a.balance <> COALESCE(SUM(e.amount), 0)
+ORDER BY a.id;`
+
+const GetAccountsWithoutLedgerSQL = `SELECT a.id, a.balance, a.currency
+FROM accounts a
+WHERE a.balance <> 0
+  AND NOT EXISTS(SELECT 1 FROM ledger_entries e WHERE e.account_id = a.id)
+ORDER BY a.id;`
+
+const GetClientAccountsSQL = `SELECT id, balance, currency
+FROM accounts
+WHERE client_id = ?
+ORDER BY id;`
 
-const GetClientIdByAccountSQL = `SELECT client_id
+const GetListOfAccountsSQL = `SELECT id, client_id, balance, currency
 FROM accounts
-WHERE id = ?;`
+ORDER BY id;`
 
-const GetClientStatusSQL = `SELECT status
+const GetListOfClientsSQL = `SELECT id, name, login, password, phone_number, status
 FROM clients
-WHERE id = ?;`
+ORDER BY id;`
 
-const GetClientStatusByPhoneNumberSQL = `SELECT status
-FROM clients
-WHERE phone_number = ?;`
+const GetListOfClientsFormattedSQL = `SELECT id, name, login, password, phone_number, status
+FROM clients ORDER BY name DESC LIMIT ? OFFSET ?;`
 
-const GetClientAccountsSQL = `SELECT id, balance
-FROM accounts
-WHERE client_id = ?;`
+const GetClientsAfterSQL = `SELECT id, name, login, password, phone_number, status
+FROM clients
+WHERE id > ?
+ORDER BY id
+LIMIT ?;`
 
-const GetListOfAccountsSQL = `SELECT id, client_id, balance
-FROM accounts;`
+const GetClientsBeforeSQL = `SELECT id, name, login, password, phone_number, status
+FROM clients
+WHERE id < ?
+ORDER BY id DESC
+LIMIT ?;`
 
-const GetListOfClientsSQL = `SELECT id, name, login, password, phone_number, status
+const CountClientsSQL = `SELECT COUNT(*)
 FROM clients;`
 
-const GetListOfClientsFormattedSQL = `SELECT id, name, login, password, phone_number, status
-FROM clients ORDER BY name DESC LIMIT ? OFFSET ?;`
+const GetJournalListSQL = `SELECT id, date, type, direction, COALESCE(account_id, 0),
+       COALESCE(counterparty, transferred_to), COALESCE(counterparty_account_id, 0),
+       transferred_to, amount, currency, rate, COALESCE(reference, ''), fee
+FROM journal
+WHERE client_id = ?`
 
-const GetJournalListFormattedSQL = `SELECT id, date, type, transferred_to, amount
+const CountJournalSQL = `SELECT COUNT(*)
 FROM journal
-WHERE client_id = ? ORDER BY date
+WHERE client_id = ?`
+
+const JournalFromFilterSQL = `
+  AND date >= ?`
+
+const JournalToFilterSQL = `
+  AND date < ?`
+
+const JournalTypeFilterSQL = `
+  AND type IN (%s)`
+
+const JournalCounterpartyFilterSQL = `
+  AND counterparty = ?`
+
+const JournalAccountFilterSQL = `
+  AND account_id = ?`
+
+const JournalMinAmountFilterSQL = `
+  AND currency = ?
+  AND amount >= ?`
+
+const JournalMaxAmountFilterSQL = `
+  AND currency = ?
+  AND amount <= ?`
+
+const JournalOrderAscSQL = `
+ORDER BY date, id`
+
+const JournalOrderDescSQL = `
+ORDER BY date DESC, id DESC`
+
+const JournalAfterSQL = `
+  AND (date, id) > (?, ?)`
+
+const JournalBeforeSQL = `
+  AND (date, id) < (?, ?)`
+
+const JournalPageSQL = `
 LIMIT ? OFFSET ?;`
 
-const GetClientAccountIdSQL = `SELECT id
+const GetClientAccountIdByCurrencySQL = `SELECT id
 FROM accounts
-WHERE client_id = ? LIMIT 1;`
+WHERE client_id = ?
+  AND currency = ?
+ORDER BY id
+LIMIT 1;`
+
+const GetExchangeRateSQL = `SELECT base_currency, quote_currency, buy_rate, sell_rate, effective_at
+FROM exchange_rates
+WHERE base_currency = ?
+  AND quote_currency = ?
+  AND effective_at <= ?
+ORDER BY effective_at DESC, id DESC
+LIMIT 1;`
 
 const GetAllATMsSQL = `SELECT id, name, location
-FROM atms;`
+FROM atms
+ORDER BY id;`
 
 const UpdateClientBalanceSQL = `UPDATE accounts
 SET balance = balance + :amount
@@ -126,27 +532,170 @@ WHERE id = :id;`
 
 const UpdateListOfClientsSQL = `INSERT INTO clients (id, name, login, password, phone_number, status)
 VALUES (:id, :name, :login, :password, :phone_number, :status)
-ON CONFLICT (login)
+ON CONFLICT (id)
     DO UPDATE SET name=excluded.name,
                   login=excluded.login,
                   password=excluded.password,
                   phone_number=excluded.phone_number,
                   status=excluded.status;`
 
-const UpdateListOfAccountsWithClientIdsSQL = `INSERT INTO accounts (id, client_id, balance)
-VALUES (:id, :client_id, :balance)
+const UpdateListOfAccountsWithClientIdsSQL = `INSERT INTO accounts (id, client_id, balance, currency)
+VALUES (:id, :client_id, :balance, :currency)
 ON CONFLICT (id)
     DO UPDATE SET id=excluded.id,
                   client_id=excluded.client_id,
-                  balance=excluded.balance;`
+                  balance=excluded.balance,
+                  currency=excluded.currency;`
 
 const UpdateListOfATMsSQL = `INSERT OR
 REPLACE INTO atms (id, name, location)
 VALUES (:id, :name, :location);`
 
-const ChangeClientStatusSQL = `UPDATE clients
+const UpdateClientPasswordSQL = `UPDATE clients
+SET password = :password
+WHERE login = :login;`
+
+const GetLoginAttemptsSQL = `SELECT failed_count, first_failed_at, locked_until, last_login_at
+FROM login_attempts
+WHERE client_id = ?;`
+
+const SaveFailedLoginSQL = `INSERT INTO login_attempts(client_id, failed_count, first_failed_at, locked_until)
+VALUES (:client_id, :failed_count, :first_failed_at, :locked_until)
+ON CONFLICT (client_id)
+    DO UPDATE SET failed_count=excluded.failed_count,
+                  first_failed_at=excluded.first_failed_at,
+                  locked_until=excluded.locked_until;`
+
+const SaveSuccessfulLoginSQL = `INSERT INTO login_attempts(client_id, failed_count, last_login_at)
+VALUES (:client_id, 0, :last_login_at)
+ON CONFLICT (client_id)
+    DO UPDATE SET failed_count=0,
+                  first_failed_at=NULL,
+                  locked_until=NULL,
+                  last_login_at=excluded.last_login_at;`
+
+const ResetLoginAttemptsSQL = `UPDATE login_attempts
+SET failed_count    = 0,
+    first_failed_at = NULL,
+    locked_until    = NULL
+WHERE client_id = :client_id;`
+
+const ChangeClientStatusByIdSQL = `UPDATE clients
 SET status = :status
-WHERE phone_number = :phone_number;`
+WHERE id = :id;`
+
+const GetSessionByTokenHashSQL = `SELECT id, client_id, device, created_at, expires_at, revoked_at
+FROM sessions
+WHERE token_hash = ?;`
+
+const RevokeSessionSQL = `UPDATE sessions
+SET revoked_at = :revoked_at
+WHERE token_hash = :token_hash
+  AND revoked_at IS NULL;`
+
+const RevokeAllClientSessionsSQL = `UPDATE sessions
+SET revoked_at = :revoked_at
+WHERE client_id = :client_id
+  AND revoked_at IS NULL;`
+
+const AddIdempotencyKeySQL = `INSERT INTO idempotency_keys(client_id, idempotency_key, request, journal_id, amount, currency, created_at)
+VALUES (:client_id, :idempotency_key, :request, :journal_id, :amount, :currency, :created_at);`
+
+const GetIdempotencyKeySQL = `SELECT request, journal_id, amount, currency, created_at
+FROM idempotency_keys
+WHERE client_id = ?
+  AND idempotency_key = ?;`
+
+const DeleteIdempotencyKeysBeforeSQL = `DELETE
+FROM idempotency_keys
+WHERE created_at < ?;`
+
+const GetJournalSQL = `SELECT client_id, id, date, type, direction, COALESCE(account_id, 0),
+       COALESCE(counterparty, transferred_to), COALESCE(counterparty_account_id, 0),
+       transferred_to, amount, currency, rate, COALESCE(reference, ''), fee
+FROM journal
+WHERE id = ?;`
+
+const GetJournalPostingSQL = `SELECT id, type, created_at
+FROM postings
+WHERE journal_id = ?
+ORDER BY id
+LIMIT 1;`
+
+const GetLedgerEntriesSQL = `SELECT COALESCE(account_id, 0), COALESCE(system_account, ''), amount, currency
+FROM ledger_entries
+WHERE posting_id = ?
+ORDER BY id;`
+
+const AddReversalSQL = `INSERT INTO reversals(journal_id, reversal_journal_id, reason, created_at)
+VALUES (:journal_id, :reversal_journal_id, :reason, :created_at);`
+
+const GetReversalSQL = `SELECT reversal_journal_id, reason, created_at
+FROM reversals
+WHERE journal_id = ?;`
+
+const AddCatalogServiceSQL = `INSERT INTO services(name, category, currency, min_amount, max_amount, fixed_fee, fee_percent,
+                     reference_pattern)
+VALUES (:name, :category, :currency, :min_amount, :max_amount, :fixed_fee, :fee_percent, :reference_pattern);`
+
+const GetCatalogServiceSQL = `SELECT name, category, COALESCE(currency, ''), COALESCE(min_amount, 0), COALESCE(max_amount, 0),
+       COALESCE(fixed_fee, 0), COALESCE(fee_percent, 0), COALESCE(reference_pattern, '')
+FROM services
+WHERE name = ?;`
+
+const GetCatalogServicesSQL = `SELECT name, category, COALESCE(currency, ''), COALESCE(min_amount, 0), COALESCE(max_amount, 0),
+       COALESCE(fixed_fee, 0), COALESCE(fee_percent, 0), COALESCE(reference_pattern, '')
+FROM services
+ORDER BY id;`
+
+const GetCatalogServicesByCategorySQL = `SELECT name, category, COALESCE(currency, ''), COALESCE(min_amount, 0), COALESCE(max_amount, 0),
+       COALESCE(fixed_fee, 0), COALESCE(fee_percent, 0), COALESCE(reference_pattern, '')
+FROM services
+WHERE category = ?
+ORDER BY id;`
+
+const AddProviderSQL = `INSERT INTO providers(name, currency)
+VALUES (:name, :currency);`
+
+const GetProviderSQL = `SELECT id, name, balance, currency
+FROM providers
+WHERE id = ?;`
+
+const GetProviderByNameSQL = `SELECT id, name, balance, currency
+FROM providers
+WHERE name = ?;`
+
+const GetListOfProvidersSQL = `SELECT id, name, balance, currency
+FROM providers
+ORDER BY id;`
+
+const UpdateProviderBalanceSQL = `UPDATE providers
+SET balance = balance + :amount
+WHERE id = :id;`
+
+const GetServiceProviderSQL = `SELECT COALESCE(provider_id, 0)
+FROM services
+WHERE name = ?;`
+
+const SetServiceProviderSQL = `UPDATE services
+SET provider_id = :provider_id
+WHERE name = :name;`
+
+const GetProvidersOutOfLedgerSQL = `SELECT p.id, p.name, p.balance, p.currency, COALESCE(SUM(e.amount), 0)
+FROM providers p
+         LEFT JOIN ledger_entries e ON e.system_account = 'provider:' || p.id AND e.currency = p.currency
+GROUP BY p.id, p.name, p.balance, p.currency
+HAVING p.balance <> COALESCE(SUM(e.amount), 0)
+ORDER BY p.id;`
+
+const GetSystemAccountTotalsSQL = `SELECT p.type, e.currency, COUNT(*), SUM(e.amount)
+FROM ledger_entries e
+         JOIN postings p ON p.id = e.posting_id
+WHERE e.system_account = ?
+  AND p.created_at >= ?
+  AND p.created_at < ?
+GROUP BY p.type, e.currency
+ORDER BY p.type, e.currency;`
 
 const SearchClientByName = `SELECT id, name, login, password, phone_number, status
 FROM clients
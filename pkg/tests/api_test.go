@@ -66,7 +66,7 @@ func TestAddAccount(t *testing.T) {
 		t.Errorf("unexpected error: %v", err)
 	}
 
-	err = core.AddAccount(99999999, 200000, db)
+	err = core.AddAccount(99999999, core.NewMoney(20000000, core.DefaultCurrency), db)
 
 	if err == nil {
 		t.Errorf("expected error found: %v", err)
@@ -78,7 +78,7 @@ func TestAddAccount(t *testing.T) {
 		t.Errorf("unexpected error at AddClient: %v", err)
 	}
 
-	err = core.AddAccount(999999999, 200000, db)
+	err = core.AddAccount(999999999, core.NewMoney(20000000, core.DefaultCurrency), db)
 
 	if err != nil {
 		t.Errorf( "unexpected error at AddAccount: %v", err)
@@ -214,7 +214,7 @@ func TestGetListOfClientAccounts(t *testing.T) {
 		t.Errorf("unexpected error at AddClient: %v", err)
 	}
 
-	err = core.AddAccount(999999999, 20000, db)
+	err = core.AddAccount(999999999, core.NewMoney(2000000, core.DefaultCurrency), db)
 	if err != nil {
 		t.Errorf("unexpected error at AddAccount: %v", err)
 	}
@@ -480,7 +480,7 @@ func TestGetListOfAccountsWithClients(t *testing.T) {
 		t.Errorf("unexpected error at AddClient: %v", err)
 	}
 
-	err = core.AddAccount(999999999, 20000, db)
+	err = core.AddAccount(999999999, core.NewMoney(2000000, core.DefaultCurrency), db)
 	if err != nil {
 		t.Errorf("unexpected error at AddAccount: %v", err)
 	}
@@ -511,7 +511,7 @@ func TestPayForService(t *testing.T) {
 		t.Errorf("unexpected error: %v", err)
 	}
 
-	err = core.PayForService("", 0, "", 0, db)
+	err = core.PayForService("", 0, core.Session{}, core.NewMoney(100, core.DefaultCurrency), db)
 	if ok := errors.Is(err, core.ErrServiceNotExist); !ok {
 		t.Errorf("expected error: %v, found: %v", core.ErrServiceNotExist, err)
 	}
@@ -526,12 +526,17 @@ func TestPayForService(t *testing.T) {
 		t.Errorf("unexpected error at AddClient: %v", err)
 	}
 
-	err = core.AddAccount(999999999, 20000, db)
+	err = core.AddAccount(999999999, core.NewMoney(2000000, core.DefaultCurrency), db)
 	if err != nil {
 		t.Errorf("unexpected error at AddAccount: %v", err)
 	}
 
-	err = core.PayForService("Water", 1, "vasya", 2000, db)
+	session, err := core.StartSession("vasya", "1234", "test", db)
+	if err != nil {
+		t.Errorf("unexpected error at StartSession: %v", err)
+	}
+
+	err = core.PayForService("Water", 1, session, core.NewMoney(200000, core.DefaultCurrency), db)
 	if err != nil {
 		t.Error(err)
 	}
@@ -595,17 +600,22 @@ func TestTransferToByAccountId(t *testing.T) {
 		t.Errorf("unexpected error at AddClient: %v", err)
 	}
 
-	err = core.AddAccount(1234, 50000, db)
+	err = core.AddAccount(1234, core.NewMoney(5000000, core.DefaultCurrency), db)
 	if err != nil {
 		t.Errorf("unexpected error at AddAccount: %v", err)
 	}
 
-	err = core.AddAccount(5678, 0, db)
+	err = core.AddAccount(5678, core.NewMoney(0, core.DefaultCurrency), db)
 	if err != nil {
 		t.Errorf("unexpected error at AddAccount: %v", err)
 	}
 
-	err = core.TransferToByAccountId(2, "vasya1", 1, 50000, db)
+	session, err := core.StartSession("vasya1", "1234", "test", db)
+	if err != nil {
+		t.Errorf("unexpected error at StartSession: %v", err)
+	}
+
+	err = core.TransferToByAccountId(2, session, 1, core.NewMoney(5000000, core.DefaultCurrency), db)
 	if err != nil {
 		t.Errorf("expected empty error, found: %v", err)
 	}
@@ -637,17 +647,22 @@ func TestTransferToByPhoneNumber(t *testing.T) {
 		t.Errorf("unexpected error at AddClient: %v", err)
 	}
 
-	err = core.AddAccount(1234, 50000, db)
+	err = core.AddAccount(1234, core.NewMoney(5000000, core.DefaultCurrency), db)
 	if err != nil {
 		t.Errorf("unexpected error at AddAccount: %v", err)
 	}
 
-	err = core.AddAccount(5678, 0, db)
+	err = core.AddAccount(5678, core.NewMoney(0, core.DefaultCurrency), db)
 	if err != nil {
 		t.Errorf("unexpected error at AddAccount: %v", err)
 	}
 
-	err = core.TransferToByPhoneNumber(5678, "vasya1", 1, 50000, db)
+	session, err := core.StartSession("vasya1", "1234", "test", db)
+	if err != nil {
+		t.Errorf("unexpected error at StartSession: %v", err)
+	}
+
+	err = core.TransferToByPhoneNumber(5678, session, 1, core.NewMoney(5000000, core.DefaultCurrency), db)
 	if err != nil {
 		t.Errorf("expected empty error, found: %v", err)
 	}
@@ -751,17 +766,22 @@ func TestGetJournalListFormatted(t *testing.T) {
 		t.Errorf("unexpected error at AddClient: %v", err)
 	}
 
-	err = core.AddAccount(1234, 50000, db)
+	err = core.AddAccount(1234, core.NewMoney(5000000, core.DefaultCurrency), db)
 	if err != nil {
 		t.Errorf("unexpected error at AddAccount: %v", err)
 	}
 
-	err = core.AddAccount(5678, 0, db)
+	err = core.AddAccount(5678, core.NewMoney(0, core.DefaultCurrency), db)
 	if err != nil {
 		t.Errorf("unexpected error at AddAccount: %v", err)
 	}
 
-	err = core.TransferToByAccountId(2, "vasya1", 1, 50000, db)
+	session, err := core.StartSession("vasya1", "1234", "test", db)
+	if err != nil {
+		t.Errorf("unexpected error at StartSession: %v", err)
+	}
+
+	err = core.TransferToByAccountId(2, session, 1, core.NewMoney(5000000, core.DefaultCurrency), db)
 	if err != nil {
 		t.Errorf("expected empty error, found: %v", err)
 	}
@@ -0,0 +1,97 @@
+package tests
+
+import (
+	"database/sql"
+	"errors"
+	"github.com/JAbduvohidov/apm-ibank-core/pkg/core"
+	_ "github.com/mattn/go-sqlite3"
+	"testing"
+)
+
+func TestAuthenticate(t *testing.T) {
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Errorf("can't open db: %v", err)
+	}
+	defer func() {
+		if err := db.Close(); err != nil {
+			t.Errorf("can't close db: %v", err)
+		}
+	}()
+
+	err = core.Init(db)
+	if err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	err = core.AddClient("Vasya", "vasya", "1234", 999999999, db)
+	if err != nil {
+		t.Errorf("unexpected error at AddClient: %v", err)
+	}
+
+	_, err = core.Authenticate("petya", "1234", db)
+	if ok := errors.Is(err, core.ErrLoginNotFound); !ok {
+		t.Errorf("expected error: %v, found: %v", core.ErrLoginNotFound, err)
+	}
+
+	_, err = core.Authenticate("vasya", "4321", db)
+	if ok := errors.Is(err, core.ErrInvalidCredentials); !ok {
+		t.Errorf("expected error: %v, found: %v", core.ErrInvalidCredentials, err)
+	}
+
+	result, err := core.Authenticate("vasya", "1234", db)
+	if err != nil {
+		t.Errorf("unexpected error at Authenticate: %v", err)
+	}
+
+	if result.ClientId != 1 || result.PhoneNumber != 999999999 || result.Status != core.Active {
+		t.Errorf("unexpected auth result: %v", result)
+	}
+
+	if !result.LastLoginAt.IsZero() {
+		t.Errorf("first login must have zero last login time, found: %v", result.LastLoginAt)
+	}
+
+	result, err = core.Authenticate("vasya", "1234", db)
+	if err != nil {
+		t.Errorf("unexpected error at Authenticate: %v", err)
+	}
+
+	if result.LastLoginAt.IsZero() {
+		t.Errorf("second login must report previous login time")
+	}
+}
+
+func TestAuthenticateHidesUnknownLogins(t *testing.T) {
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Errorf("can't open db: %v", err)
+	}
+	defer func() {
+		if err := db.Close(); err != nil {
+			t.Errorf("can't close db: %v", err)
+		}
+	}()
+
+	core.SetHideUnknownLogins(true)
+	defer core.SetHideUnknownLogins(false)
+
+	err = core.Init(db)
+	if err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	_, err = core.Authenticate("petya", "1234", db)
+	if ok := errors.Is(err, core.ErrInvalidCredentials); !ok {
+		t.Errorf("expected error: %v, found: %v", core.ErrInvalidCredentials, err)
+	}
+
+	phoneNumber, err := core.Login("petya", "1234", db)
+	if ok := errors.Is(err, core.ErrInvalidPass); !ok {
+		t.Errorf("expected error: %v, found: %v", core.ErrInvalidPass, err)
+	}
+
+	if phoneNumber != -1 {
+		t.Errorf("expected: -1, found: %d", phoneNumber)
+	}
+}
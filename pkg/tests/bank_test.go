@@ -0,0 +1,99 @@
+package tests
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"github.com/JAbduvohidov/apm-ibank-core/pkg/core"
+	_ "github.com/mattn/go-sqlite3"
+	"testing"
+	"time"
+)
+
+func TestBankUsesItsOptions(t *testing.T) {
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Errorf("can't open db: %v", err)
+	}
+	defer func() {
+		if err := db.Close(); err != nil {
+			t.Errorf("can't close db: %v", err)
+		}
+	}()
+	db.SetMaxOpenConns(1)
+
+	date := time.Date(2020, time.March, 1, 12, 0, 0, 0, time.UTC)
+	bank := core.NewBank(
+		core.NewSQLiteStore(db),
+		core.WithClock(func() time.Time { return date }),
+		core.WithPasswordHasher(core.NewBcryptHasher(4)),
+		core.WithLimits(core.Limits{
+			MaxAmount:   map[string]int64{core.DefaultCurrency: 5000},
+			MaxPageSize: 10,
+		}),
+	)
+	ctx := context.Background()
+
+	err = bank.Init(ctx)
+	if err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	err = bank.AddClient(ctx, "Vasya", "vasya", "1234", 1234)
+	if err != nil {
+		t.Errorf("unexpected error at AddClient: %v", err)
+	}
+
+	err = bank.AddClient(ctx, "Petya", "petya", "4321", 4321)
+	if err != nil {
+		t.Errorf("unexpected error at AddClient: %v", err)
+	}
+
+	err = bank.AddAccount(ctx, 1234, core.NewMoney(10000, core.DefaultCurrency))
+	if err != nil {
+		t.Errorf("unexpected error at AddAccount: %v", err)
+	}
+
+	err = bank.AddAccount(ctx, 4321, core.NewMoney(0, core.DefaultCurrency))
+	if err != nil {
+		t.Errorf("unexpected error at AddAccount: %v", err)
+	}
+
+	session, err := bank.StartSession(ctx, "vasya", "1234", "test")
+	if err != nil {
+		t.Errorf("unexpected error at StartSession: %v", err)
+	}
+
+	err = bank.TransferToByAccountId(ctx, 2, session, 1, core.NewMoney(5001, core.DefaultCurrency))
+	if ok := errors.Is(err, core.ErrLimitExceeded); !ok {
+		t.Errorf("expected error: %v, found: %v", core.ErrLimitExceeded, err)
+	}
+
+	err = bank.TransferToByAccountId(ctx, 2, session, 1, core.NewMoney(5000, core.DefaultCurrency))
+	if err != nil {
+		t.Errorf("unexpected error at TransferToByAccountId: %v", err)
+	}
+
+	journals, err := bank.GetJournalList(ctx, core.NewJournalQuery("vasya"))
+	if err != nil {
+		t.Errorf("unexpected error at GetJournalList: %v", err)
+	}
+	if len(journals) != 1 || !journals[0].Date.Equal(date) {
+		t.Errorf("expected one entry dated %v, found: %v", date, journals)
+	}
+
+	_, err = bank.GetListOfClientsPage(ctx, core.PageRequest{Limit: 11})
+	if ok := errors.Is(err, core.ErrLimitExceeded); !ok {
+		t.Errorf("expected error: %v, found: %v", core.ErrLimitExceeded, err)
+	}
+
+	page, err := bank.GetListOfClientsPage(ctx, core.PageRequest{Limit: 10})
+	if err != nil {
+		t.Errorf("unexpected error at GetListOfClientsPage: %v", err)
+	}
+	if len(page.Clients) != 2 {
+		t.Errorf("expected 2 clients, found: %v", page.Clients)
+	}
+
+	checkLedgerBalances(t, db)
+}
@@ -0,0 +1,172 @@
+package tests
+
+import (
+	"context"
+	"errors"
+	"github.com/JAbduvohidov/apm-ibank-core/pkg/core"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestServiceCatalog(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store core.Store) {
+		date := time.Date(2020, time.March, 1, 12, 0, 0, 0, time.UTC)
+		bank := core.NewBank(
+			store,
+			core.WithClock(func() time.Time { return date }),
+			core.WithPasswordHasher(core.NewBcryptHasher(4)),
+		)
+		ctx := context.Background()
+
+		err := bank.AddClient(ctx, "Vasya", "vasya", "1234", 1234)
+		if err != nil {
+			t.Errorf("unexpected error at AddClient: %v", err)
+		}
+
+		err = bank.AddAccount(ctx, 1234, core.NewMoney(100000, core.DefaultCurrency))
+		if err != nil {
+			t.Errorf("unexpected error at AddAccount: %v", err)
+		}
+
+		err = bank.AddService(ctx, "Water")
+		if err != nil {
+			t.Errorf("unexpected error at AddService: %v", err)
+		}
+
+		mobile := core.CatalogService{
+			Name:             "Tcell",
+			Category:         core.Mobile,
+			MinAmount:        core.NewMoney(100, core.DefaultCurrency),
+			MaxAmount:        core.NewMoney(50000, core.DefaultCurrency),
+			FixedFee:         core.NewMoney(50, core.DefaultCurrency),
+			FeePercent:       mustParseRate(t, "1.5"),
+			ReferencePattern: `\+992\d{9}`,
+		}
+		internet := core.CatalogService{Name: "Babilon", Category: core.Internet, ReferencePattern: `\d+`}
+		for _, service := range []core.CatalogService{mobile, internet} {
+			err = bank.AddCatalogService(ctx, service)
+			if err != nil {
+				t.Errorf("unexpected error at AddCatalogService: %v", err)
+			}
+		}
+
+		for _, invalid := range []struct {
+			service  core.CatalogService
+			expected error
+		}{
+			{core.CatalogService{Name: "Gas", Category: "gas"}, core.ErrInvalidCategory},
+			{core.CatalogService{Name: "Gas", Category: core.Utilities, ReferencePattern: `(\d`}, core.ErrInvalidReferencePattern},
+			{core.CatalogService{Name: "Gas", Category: core.Utilities, MinAmount: core.NewMoney(100, core.DefaultCurrency),
+				FixedFee: core.NewMoney(10, "USD")}, core.ErrCurrencyMismatch},
+			{core.CatalogService{Name: "Gas", Category: core.Utilities, MinAmount: core.NewMoney(100, core.DefaultCurrency),
+				MaxAmount: core.NewMoney(10, core.DefaultCurrency)}, core.ErrInvalidAmount},
+			{core.CatalogService{Name: "Water", Category: core.Utilities}, core.ErrServiceExist},
+		} {
+			err = bank.AddCatalogService(ctx, invalid.service)
+			if ok := errors.Is(err, invalid.expected); !ok {
+				t.Errorf("expected error: %v, found: %v", invalid.expected, err)
+			}
+		}
+
+		services, err := bank.GetServiceCatalog(ctx, core.Mobile)
+		if err != nil {
+			t.Errorf("unexpected error at GetServiceCatalog: %v", err)
+		}
+		expected := []core.CatalogService{mobile}
+		if !reflect.DeepEqual(services, expected) {
+			t.Errorf("expected: %v, found: %v", expected, services)
+		}
+
+		services, err = bank.GetServiceCatalog(ctx, "")
+		if err != nil {
+			t.Errorf("unexpected error at GetServiceCatalog: %v", err)
+		}
+		expected = []core.CatalogService{{Name: "Water"}, mobile, internet}
+		if !reflect.DeepEqual(services, expected) {
+			t.Errorf("expected: %v, found: %v", expected, services)
+		}
+
+		session, err := bank.StartSession(ctx, "vasya", "1234", "test")
+		if err != nil {
+			t.Errorf("unexpected error at StartSession: %v", err)
+		}
+
+		for _, invalid := range []struct {
+			reference string
+			amount    int64
+			expected  error
+		}{
+			{"900000000", 1000, core.ErrInvalidReference},
+			{"+992900000000 ", 1000, core.ErrInvalidReference},
+			{"+992900000000", 99, core.ErrLimitExceeded},
+			{"+992900000000", 50001, core.ErrLimitExceeded},
+		} {
+			err = bank.PayForServiceWithReference(ctx, "Tcell", invalid.reference, 1, session, core.NewMoney(invalid.amount, core.DefaultCurrency))
+			if ok := errors.Is(err, invalid.expected); !ok {
+				t.Errorf("expected error: %v, found: %v", invalid.expected, err)
+			}
+		}
+
+		err = bank.PayForService(ctx, "Tcell", 1, session, core.NewMoney(1000, core.DefaultCurrency))
+		if ok := errors.Is(err, core.ErrInvalidReference); !ok {
+			t.Errorf("expected error: %v, found: %v", core.ErrInvalidReference, err)
+		}
+
+		// Journal entries 1 and 2, the fee of the first is 50 + 1.5% of 1000.
+		err = bank.PayForServiceWithReference(ctx, "Tcell", "+992900000000", 1, session, core.NewMoney(1000, core.DefaultCurrency))
+		if err != nil {
+			t.Errorf("unexpected error at PayForServiceWithReference: %v", err)
+		}
+
+		err = bank.PayForService(ctx, "Water", 1, session, core.NewMoney(500, core.DefaultCurrency))
+		if err != nil {
+			t.Errorf("unexpected error at PayForService: %v", err)
+		}
+
+		accounts, err := bank.GetListOfAccountsWithClients(ctx)
+		if err != nil {
+			t.Errorf("unexpected error at GetListOfAccountsWithClients: %v", err)
+		}
+		if len(accounts) != 1 || accounts[0].Balance != core.NewMoney(98435, core.DefaultCurrency) {
+			t.Errorf("expected balance: %v, found: %v", core.NewMoney(98435, core.DefaultCurrency), accounts)
+		}
+
+		_, err = bank.ReverseTransaction(ctx, 1, "wrong number")
+		if err != nil {
+			t.Errorf("unexpected error at ReverseTransaction: %v", err)
+		}
+
+		journals, err := bank.GetJournalList(ctx, core.NewJournalQuery("vasya"))
+		if err != nil {
+			t.Errorf("unexpected error at GetJournalList: %v", err)
+		}
+		paid := core.Journal{Id: 1, Date: date, Type: core.Service, Direction: core.Outgoing, AccountId: 1,
+			Counterparty: "Tcell", TransferredTo: "Tcell", Amount: core.NewMoney(1000, core.DefaultCurrency),
+			Reference: "+992900000000", Fee: core.NewMoney(65, core.DefaultCurrency)}
+		refunded := paid
+		refunded.Id, refunded.Type, refunded.Direction = 3, core.Reversal, core.Incoming
+		expectedJournals := []core.Journal{
+			paid,
+			{Id: 2, Date: date, Type: core.Service, Direction: core.Outgoing, AccountId: 1, Counterparty: "Water",
+				TransferredTo: "Water", Amount: core.NewMoney(500, core.DefaultCurrency)},
+			refunded,
+		}
+		if !reflect.DeepEqual(journals, expectedJournals) {
+			t.Errorf("expected: %v, found: %v", expectedJournals, journals)
+		}
+
+		balance, err := bank.GetLedgerBalance(ctx, 1)
+		if err != nil {
+			t.Errorf("unexpected error at GetLedgerBalance: %v", err)
+		}
+		if balance != core.NewMoney(99500, core.DefaultCurrency) {
+			t.Errorf("expected: %v, found: %v", core.NewMoney(99500, core.DefaultCurrency), balance)
+		}
+
+		err = bank.CheckLedger(ctx)
+		if err != nil {
+			t.Errorf("unexpected error at CheckLedger: %v", err)
+		}
+	})
+}
@@ -0,0 +1,312 @@
+package tests
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"github.com/JAbduvohidov/apm-ibank-core/pkg/core"
+	_ "github.com/mattn/go-sqlite3"
+	"io/ioutil"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestConcurrentTransfersConserveMoney(t *testing.T) {
+	if testing.Short() {
+		t.Skip("stress test")
+	}
+
+	dir, err := ioutil.TempDir("", "concurrency")
+	if err != nil {
+		t.Fatalf("can't create dir: %v", err)
+	}
+	defer func() {
+		if err := os.RemoveAll(dir); err != nil {
+			t.Errorf("can't remove dir: %v", err)
+		}
+	}()
+
+	db, err := sql.Open("sqlite3", filepath.Join(dir, "bank.sqlite")+"?_journal_mode=WAL")
+	if err != nil {
+		t.Fatalf("can't open db: %v", err)
+	}
+	defer func() {
+		if err := db.Close(); err != nil {
+			t.Errorf("can't close db: %v", err)
+		}
+	}()
+	db.SetMaxOpenConns(16)
+
+	const (
+		clients   = 10
+		balance   = 100000
+		workers   = 20
+		transfers = 2000
+	)
+
+	bank := core.NewBank(
+		core.NewSQLiteStore(db),
+		core.WithPasswordHasher(core.NewBcryptHasher(4)),
+		core.WithRetryPolicy(core.RetryPolicy{MaxAttempts: 20, Delay: time.Millisecond}),
+	)
+	ctx := context.Background()
+
+	err = bank.Init(ctx)
+	if err != nil {
+		t.Fatalf("unexpected error at Init: %v", err)
+	}
+
+	sessions := make([]core.Session, clients)
+	for i := range sessions {
+		login := "client" + strconv.Itoa(i)
+		err = bank.AddClient(ctx, login, login, "1234", int64(1000+i))
+		if err != nil {
+			t.Fatalf("unexpected error at AddClient: %v", err)
+		}
+
+		err = bank.AddAccount(ctx, int64(1000+i), core.NewMoney(balance, core.DefaultCurrency))
+		if err != nil {
+			t.Fatalf("unexpected error at AddAccount: %v", err)
+		}
+
+		sessions[i], err = bank.StartSession(ctx, login, "1234", "test")
+		if err != nil {
+			t.Fatalf("unexpected error at StartSession: %v", err)
+		}
+	}
+
+	var wait sync.WaitGroup
+	for worker := 0; worker < workers; worker++ {
+		wait.Add(1)
+		go func(worker int) {
+			defer wait.Done()
+			random := rand.New(rand.NewSource(int64(worker)))
+			for i := 0; i < transfers/workers; i++ {
+				from := random.Intn(clients)
+				to := (from + 1 + random.Intn(clients-1)) % clients
+				amount := core.NewMoney(random.Int63n(5000)+1, core.DefaultCurrency)
+
+				var err error
+				if i%2 == 0 {
+					err = bank.TransferToByAccountId(ctx, int64(to+1), sessions[from], int64(from+1), amount)
+				} else {
+					err = bank.TransferToByPhoneNumber(ctx, int64(1000+to), sessions[from], int64(from+1), amount)
+				}
+				if err != nil && !errors.Is(err, core.ErrInsufficientFunds) {
+					t.Errorf("unexpected error at transfer: %v", err)
+				}
+			}
+		}(worker)
+	}
+	wait.Wait()
+
+	accounts, err := bank.GetListOfAccountsWithClients(ctx)
+	if err != nil {
+		t.Fatalf("unexpected error at GetListOfAccountsWithClients: %v", err)
+	}
+
+	var total int64
+	for _, account := range accounts {
+		if account.Balance.Amount < 0 {
+			t.Errorf("negative balance: %v", account)
+		}
+		total += account.Balance.Amount
+	}
+	if total != clients*balance {
+		t.Errorf("expected total: %d, found: %d", clients*balance, total)
+	}
+
+	err = bank.CheckLedger(ctx)
+	if err != nil {
+		t.Errorf("unexpected error at CheckLedger: %v", err)
+	}
+}
+
+func TestConcurrentLoginsRespectLockout(t *testing.T) {
+	dir, err := ioutil.TempDir("", "concurrency")
+	if err != nil {
+		t.Fatalf("can't create dir: %v", err)
+	}
+	defer func() {
+		if err := os.RemoveAll(dir); err != nil {
+			t.Errorf("can't remove dir: %v", err)
+		}
+	}()
+
+	db, err := sql.Open("sqlite3", filepath.Join(dir, "bank.sqlite")+"?_journal_mode=WAL&_busy_timeout=5000")
+	if err != nil {
+		t.Fatalf("can't open db: %v", err)
+	}
+	defer func() {
+		if err := db.Close(); err != nil {
+			t.Errorf("can't close db: %v", err)
+		}
+	}()
+	db.SetMaxOpenConns(16)
+
+	const attempts = 40
+
+	// A slower hash keeps the attempts running side by side.
+	bank := core.NewBank(
+		core.NewSQLiteStore(db),
+		core.WithPasswordHasher(core.NewBcryptHasher(8)),
+	)
+	ctx := context.Background()
+
+	err = bank.Init(ctx)
+	if err != nil {
+		t.Fatalf("unexpected error at Init: %v", err)
+	}
+
+	err = bank.AddClient(ctx, "Vasya", "vasya", "1234", 1234)
+	if err != nil {
+		t.Fatalf("unexpected error at AddClient: %v", err)
+	}
+
+	var wait sync.WaitGroup
+	var mutex sync.Mutex
+	var checked, refused int64
+	for i := 0; i < attempts; i++ {
+		wait.Add(1)
+		go func() {
+			defer wait.Done()
+			_, err := bank.Authenticate(ctx, "vasya", "wrong")
+
+			mutex.Lock()
+			defer mutex.Unlock()
+			switch {
+			case errors.Is(err, core.ErrInvalidCredentials):
+				checked++
+			case errors.Is(err, core.ErrClientTemporarilyLocked):
+				refused++
+			default:
+				t.Errorf("unexpected error at Authenticate: %v", err)
+			}
+		}()
+	}
+	wait.Wait()
+
+	maxAttempts := core.DefaultLockoutPolicy.MaxFailedAttempts
+	if checked != maxAttempts || refused != attempts-maxAttempts {
+		t.Errorf("expected %d checked and %d refused attempts, found: %d and %d",
+			maxAttempts, attempts-maxAttempts, checked, refused)
+	}
+
+	_, err = bank.Authenticate(ctx, "vasya", "1234")
+	if ok := errors.Is(err, core.ErrClientTemporarilyLocked); !ok {
+		t.Errorf("expected error: %v, found: %v", core.ErrClientTemporarilyLocked, err)
+	}
+}
+
+func TestReadsDontWaitForWriters(t *testing.T) {
+	dir, err := ioutil.TempDir("", "concurrency")
+	if err != nil {
+		t.Fatalf("can't create dir: %v", err)
+	}
+	defer func() {
+		if err := os.RemoveAll(dir); err != nil {
+			t.Errorf("can't remove dir: %v", err)
+		}
+	}()
+
+	db, err := sql.Open("sqlite3", filepath.Join(dir, "bank.sqlite")+"?_journal_mode=WAL&_busy_timeout=0")
+	if err != nil {
+		t.Fatalf("can't open db: %v", err)
+	}
+	defer func() {
+		if err := db.Close(); err != nil {
+			t.Errorf("can't close db: %v", err)
+		}
+	}()
+
+	store := core.NewSQLiteStore(db)
+	bank := core.NewBank(store)
+	ctx := context.Background()
+
+	err = bank.Init(ctx)
+	if err != nil {
+		t.Fatalf("unexpected error at Init: %v", err)
+	}
+
+	err = bank.AddAtm(ctx, "Center", "Rudaki 1")
+	if err != nil {
+		t.Errorf("unexpected error at AddAtm: %v", err)
+	}
+
+	tx, err := store.Begin(ctx, core.TxOptions{})
+	if err != nil {
+		t.Fatalf("unexpected error at Begin: %v", err)
+	}
+	err = tx.AddATM(ctx, core.ATM{Name: "Airport", Location: "Airport"})
+	if err != nil {
+		t.Errorf("unexpected error at AddATM: %v", err)
+	}
+
+	atms, err := bank.GetListOfATMs(ctx)
+	if err != nil {
+		t.Errorf("unexpected error at GetListOfATMs: %v", err)
+	}
+	if len(atms) != 1 {
+		t.Errorf("expected only the committed ATM, found: %v", atms)
+	}
+
+	_, err = store.Begin(ctx, core.TxOptions{})
+	if err == nil {
+		t.Errorf("a second writer must not get the write lock")
+	}
+
+	err = tx.Rollback()
+	if err != nil {
+		t.Errorf("unexpected error at Rollback: %v", err)
+	}
+}
+
+// TestSettersDuringPackageCalls is meant for the race detector, which reports
+// the settings read by the package level functions if they aren't guarded.
+func TestSettersDuringPackageCalls(t *testing.T) {
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatalf("can't open db: %v", err)
+	}
+	defer func() {
+		if err := db.Close(); err != nil {
+			t.Errorf("can't close db: %v", err)
+		}
+	}()
+	db.SetMaxOpenConns(1)
+
+	err = core.Init(db)
+	if err != nil {
+		t.Fatalf("unexpected error at Init: %v", err)
+	}
+
+	defer func() {
+		core.SetSessionTTL(core.DefaultSessionTTL)
+		core.SetRetryPolicy(core.DefaultRetryPolicy)
+		core.SetHideUnknownLogins(false)
+	}()
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 100; i++ {
+			core.SetSessionTTL(core.DefaultSessionTTL)
+			core.SetRetryPolicy(core.DefaultRetryPolicy)
+			core.SetHideUnknownLogins(false)
+			core.RegisterPasswordHasher(core.DefaultPasswordHasher)
+		}
+	}()
+
+	for i := 0; i < 100; i++ {
+		_, err = core.GetListOfClients(db)
+		if err != nil {
+			t.Errorf("unexpected error at GetListOfClients: %v", err)
+		}
+	}
+	<-done
+}
@@ -0,0 +1,168 @@
+package tests
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"github.com/JAbduvohidov/apm-ibank-core/pkg/core"
+	_ "github.com/mattn/go-sqlite3"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// cancellingClock cancels its context on the given call to Now, which lets a
+// test stop an operation half way through.
+type cancellingClock struct {
+	calls    int
+	cancelAt int
+	cancel   context.CancelFunc
+}
+
+func (receiver *cancellingClock) Now() time.Time {
+	receiver.calls++
+	if receiver.calls == receiver.cancelAt {
+		receiver.cancel()
+	}
+	return time.Now()
+}
+
+func TestCancelledTransferHasNoEffect(t *testing.T) {
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Errorf("can't open db: %v", err)
+	}
+	defer func() {
+		if err := db.Close(); err != nil {
+			t.Errorf("can't close db: %v", err)
+		}
+	}()
+	db.SetMaxOpenConns(1)
+
+	err = core.Init(db)
+	if err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	err = core.AddClient("Vasya", "vasya", "1234", 1234, db)
+	if err != nil {
+		t.Errorf("unexpected error at AddClient: %v", err)
+	}
+
+	err = core.AddClient("Petya", "petya", "4321", 4321, db)
+	if err != nil {
+		t.Errorf("unexpected error at AddClient: %v", err)
+	}
+
+	err = core.AddAccount(1234, core.NewMoney(10000, core.DefaultCurrency), db)
+	if err != nil {
+		t.Errorf("unexpected error at AddAccount: %v", err)
+	}
+
+	err = core.AddAccount(4321, core.NewMoney(0, core.DefaultCurrency), db)
+	if err != nil {
+		t.Errorf("unexpected error at AddAccount: %v", err)
+	}
+
+	session, err := core.StartSession("vasya", "1234", "test", db)
+	if err != nil {
+		t.Errorf("unexpected error at StartSession: %v", err)
+	}
+
+	accounts, err := core.GetListOfAccountsWithClients(db)
+	if err != nil {
+		t.Errorf("unexpected error at GetListOfAccountsWithClients: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	err = core.TransferToByAccountIdContext(ctx, 2, session, 1, core.NewMoney(2500, core.DefaultCurrency), db)
+	if ok := errors.Is(err, context.Canceled); !ok {
+		t.Errorf("expected error: %v, found: %v", context.Canceled, err)
+	}
+
+	// The first call checks the session, the second one dates the journal
+	// entry after both balances have already been changed.
+	ctx, cancel = context.WithCancel(context.Background())
+	defer cancel()
+	clock := &cancellingClock{cancelAt: 2, cancel: cancel}
+	core.SetClock(clock.Now)
+	defer core.SetClock(nil)
+
+	err = core.TransferToByAccountIdContext(ctx, 2, session, 1, core.NewMoney(2500, core.DefaultCurrency), db)
+	if err == nil {
+		t.Errorf("transfer with a cancelled context must fail")
+	}
+	if clock.calls < 2 {
+		t.Errorf("transfer stopped before reaching the journal")
+	}
+	core.SetClock(nil)
+
+	found, err := core.GetListOfAccountsWithClients(db)
+	if err != nil {
+		t.Errorf("unexpected error at GetListOfAccountsWithClients: %v", err)
+	}
+	if !reflect.DeepEqual(accounts, found) {
+		t.Errorf("expected: %v, found: %v", accounts, found)
+	}
+
+	journals, err := core.GetJournalListFormatted("vasya", 10, 0, db)
+	if err != nil {
+		t.Errorf("unexpected error at GetJournalListFormatted: %v", err)
+	}
+	if journals != nil {
+		t.Errorf("cancelled transfer must not be journaled, found: %v", journals)
+	}
+	checkLedgerBalances(t, db)
+
+	err = core.TransferToByAccountIdContext(context.Background(), 2, session, 1, core.NewMoney(2500, core.DefaultCurrency), db)
+	if err != nil {
+		t.Errorf("unexpected error at TransferToByAccountIdContext: %v", err)
+	}
+	checkLedgerBalances(t, db)
+}
+
+func TestListingStopsAtDeadline(t *testing.T) {
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Errorf("can't open db: %v", err)
+	}
+	defer func() {
+		if err := db.Close(); err != nil {
+			t.Errorf("can't close db: %v", err)
+		}
+	}()
+	db.SetMaxOpenConns(1)
+
+	err = core.Init(db)
+	if err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	err = core.AddClient("Vasya", "vasya", "1234", 1234, db)
+	if err != nil {
+		t.Errorf("unexpected error at AddClient: %v", err)
+	}
+
+	ctx, cancel := context.WithDeadline(context.Background(), time.Now().Add(-time.Second))
+	defer cancel()
+
+	_, err = core.GetListOfClientsContext(ctx, db)
+	if ok := errors.Is(err, context.DeadlineExceeded); !ok {
+		t.Errorf("expected error: %v, found: %v", context.DeadlineExceeded, err)
+	}
+
+	_, err = core.GetListOfClientsPageContext(ctx, core.PageRequest{Limit: 10, WithTotal: true}, db)
+	if ok := errors.Is(err, context.DeadlineExceeded); !ok {
+		t.Errorf("expected error: %v, found: %v", context.DeadlineExceeded, err)
+	}
+
+	clients, err := core.GetListOfClientsContext(context.Background(), db)
+	if err != nil {
+		t.Errorf("unexpected error at GetListOfClientsContext: %v", err)
+	}
+	if len(clients) != 1 {
+		t.Errorf("expected one client, found: %v", clients)
+	}
+}
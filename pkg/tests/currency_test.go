@@ -0,0 +1,137 @@
+package tests
+
+import (
+	"database/sql"
+	"errors"
+	"github.com/JAbduvohidov/apm-ibank-core/pkg/core"
+	_ "github.com/mattn/go-sqlite3"
+	"testing"
+)
+
+func TestMultiCurrencyAccounts(t *testing.T) {
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Errorf("can't open db: %v", err)
+	}
+	defer func() {
+		if err := db.Close(); err != nil {
+			t.Errorf("can't close db: %v", err)
+		}
+	}()
+
+	err = core.Init(db)
+	if err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	err = core.AddClient("Vasya", "vasya", "1234", 1234, db)
+	if err != nil {
+		t.Errorf("unexpected error at AddClient: %v", err)
+	}
+
+	err = core.AddClient("Petya", "petya", "1234", 5678, db)
+	if err != nil {
+		t.Errorf("unexpected error at AddClient: %v", err)
+	}
+
+	err = core.AddAccount(1234, core.NewMoney(10000, "XXX"), db)
+	if ok := errors.Is(err, core.ErrUnsupportedCurrency); !ok {
+		t.Errorf("expected error: %v, found: %v", core.ErrUnsupportedCurrency, err)
+	}
+
+	err = core.AddAccount(1234, core.NewMoney(10000, core.DefaultCurrency), db)
+	if err != nil {
+		t.Errorf("unexpected error at AddAccount: %v", err)
+	}
+
+	err = core.AddAccount(1234, core.NewMoney(5000, "USD"), db)
+	if err != nil {
+		t.Errorf("unexpected error at AddAccount: %v", err)
+	}
+
+	err = core.AddAccount(5678, core.NewMoney(0, core.DefaultCurrency), db)
+	if err != nil {
+		t.Errorf("unexpected error at AddAccount: %v", err)
+	}
+
+	session, err := core.StartSession("vasya", "1234", "test", db)
+	if err != nil {
+		t.Errorf("unexpected error at StartSession: %v", err)
+	}
+
+	err = core.TransferToByAccountId(3, session, 2, core.NewMoney(100, "USD"), db)
+	if ok := errors.Is(err, core.ErrCurrencyMismatch); !ok {
+		t.Errorf("expected error: %v, found: %v", core.ErrCurrencyMismatch, err)
+	}
+
+	err = core.TransferToByAccountId(3, session, 2, core.NewMoney(100, core.DefaultCurrency), db)
+	if ok := errors.Is(err, core.ErrCurrencyMismatch); !ok {
+		t.Errorf("expected error: %v, found: %v", core.ErrCurrencyMismatch, err)
+	}
+
+	err = core.TransferToByPhoneNumber(5678, session, 2, core.NewMoney(100, "USD"), db)
+	if ok := errors.Is(err, core.ErrRecipientHasNoAccount); !ok {
+		t.Errorf("expected error: %v, found: %v", core.ErrRecipientHasNoAccount, err)
+	}
+
+	err = core.TransferToByPhoneNumber(5678, session, 1, core.NewMoney(100, core.DefaultCurrency), db)
+	if err != nil {
+		t.Errorf("unexpected error at TransferToByPhoneNumber: %v", err)
+	}
+
+	accounts, err := core.GetListOfClientAccounts("vasya", db)
+	if err != nil {
+		t.Errorf("unexpected error at GetListOfClientAccounts: %v", err)
+	}
+
+	if len(accounts) != 2 || accounts[0].Balance != core.NewMoney(9900, core.DefaultCurrency) || accounts[1].Balance != core.NewMoney(5000, "USD") {
+		t.Errorf("unexpected balances: %v", accounts)
+	}
+
+	journals, err := core.GetJournalListFormatted("vasya", 10, 0, db)
+	if err != nil {
+		t.Errorf("unexpected error at GetJournalListFormatted: %v", err)
+	}
+
+	if len(journals) != 1 || journals[0].Amount != core.NewMoney(100, core.DefaultCurrency) {
+		t.Errorf("journal must record the transfer currency, found: %v", journals)
+	}
+}
+
+func TestInitAddsCurrencyToLegacyTables(t *testing.T) {
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Errorf("can't open db: %v", err)
+	}
+	defer func() {
+		if err := db.Close(); err != nil {
+			t.Errorf("can't close db: %v", err)
+		}
+	}()
+	db.SetMaxOpenConns(1)
+
+	_, err = db.Exec(`CREATE TABLE accounts
+(
+    id        INTEGER PRIMARY KEY AUTOINCREMENT,
+    client_id INTEGER NOT NULL REFERENCES clients,
+    balance   INTEGER NOT NULL check ( balance >= 0 )
+);
+INSERT INTO accounts(client_id, balance) VALUES (1, 500);`)
+	if err != nil {
+		t.Errorf("can't create legacy table: %v", err)
+	}
+
+	err = core.Init(db)
+	if err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	accounts, err := core.GetListOfAccountsWithClients(db)
+	if err != nil {
+		t.Errorf("unexpected error at GetListOfAccountsWithClients: %v", err)
+	}
+
+	if len(accounts) != 1 || accounts[0].Balance != core.NewMoney(500, core.DefaultCurrency) {
+		t.Errorf("legacy accounts must default to %s, found: %v", core.DefaultCurrency, accounts)
+	}
+}
@@ -0,0 +1,163 @@
+package tests
+
+import (
+	"database/sql"
+	"errors"
+	"github.com/JAbduvohidov/apm-ibank-core/pkg/core"
+	_ "github.com/mattn/go-sqlite3"
+	"testing"
+	"time"
+)
+
+func mustParseRate(t *testing.T, value string) core.Rate {
+	rate, err := core.ParseRate(value)
+	if err != nil {
+		t.Fatalf("can't parse rate %s: %v", value, err)
+	}
+	return rate
+}
+
+func TestLoadExchangeRates(t *testing.T) {
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Errorf("can't open db: %v", err)
+	}
+	defer func() {
+		if err := db.Close(); err != nil {
+			t.Errorf("can't close db: %v", err)
+		}
+	}()
+
+	err = core.Init(db)
+	if err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	_, err = core.GetExchangeRate("USD", core.DefaultCurrency, db)
+	if ok := errors.Is(err, core.ErrExchangeRateNotFound); !ok {
+		t.Errorf("expected error: %v, found: %v", core.ErrExchangeRateNotFound, err)
+	}
+
+	err = core.LoadExchangeRates([]core.ExchangeRate{
+		{Base: "USD", Quote: core.DefaultCurrency, Buy: mustParseRate(t, "11"), Sell: mustParseRate(t, "10")},
+	}, db)
+	if ok := errors.Is(err, core.ErrInvalidRate); !ok {
+		t.Errorf("expected error: %v, found: %v", core.ErrInvalidRate, err)
+	}
+
+	current := time.Now()
+	err = core.LoadExchangeRates([]core.ExchangeRate{
+		{Base: "USD", Quote: core.DefaultCurrency, Buy: mustParseRate(t, "10.5"), Sell: mustParseRate(t, "10.8"), EffectiveAt: current.Add(-time.Hour)},
+		{Base: "USD", Quote: core.DefaultCurrency, Buy: mustParseRate(t, "10.9"), Sell: mustParseRate(t, "11.05"), EffectiveAt: current.Add(-time.Minute)},
+		{Base: "USD", Quote: core.DefaultCurrency, Buy: mustParseRate(t, "12"), Sell: mustParseRate(t, "13"), EffectiveAt: current.Add(time.Hour)},
+	}, db)
+	if err != nil {
+		t.Errorf("unexpected error at LoadExchangeRates: %v", err)
+	}
+
+	rate, err := core.GetExchangeRate("USD", core.DefaultCurrency, db)
+	if err != nil {
+		t.Errorf("unexpected error at GetExchangeRate: %v", err)
+	}
+
+	if rate.Buy.String() != "10.900000" || rate.Sell.String() != "11.050000" {
+		t.Errorf("expected the latest effective rate, found: %v", rate)
+	}
+}
+
+func TestConvertBetweenOwnAccounts(t *testing.T) {
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Errorf("can't open db: %v", err)
+	}
+	defer func() {
+		if err := db.Close(); err != nil {
+			t.Errorf("can't close db: %v", err)
+		}
+	}()
+
+	err = core.Init(db)
+	if err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	err = core.LoadExchangeRates([]core.ExchangeRate{
+		{Base: "USD", Quote: core.DefaultCurrency, Buy: mustParseRate(t, "10.9"), Sell: mustParseRate(t, "11.05")},
+	}, db)
+	if err != nil {
+		t.Errorf("unexpected error at LoadExchangeRates: %v", err)
+	}
+
+	err = core.AddClient("Vasya", "vasya", "1234", 1234, db)
+	if err != nil {
+		t.Errorf("unexpected error at AddClient: %v", err)
+	}
+
+	err = core.AddAccount(1234, core.NewMoney(100000, core.DefaultCurrency), db)
+	if err != nil {
+		t.Errorf("unexpected error at AddAccount: %v", err)
+	}
+
+	err = core.AddAccount(1234, core.NewMoney(0, "USD"), db)
+	if err != nil {
+		t.Errorf("unexpected error at AddAccount: %v", err)
+	}
+
+	err = core.AddAccount(1234, core.NewMoney(0, "EUR"), db)
+	if err != nil {
+		t.Errorf("unexpected error at AddAccount: %v", err)
+	}
+
+	session, err := core.StartSession("vasya", "1234", "test", db)
+	if err != nil {
+		t.Errorf("unexpected error at StartSession: %v", err)
+	}
+
+	converted, err := core.ConvertBetweenOwnAccounts(session, 1, 2, core.NewMoney(11050, core.DefaultCurrency), db)
+	if err != nil {
+		t.Errorf("unexpected error at ConvertBetweenOwnAccounts: %v", err)
+	}
+
+	if converted != core.NewMoney(1000, "USD") {
+		t.Errorf("expected: 10.00 USD, found: %v", converted)
+	}
+
+	converted, err = core.ConvertBetweenOwnAccounts(session, 2, 1, core.NewMoney(500, "USD"), db)
+	if err != nil {
+		t.Errorf("unexpected error at ConvertBetweenOwnAccounts: %v", err)
+	}
+
+	if converted != core.NewMoney(5450, core.DefaultCurrency) {
+		t.Errorf("expected: 54.50 TJS, found: %v", converted)
+	}
+
+	_, err = core.ConvertBetweenOwnAccounts(session, 1, 3, core.NewMoney(100, core.DefaultCurrency), db)
+	if ok := errors.Is(err, core.ErrExchangeRateNotFound); !ok {
+		t.Errorf("expected error: %v, found: %v", core.ErrExchangeRateNotFound, err)
+	}
+
+	accounts, err := core.GetListOfClientAccounts("vasya", db)
+	if err != nil {
+		t.Errorf("unexpected error at GetListOfClientAccounts: %v", err)
+	}
+
+	expected := []core.Money{core.NewMoney(94400, core.DefaultCurrency), core.NewMoney(500, "USD"), core.NewMoney(0, "EUR")}
+	for i, account := range accounts {
+		if account.Balance != expected[i] {
+			t.Errorf("expected: %v, found: %v", expected[i], account.Balance)
+		}
+	}
+
+	journals, err := core.GetJournalListFormatted("vasya", 10, 0, db)
+	if err != nil {
+		t.Errorf("unexpected error at GetJournalListFormatted: %v", err)
+	}
+
+	if len(journals) != 4 || journals[0].Type != core.Conversion || journals[0].Rate.String() != "11.050000" || journals[2].Rate.String() != "10.900000" {
+		t.Errorf("conversions must be journaled with their rate, found: %v", journals)
+	}
+
+	if len(journals) == 4 && (journals[1].Direction != core.Incoming || journals[1].Amount != core.NewMoney(1000, "USD")) {
+		t.Errorf("expected incoming 10.00 USD, found: %v", journals[1])
+	}
+}
@@ -0,0 +1,213 @@
+package tests
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"github.com/JAbduvohidov/apm-ibank-core/pkg/core"
+	_ "github.com/mattn/go-sqlite3"
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestIdempotencyKeys(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store core.Store) {
+		date := time.Date(2020, time.March, 1, 12, 0, 0, 0, time.UTC)
+		bank := core.NewBank(
+			store,
+			core.WithClock(func() time.Time { return date }),
+			core.WithPasswordHasher(core.NewBcryptHasher(4)),
+			core.WithIdempotencyWindow(time.Hour),
+			core.WithSessionTTL(24*time.Hour),
+		)
+		ctx := context.Background()
+
+		for _, client := range []struct {
+			name, login string
+			phoneNumber int64
+		}{
+			{"Vasya", "vasya", 1234},
+			{"Petya", "petya", 4321},
+		} {
+			err := bank.AddClient(ctx, client.name, client.login, "1234", client.phoneNumber)
+			if err != nil {
+				t.Errorf("unexpected error at AddClient: %v", err)
+			}
+		}
+
+		for _, account := range []struct {
+			phoneNumber int64
+			balance     core.Money
+		}{
+			{1234, core.NewMoney(100000, core.DefaultCurrency)},
+			{1234, core.NewMoney(0, "USD")},
+			{4321, core.NewMoney(0, core.DefaultCurrency)},
+		} {
+			err := bank.AddAccount(ctx, account.phoneNumber, account.balance)
+			if err != nil {
+				t.Errorf("unexpected error at AddAccount: %v", err)
+			}
+		}
+
+		err := bank.AddService(ctx, "Internet")
+		if err != nil {
+			t.Errorf("unexpected error at AddService: %v", err)
+		}
+
+		err = bank.LoadExchangeRates(ctx, []core.ExchangeRate{
+			{Base: "USD", Quote: core.DefaultCurrency, Buy: mustParseRate(t, "10"), Sell: mustParseRate(t, "10")},
+		})
+		if err != nil {
+			t.Errorf("unexpected error at LoadExchangeRates: %v", err)
+		}
+
+		vasya, err := bank.StartSession(ctx, "vasya", "1234", "test")
+		if err != nil {
+			t.Errorf("unexpected error at StartSession: %v", err)
+		}
+
+		petya, err := bank.StartSession(ctx, "petya", "1234", "test")
+		if err != nil {
+			t.Errorf("unexpected error at StartSession: %v", err)
+		}
+
+		payment := core.WithIdempotencyKey("payment")
+		for i := 0; i < 2; i++ {
+			err = bank.PayForService(ctx, "Internet", 1, vasya, core.NewMoney(1000, core.DefaultCurrency), payment)
+			if err != nil {
+				t.Errorf("unexpected error at PayForService: %v", err)
+			}
+		}
+
+		err = bank.PayForService(ctx, "Internet", 1, vasya, core.NewMoney(2000, core.DefaultCurrency), payment)
+		if ok := errors.Is(err, core.ErrIdempotencyKeyReused); !ok {
+			t.Errorf("expected error: %v, found: %v", core.ErrIdempotencyKeyReused, err)
+		}
+
+		transfer := core.WithIdempotencyKey("transfer")
+		for i := 0; i < 2; i++ {
+			err = bank.TransferToByPhoneNumber(ctx, 4321, vasya, 1, core.NewMoney(5000, core.DefaultCurrency), transfer)
+			if err != nil {
+				t.Errorf("unexpected error at TransferToByPhoneNumber: %v", err)
+			}
+		}
+
+		err = bank.TransferToByAccountId(ctx, 3, vasya, 1, core.NewMoney(5000, core.DefaultCurrency), transfer)
+		if ok := errors.Is(err, core.ErrIdempotencyKeyReused); !ok {
+			t.Errorf("expected error: %v, found: %v", core.ErrIdempotencyKeyReused, err)
+		}
+
+		// Keys are per client.
+		err = bank.TransferToByAccountId(ctx, 1, petya, 3, core.NewMoney(1000, core.DefaultCurrency), transfer)
+		if err != nil {
+			t.Errorf("unexpected error at TransferToByAccountId: %v", err)
+		}
+
+		conversion := core.WithIdempotencyKey("conversion")
+		for i := 0; i < 2; i++ {
+			converted, err := bank.ConvertBetweenOwnAccounts(ctx, vasya, 1, 2, core.NewMoney(10000, core.DefaultCurrency), conversion)
+			if err != nil {
+				t.Errorf("unexpected error at ConvertBetweenOwnAccounts: %v", err)
+			}
+			if converted != core.NewMoney(1000, "USD") {
+				t.Errorf("expected: %v, found: %v", core.NewMoney(1000, "USD"), converted)
+			}
+		}
+
+		// Failed operations don't use up their key.
+		failed := core.WithIdempotencyKey("failed")
+		err = bank.TransferToByAccountId(ctx, 3, vasya, 1, core.NewMoney(1000000, core.DefaultCurrency), failed)
+		if ok := errors.Is(err, core.ErrInsufficientFunds); !ok {
+			t.Errorf("expected error: %v, found: %v", core.ErrInsufficientFunds, err)
+		}
+
+		err = bank.TransferToByAccountId(ctx, 3, vasya, 1, core.NewMoney(1000, core.DefaultCurrency), failed)
+		if err != nil {
+			t.Errorf("unexpected error at TransferToByAccountId: %v", err)
+		}
+
+		// Past the window the payment is made again.
+		date = date.Add(2 * time.Hour)
+		err = bank.PayForService(ctx, "Internet", 1, vasya, core.NewMoney(1000, core.DefaultCurrency), payment)
+		if err != nil {
+			t.Errorf("unexpected error at PayForService: %v", err)
+		}
+
+		accounts, err := bank.GetListOfAccountsWithClients(ctx)
+		if err != nil {
+			t.Errorf("unexpected error at GetListOfAccountsWithClients: %v", err)
+		}
+		expected := []core.AccountWithClientId{
+			{Id: 1, ClientId: 1, Balance: core.NewMoney(83000, core.DefaultCurrency)},
+			{Id: 2, ClientId: 1, Balance: core.NewMoney(1000, "USD")},
+			{Id: 3, ClientId: 2, Balance: core.NewMoney(5000, core.DefaultCurrency)},
+		}
+		if !reflect.DeepEqual(accounts, expected) {
+			t.Errorf("expected: %v, found: %v", expected, accounts)
+		}
+
+		err = bank.CheckLedger(ctx)
+		if err != nil {
+			t.Errorf("unexpected error at CheckLedger: %v", err)
+		}
+	})
+}
+
+func TestIdempotencyKeyWithoutContext(t *testing.T) {
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Errorf("can't open db: %v", err)
+	}
+	defer func() {
+		if err := db.Close(); err != nil {
+			t.Errorf("can't close db: %v", err)
+		}
+	}()
+
+	core.SetPasswordHasher(core.NewBcryptHasher(4))
+	defer core.SetPasswordHasher(core.DefaultPasswordHasher)
+
+	err = core.Init(db)
+	if err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	for _, phoneNumber := range []int64{1234, 4321} {
+		login := strconv.FormatInt(phoneNumber, 10)
+		err = core.AddClient(login, login, "1234", phoneNumber, db)
+		if err != nil {
+			t.Errorf("unexpected error at AddClient: %v", err)
+		}
+
+		err = core.AddAccount(phoneNumber, core.NewMoney(10000, core.DefaultCurrency), db)
+		if err != nil {
+			t.Errorf("unexpected error at AddAccount: %v", err)
+		}
+	}
+
+	session, err := core.StartSession("1234", "1234", "test", db)
+	if err != nil {
+		t.Errorf("unexpected error at StartSession: %v", err)
+	}
+
+	for i := 0; i < 2; i++ {
+		err = core.TransferToByAccountId(2, session, 1, core.NewMoney(1000, core.DefaultCurrency), db, core.WithIdempotencyKey("retry"))
+		if err != nil {
+			t.Errorf("unexpected error at TransferToByAccountId: %v", err)
+		}
+	}
+
+	accounts, err := core.GetListOfAccountsWithClients(db)
+	if err != nil {
+		t.Errorf("unexpected error at GetListOfAccountsWithClients: %v", err)
+	}
+	expected := []core.AccountWithClientId{
+		{Id: 1, ClientId: 1, Balance: core.NewMoney(9000, core.DefaultCurrency)},
+		{Id: 2, ClientId: 2, Balance: core.NewMoney(11000, core.DefaultCurrency)},
+	}
+	if !reflect.DeepEqual(accounts, expected) {
+		t.Errorf("expected: %v, found: %v", expected, accounts)
+	}
+}
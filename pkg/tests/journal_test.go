@@ -0,0 +1,383 @@
+package tests
+
+import (
+	"database/sql"
+	"errors"
+	"github.com/JAbduvohidov/apm-ibank-core/pkg/core"
+	_ "github.com/mattn/go-sqlite3"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestJournalHasBothSidesOfTransfer(t *testing.T) {
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Errorf("can't open db: %v", err)
+	}
+	defer func() {
+		if err := db.Close(); err != nil {
+			t.Errorf("can't close db: %v", err)
+		}
+	}()
+	db.SetMaxOpenConns(1)
+
+	err = core.Init(db)
+	if err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	err = core.AddClient("Vasya", "vasya", "1234", 1234, db)
+	if err != nil {
+		t.Errorf("unexpected error at AddClient: %v", err)
+	}
+
+	err = core.AddClient("Petya", "petya", "4321", 4321, db)
+	if err != nil {
+		t.Errorf("unexpected error at AddClient: %v", err)
+	}
+
+	err = core.AddAccount(1234, core.NewMoney(10000, core.DefaultCurrency), db)
+	if err != nil {
+		t.Errorf("unexpected error at AddAccount: %v", err)
+	}
+
+	err = core.AddAccount(4321, core.NewMoney(0, core.DefaultCurrency), db)
+	if err != nil {
+		t.Errorf("unexpected error at AddAccount: %v", err)
+	}
+
+	err = core.AddService("Internet", db)
+	if err != nil {
+		t.Errorf("unexpected error at AddService: %v", err)
+	}
+
+	session, err := core.StartSession("vasya", "1234", "test", db)
+	if err != nil {
+		t.Errorf("unexpected error at StartSession: %v", err)
+	}
+
+	err = core.TransferToByPhoneNumber(4321, session, 1, core.NewMoney(3000, core.DefaultCurrency), db)
+	if err != nil {
+		t.Errorf("unexpected error at TransferToByPhoneNumber: %v", err)
+	}
+
+	err = core.PayForService("Internet", 1, session, core.NewMoney(500, core.DefaultCurrency), db)
+	if err != nil {
+		t.Errorf("unexpected error at PayForService: %v", err)
+	}
+
+	sent, err := core.GetJournalListFormatted("vasya", 10, 0, db)
+	if err != nil {
+		t.Errorf("unexpected error at GetJournalListFormatted: %v", err)
+	}
+
+	if len(sent) != 2 {
+		t.Fatalf("expected 2 entries, found: %v", sent)
+	}
+
+	expected := core.Journal{
+		Type:                  core.Transfer,
+		Direction:             core.Outgoing,
+		AccountId:             1,
+		Counterparty:          "4321",
+		CounterpartyAccountId: 2,
+		TransferredTo:         "4321",
+		Amount:                core.NewMoney(3000, core.DefaultCurrency),
+	}
+	expected.Id, expected.Date = sent[0].Id, sent[0].Date
+	if sent[0] != expected {
+		t.Errorf("expected: %v, found: %v", expected, sent[0])
+	}
+
+	if sent[1].Type != core.Service || sent[1].Direction != core.Outgoing || sent[1].Counterparty != "Internet" {
+		t.Errorf("expected outgoing service payment, found: %v", sent[1])
+	}
+
+	received, err := core.GetJournalListFormatted("petya", 10, 0, db)
+	if err != nil {
+		t.Errorf("unexpected error at GetJournalListFormatted: %v", err)
+	}
+
+	if len(received) != 1 {
+		t.Fatalf("expected 1 entry, found: %v", received)
+	}
+
+	expected = core.Journal{
+		Type:                  core.Transfer,
+		Direction:             core.Incoming,
+		AccountId:             2,
+		Counterparty:          "1234",
+		CounterpartyAccountId: 1,
+		TransferredTo:         "4321",
+		Amount:                core.NewMoney(3000, core.DefaultCurrency),
+	}
+	expected.Id, expected.Date = received[0].Id, received[0].Date
+	if received[0] != expected {
+		t.Errorf("expected: %v, found: %v", expected, received[0])
+	}
+}
+
+type testClock struct {
+	current time.Time
+}
+
+func (receiver *testClock) Now() time.Time {
+	return receiver.current
+}
+
+func (receiver *testClock) Advance(d time.Duration) {
+	receiver.current = receiver.current.Add(d)
+}
+
+func TestJournalDatesSortAcrossYears(t *testing.T) {
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Errorf("can't open db: %v", err)
+	}
+	defer func() {
+		if err := db.Close(); err != nil {
+			t.Errorf("can't close db: %v", err)
+		}
+	}()
+	db.SetMaxOpenConns(1)
+
+	clock := &testClock{current: time.Date(2020, 1, 1, 9, 0, 0, 0, time.FixedZone("TJT", 5*60*60))}
+	core.SetClock(clock.Now)
+	defer core.SetClock(nil)
+
+	err = core.Init(db)
+	if err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	err = core.AddClient("Vasya", "vasya", "1234", 1234, db)
+	if err != nil {
+		t.Errorf("unexpected error at AddClient: %v", err)
+	}
+
+	err = core.AddAccount(1234, core.NewMoney(10000, core.DefaultCurrency), db)
+	if err != nil {
+		t.Errorf("unexpected error at AddAccount: %v", err)
+	}
+
+	err = core.AddService("Internet", db)
+	if err != nil {
+		t.Errorf("unexpected error at AddService: %v", err)
+	}
+
+	session, err := core.StartSession("vasya", "1234", "test", db)
+	if err != nil {
+		t.Errorf("unexpected error at StartSession: %v", err)
+	}
+
+	err = core.PayForService("Internet", 1, session, core.NewMoney(100, core.DefaultCurrency), db)
+	if err != nil {
+		t.Errorf("unexpected error at PayForService: %v", err)
+	}
+
+	clock.current = time.Date(2019, 12, 31, 23, 0, 0, 0, time.UTC)
+	session, err = core.StartSession("vasya", "1234", "test", db)
+	if err != nil {
+		t.Errorf("unexpected error at StartSession: %v", err)
+	}
+
+	err = core.PayForService("Internet", 1, session, core.NewMoney(200, core.DefaultCurrency), db)
+	if err != nil {
+		t.Errorf("unexpected error at PayForService: %v", err)
+	}
+
+	journals, err := core.GetJournalListFormatted("vasya", 10, 0, db)
+	if err != nil {
+		t.Errorf("unexpected error at GetJournalListFormatted: %v", err)
+	}
+
+	if len(journals) != 2 {
+		t.Fatalf("expected 2 entries, found: %v", journals)
+	}
+
+	if !journals[0].Date.Equal(time.Date(2019, 12, 31, 23, 0, 0, 0, time.UTC)) || journals[0].Amount.Amount != 200 {
+		t.Errorf("expected the payment of 2019-12-31T23:00:00Z first, found: %v", journals[0])
+	}
+
+	if !journals[1].Date.Equal(time.Date(2020, 1, 1, 4, 0, 0, 0, time.UTC)) || journals[1].Date.Location() != time.UTC {
+		t.Errorf("expected 2020-01-01T04:00:00Z in UTC, found: %v", journals[1].Date)
+	}
+}
+
+func TestInitMigratesLegacyJournalDates(t *testing.T) {
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Errorf("can't open db: %v", err)
+	}
+	defer func() {
+		if err := db.Close(); err != nil {
+			t.Errorf("can't close db: %v", err)
+		}
+	}()
+	db.SetMaxOpenConns(1)
+
+	_, err = db.Exec(`CREATE TABLE journal
+(
+    id             INTEGER PRIMARY KEY AUTOINCREMENT,
+    date           TEXT    NOT NULL,
+    client_id      INTEGER NOT NULL REFERENCES clients,
+    type           TEXT    NOT NULL,
+    transferred_to TEXT    NOT NULL,
+    amount         INTEGER NOT NULL check ( amount > 0 )
+);
+INSERT INTO journal(date, client_id, type, transferred_to, amount)
+VALUES ('01-01-2020 01:00:00', 1, 'service', 'Internet', 100),
+       ('12-31-2019 23:00:00', 1, 'service', 'Internet', 200);`)
+	if err != nil {
+		t.Errorf("unexpected error at Exec: %v", err)
+	}
+
+	err = core.Init(db)
+	if err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	err = core.AddClient("Vasya", "vasya", "1234", 1234, db)
+	if err != nil {
+		t.Errorf("unexpected error at AddClient: %v", err)
+	}
+
+	journals, err := core.GetJournalListFormatted("vasya", 10, 0, db)
+	if err != nil {
+		t.Errorf("unexpected error at GetJournalListFormatted: %v", err)
+	}
+
+	expected := []time.Time{
+		time.Date(2019, 12, 31, 23, 0, 0, 0, time.Local),
+		time.Date(2020, 1, 1, 1, 0, 0, 0, time.Local),
+	}
+	if len(journals) != len(expected) {
+		t.Fatalf("expected %d entries, found: %v", len(expected), journals)
+	}
+	for i, journal := range journals {
+		if !journal.Date.Equal(expected[i]) {
+			t.Errorf("expected: %v, found: %v", expected[i], journal.Date)
+		}
+	}
+}
+
+func TestGetJournalListFilters(t *testing.T) {
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Errorf("can't open db: %v", err)
+	}
+	defer func() {
+		if err := db.Close(); err != nil {
+			t.Errorf("can't close db: %v", err)
+		}
+	}()
+	db.SetMaxOpenConns(1)
+
+	day := time.Date(2020, 3, 1, 12, 0, 0, 0, time.UTC)
+	clock := &testClock{current: day}
+	core.SetClock(clock.Now)
+	defer core.SetClock(nil)
+
+	err = core.Init(db)
+	if err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	err = core.AddClient("Vasya", "vasya", "1234", 1234, db)
+	if err != nil {
+		t.Errorf("unexpected error at AddClient: %v", err)
+	}
+
+	err = core.AddClient("Petya", "petya", "4321", 4321, db)
+	if err != nil {
+		t.Errorf("unexpected error at AddClient: %v", err)
+	}
+
+	err = core.AddAccount(1234, core.NewMoney(10000, core.DefaultCurrency), db)
+	if err != nil {
+		t.Errorf("unexpected error at AddAccount: %v", err)
+	}
+
+	err = core.AddAccount(1234, core.NewMoney(1000, "USD"), db)
+	if err != nil {
+		t.Errorf("unexpected error at AddAccount: %v", err)
+	}
+
+	err = core.AddAccount(4321, core.NewMoney(0, core.DefaultCurrency), db)
+	if err != nil {
+		t.Errorf("unexpected error at AddAccount: %v", err)
+	}
+
+	err = core.AddService("Internet", db)
+	if err != nil {
+		t.Errorf("unexpected error at AddService: %v", err)
+	}
+
+	operations := []func(session core.Session) error{
+		func(session core.Session) error {
+			return core.PayForService("Internet", 1, session, core.NewMoney(100, core.DefaultCurrency), db)
+		},
+		func(session core.Session) error {
+			return core.TransferToByPhoneNumber(4321, session, 1, core.NewMoney(500, core.DefaultCurrency), db)
+		},
+		func(session core.Session) error {
+			return core.TransferToByAccountId(3, session, 1, core.NewMoney(2000, core.DefaultCurrency), db)
+		},
+		func(session core.Session) error {
+			return core.PayForService("Internet", 2, session, core.NewMoney(500, "USD"), db)
+		},
+	}
+	for i, operation := range operations {
+		clock.current = day.AddDate(0, 0, i)
+
+		session, err := core.StartSession("vasya", "1234", "test", db)
+		if err != nil {
+			t.Errorf("unexpected error at StartSession: %v", err)
+		}
+
+		err = operation(session)
+		if err != nil {
+			t.Errorf("unexpected error at operation %d: %v", i, err)
+		}
+	}
+
+	query := core.NewJournalQuery("vasya")
+	cases := []struct {
+		name     string
+		query    core.JournalQuery
+		expected []int64
+	}{
+		{"all", query, []int64{100, 500, 2000, 500}},
+		{"type", query.Types(core.Service), []int64{100, 500}},
+		{"types", query.Types(core.Service, core.Transfer), []int64{100, 500, 2000, 500}},
+		{"date range", query.From(day.AddDate(0, 0, 1)).To(day.AddDate(0, 0, 3)), []int64{500, 2000}},
+		{"counterparty", query.Counterparty("4321"), []int64{500, 2000}},
+		{"account", query.AccountId(2), []int64{500}},
+		{"min amount", query.MinAmount(core.NewMoney(500, core.DefaultCurrency)), []int64{500, 2000}},
+		{"amount range", query.MinAmount(core.NewMoney(500, core.DefaultCurrency)).MaxAmount(core.NewMoney(500, core.DefaultCurrency)), []int64{500}},
+		{"descending", query.Descending(), []int64{500, 2000, 500, 100}},
+		{"page", query.Descending().Page(2, 1), []int64{2000, 500}},
+		{"nothing", query.Types(core.Conversion), nil},
+	}
+
+	for _, testCase := range cases {
+		journals, err := core.GetJournalList(testCase.query, db)
+		if err != nil {
+			t.Errorf("%s: unexpected error at GetJournalList: %v", testCase.name, err)
+		}
+
+		var amounts []int64
+		for _, journal := range journals {
+			amounts = append(amounts, journal.Amount.Amount)
+		}
+		if !reflect.DeepEqual(amounts, testCase.expected) {
+			t.Errorf("%s: expected: %v, found: %v", testCase.name, testCase.expected, amounts)
+		}
+	}
+
+	_, err = core.GetJournalList(query.MinAmount(core.NewMoney(1, core.DefaultCurrency)).MaxAmount(core.NewMoney(1, "USD")), db)
+	if ok := errors.Is(err, core.ErrCurrencyMismatch); !ok {
+		t.Errorf("expected error: %v, found: %v", core.ErrCurrencyMismatch, err)
+	}
+}
@@ -0,0 +1,260 @@
+package tests
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"github.com/JAbduvohidov/apm-ibank-core/pkg/core"
+	_ "github.com/mattn/go-sqlite3"
+	"testing"
+	"time"
+)
+
+func checkLedgerBalances(t *testing.T, db *sql.DB) {
+	err := core.CheckLedger(db)
+	if err != nil {
+		t.Errorf("unexpected error at CheckLedger: %v", err)
+	}
+
+	accounts, err := core.GetListOfAccountsWithClients(db)
+	if err != nil {
+		t.Errorf("unexpected error at GetListOfAccountsWithClients: %v", err)
+	}
+
+	for _, account := range accounts {
+		balance, err := core.GetLedgerBalance(account.Id, db)
+		if err != nil {
+			t.Errorf("unexpected error at GetLedgerBalance: %v", err)
+		}
+		if balance != account.Balance {
+			t.Errorf("account %d: expected: %v, found: %v", account.Id, account.Balance, balance)
+		}
+	}
+}
+
+func TestLedgerStaysBalanced(t *testing.T) {
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Errorf("can't open db: %v", err)
+	}
+	defer func() {
+		if err := db.Close(); err != nil {
+			t.Errorf("can't close db: %v", err)
+		}
+	}()
+	db.SetMaxOpenConns(1)
+
+	err = core.Init(db)
+	if err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	err = core.AddClient("Vasya", "vasya", "1234", 1234, db)
+	if err != nil {
+		t.Errorf("unexpected error at AddClient: %v", err)
+	}
+
+	err = core.AddClient("Petya", "petya", "4321", 4321, db)
+	if err != nil {
+		t.Errorf("unexpected error at AddClient: %v", err)
+	}
+
+	err = core.AddAccount(1234, core.NewMoney(100000, core.DefaultCurrency), db)
+	if err != nil {
+		t.Errorf("unexpected error at AddAccount: %v", err)
+	}
+
+	err = core.AddAccount(1234, core.NewMoney(0, "USD"), db)
+	if err != nil {
+		t.Errorf("unexpected error at AddAccount: %v", err)
+	}
+
+	err = core.AddAccount(4321, core.NewMoney(5000, core.DefaultCurrency), db)
+	if err != nil {
+		t.Errorf("unexpected error at AddAccount: %v", err)
+	}
+
+	err = core.AddService("Internet", db)
+	if err != nil {
+		t.Errorf("unexpected error at AddService: %v", err)
+	}
+
+	err = core.LoadExchangeRates([]core.ExchangeRate{
+		{Base: "USD", Quote: core.DefaultCurrency, Buy: mustParseRate(t, "10.9"), Sell: mustParseRate(t, "11.05")},
+	}, db)
+	if err != nil {
+		t.Errorf("unexpected error at LoadExchangeRates: %v", err)
+	}
+	checkLedgerBalances(t, db)
+
+	session, err := core.StartSession("vasya", "1234", "test", db)
+	if err != nil {
+		t.Errorf("unexpected error at StartSession: %v", err)
+	}
+
+	err = core.PayForService("Internet", 1, session, core.NewMoney(1500, core.DefaultCurrency), db)
+	if err != nil {
+		t.Errorf("unexpected error at PayForService: %v", err)
+	}
+	checkLedgerBalances(t, db)
+
+	err = core.TransferToByAccountId(3, session, 1, core.NewMoney(2500, core.DefaultCurrency), db)
+	if err != nil {
+		t.Errorf("unexpected error at TransferToByAccountId: %v", err)
+	}
+	checkLedgerBalances(t, db)
+
+	err = core.TransferToByPhoneNumber(4321, session, 1, core.NewMoney(3000, core.DefaultCurrency), db)
+	if err != nil {
+		t.Errorf("unexpected error at TransferToByPhoneNumber: %v", err)
+	}
+	checkLedgerBalances(t, db)
+
+	_, err = core.ConvertBetweenOwnAccounts(session, 1, 2, core.NewMoney(11111, core.DefaultCurrency), db)
+	if err != nil {
+		t.Errorf("unexpected error at ConvertBetweenOwnAccounts: %v", err)
+	}
+	checkLedgerBalances(t, db)
+
+	err = core.TransferToByAccountId(3, session, 1, core.NewMoney(1000000, core.DefaultCurrency), db)
+	if ok := errors.Is(err, core.ErrInsufficientFunds); !ok {
+		t.Errorf("expected error: %v, found: %v", core.ErrInsufficientFunds, err)
+	}
+	checkLedgerBalances(t, db)
+
+	err = core.ImportListOfAccounts([]core.AccountWithClientId{
+		{Id: 2, ClientId: 1, Balance: core.NewMoney(700, "EUR")},
+		{Id: 3, ClientId: 2, Balance: core.NewMoney(20000, core.DefaultCurrency)},
+		{Id: 4, ClientId: 2, Balance: core.NewMoney(300, "USD")},
+	}, db)
+	if err != nil {
+		t.Errorf("unexpected error at ImportListOfAccounts: %v", err)
+	}
+	checkLedgerBalances(t, db)
+}
+
+func TestCheckLedgerFindsDrift(t *testing.T) {
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Errorf("can't open db: %v", err)
+	}
+	defer func() {
+		if err := db.Close(); err != nil {
+			t.Errorf("can't close db: %v", err)
+		}
+	}()
+	db.SetMaxOpenConns(1)
+
+	err = core.Init(db)
+	if err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	err = core.AddClient("Vasya", "vasya", "1234", 1234, db)
+	if err != nil {
+		t.Errorf("unexpected error at AddClient: %v", err)
+	}
+
+	err = core.AddAccount(1234, core.NewMoney(100000, core.DefaultCurrency), db)
+	if err != nil {
+		t.Errorf("unexpected error at AddAccount: %v", err)
+	}
+
+	_, err = db.Exec(`UPDATE accounts SET balance = balance + 1 WHERE id = 1;`)
+	if err != nil {
+		t.Errorf("unexpected error at Exec: %v", err)
+	}
+
+	err = core.CheckLedger(db)
+	if ok := errors.Is(err, core.ErrLedgerMismatch); !ok {
+		t.Errorf("expected error: %v, found: %v", core.ErrLedgerMismatch, err)
+	}
+
+	_, err = db.Exec(`INSERT INTO ledger_entries(posting_id, account_id, amount, currency) VALUES (1, 1, 1, 'TJS');`)
+	if err != nil {
+		t.Errorf("unexpected error at Exec: %v", err)
+	}
+
+	err = core.CheckLedger(db)
+	if ok := errors.Is(err, core.ErrUnbalancedPosting); !ok {
+		t.Errorf("expected error: %v, found: %v", core.ErrUnbalancedPosting, err)
+	}
+}
+
+func TestInitOpensLedgerOfExistingAccounts(t *testing.T) {
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Errorf("can't open db: %v", err)
+	}
+	defer func() {
+		if err := db.Close(); err != nil {
+			t.Errorf("can't close db: %v", err)
+		}
+	}()
+	db.SetMaxOpenConns(1)
+
+	_, err = db.Exec(`CREATE TABLE accounts
+(
+    id        INTEGER PRIMARY KEY AUTOINCREMENT,
+    client_id INTEGER NOT NULL REFERENCES clients,
+    balance   INTEGER NOT NULL check ( balance >= 0 ),
+    currency  TEXT    NOT NULL DEFAULT 'TJS'
+);
+INSERT INTO accounts(client_id, balance, currency) VALUES (1, 4200, 'TJS'), (1, 0, 'USD');`)
+	if err != nil {
+		t.Errorf("unexpected error at Exec: %v", err)
+	}
+
+	err = core.Init(db)
+	if err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	checkLedgerBalances(t, db)
+}
+
+func TestInitDatesBackfillWithBankClock(t *testing.T) {
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Errorf("can't open db: %v", err)
+	}
+	defer func() {
+		if err := db.Close(); err != nil {
+			t.Errorf("can't close db: %v", err)
+		}
+	}()
+	db.SetMaxOpenConns(1)
+
+	_, err = db.Exec(`CREATE TABLE accounts
+(
+    id        INTEGER PRIMARY KEY AUTOINCREMENT,
+    client_id INTEGER NOT NULL REFERENCES clients,
+    balance   INTEGER NOT NULL check ( balance >= 0 ),
+    currency  TEXT    NOT NULL DEFAULT 'TJS'
+);
+INSERT INTO accounts(client_id, balance, currency) VALUES (1, 4200, 'TJS');`)
+	if err != nil {
+		t.Errorf("unexpected error at Exec: %v", err)
+	}
+
+	date := time.Date(2020, time.March, 1, 12, 0, 0, 0, time.UTC)
+	bank := core.NewBank(core.NewSQLiteStore(db), core.WithClock(func() time.Time { return date }))
+	err = bank.Init(context.Background())
+	if err != nil {
+		t.Errorf("unexpected error at Init: %v", err)
+	}
+
+	expected := date.Format("2006-01-02T15:04:05.000000000Z")
+	for _, query := range []string{
+		`SELECT DISTINCT created_at FROM postings;`,
+		`SELECT DISTINCT applied_at FROM schema_version;`,
+	} {
+		var found string
+		err = db.QueryRow(query).Scan(&found)
+		if err != nil {
+			t.Errorf("unexpected error at %s: %v", query, err)
+		}
+		if found != expected {
+			t.Errorf("expected: %v, found: %v", expected, found)
+		}
+	}
+}
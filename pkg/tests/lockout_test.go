@@ -0,0 +1,223 @@
+package tests
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"github.com/JAbduvohidov/apm-ibank-core/pkg/core"
+	_ "github.com/mattn/go-sqlite3"
+	"testing"
+	"time"
+)
+
+func TestLoginTemporaryLock(t *testing.T) {
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Errorf("can't open db: %v", err)
+	}
+	defer func() {
+		if err := db.Close(); err != nil {
+			t.Errorf("can't close db: %v", err)
+		}
+	}()
+
+	clock := &testClock{current: time.Date(2020, 1, 1, 12, 0, 0, 0, time.UTC)}
+	core.SetClock(clock.Now)
+	defer core.SetClock(nil)
+
+	core.SetLockoutPolicy(core.LockoutPolicy{
+		MaxFailedAttempts: 3,
+		Window:            time.Minute,
+		LockDuration:      30 * time.Minute,
+	})
+	defer core.SetLockoutPolicy(core.DefaultLockoutPolicy)
+
+	err = core.Init(db)
+	if err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	err = core.AddClient("Vasya", "vasya", "1234", 999999999, db)
+	if err != nil {
+		t.Errorf("unexpected error at AddClient: %v", err)
+	}
+
+	for i := 0; i < 3; i++ {
+		_, err = core.Login("vasya", "wrong", db)
+		if ok := errors.Is(err, core.ErrInvalidPass); !ok {
+			t.Errorf("expected error: %v, found: %v", core.ErrInvalidPass, err)
+		}
+	}
+
+	_, err = core.Login("vasya", "1234", db)
+	if ok := errors.Is(err, core.ErrClientTemporarilyLocked); !ok {
+		t.Errorf("expected error: %v, found: %v", core.ErrClientTemporarilyLocked, err)
+	}
+
+	clock.Advance(29 * time.Minute)
+
+	_, err = core.Login("vasya", "1234", db)
+	if ok := errors.Is(err, core.ErrClientTemporarilyLocked); !ok {
+		t.Errorf("expected error: %v, found: %v", core.ErrClientTemporarilyLocked, err)
+	}
+
+	clock.Advance(time.Minute)
+
+	_, err = core.Login("vasya", "1234", db)
+	if err != nil {
+		t.Errorf("lock must expire, found: %v", err)
+	}
+}
+
+func TestLoginFailuresResetOnSuccess(t *testing.T) {
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Errorf("can't open db: %v", err)
+	}
+	defer func() {
+		if err := db.Close(); err != nil {
+			t.Errorf("can't close db: %v", err)
+		}
+	}()
+
+	core.SetLockoutPolicy(core.LockoutPolicy{
+		MaxFailedAttempts: 2,
+		Window:            time.Minute,
+		LockDuration:      time.Minute,
+	})
+	defer core.SetLockoutPolicy(core.DefaultLockoutPolicy)
+
+	err = core.Init(db)
+	if err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	err = core.AddClient("Vasya", "vasya", "1234", 999999999, db)
+	if err != nil {
+		t.Errorf("unexpected error at AddClient: %v", err)
+	}
+
+	for i := 0; i < 3; i++ {
+		_, err = core.Login("vasya", "wrong", db)
+		if ok := errors.Is(err, core.ErrInvalidPass); !ok {
+			t.Errorf("expected error: %v, found: %v", core.ErrInvalidPass, err)
+		}
+
+		_, err = core.Login("vasya", "1234", db)
+		if err != nil {
+			t.Errorf("successful login must reset failures, found: %v", err)
+		}
+	}
+}
+
+func TestLoginPermanentLockNeedsAdmin(t *testing.T) {
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Errorf("can't open db: %v", err)
+	}
+	defer func() {
+		if err := db.Close(); err != nil {
+			t.Errorf("can't close db: %v", err)
+		}
+	}()
+
+	core.SetLockoutPolicy(core.LockoutPolicy{
+		MaxFailedAttempts: 2,
+		Window:            time.Minute,
+	})
+	defer core.SetLockoutPolicy(core.DefaultLockoutPolicy)
+
+	err = core.Init(db)
+	if err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	err = core.AddClient("Vasya", "vasya", "1234", 999999999, db)
+	if err != nil {
+		t.Errorf("unexpected error at AddClient: %v", err)
+	}
+
+	for i := 0; i < 2; i++ {
+		_, err = core.Login("vasya", "wrong", db)
+		if ok := errors.Is(err, core.ErrInvalidPass); !ok {
+			t.Errorf("expected error: %v, found: %v", core.ErrInvalidPass, err)
+		}
+	}
+
+	_, err = core.Login("vasya", "1234", db)
+	if ok := errors.Is(err, core.ErrClientIsLocked); !ok {
+		t.Errorf("expected error: %v, found: %v", core.ErrClientIsLocked, err)
+	}
+
+	err = core.ChangeClientStatus(999999999, core.Active, db)
+	if err != nil {
+		t.Errorf("unexpected error at ChangeClientStatus: %v", err)
+	}
+
+	_, err = core.Login("vasya", "1234", db)
+	if err != nil {
+		t.Errorf("admin unlock must allow login, found: %v", err)
+	}
+}
+
+func TestLockoutWithoutLogger(t *testing.T) {
+	bank := core.NewBank(
+		core.NewMemoryStore(),
+		core.WithPasswordHasher(core.NewBcryptHasher(4)),
+		core.WithLockoutPolicy(core.LockoutPolicy{MaxFailedAttempts: 1, Window: time.Minute}),
+		core.WithLogger(nil),
+	)
+	ctx := context.Background()
+
+	err := bank.AddClient(ctx, "Vasya", "vasya", "1234", 999999999)
+	if err != nil {
+		t.Errorf("unexpected error at AddClient: %v", err)
+	}
+
+	_, err = bank.Login(ctx, "vasya", "wrong")
+	if ok := errors.Is(err, core.ErrInvalidPass); !ok {
+		t.Errorf("expected error: %v, found: %v", core.ErrInvalidPass, err)
+	}
+
+	_, err = bank.Login(ctx, "vasya", "1234")
+	if ok := errors.Is(err, core.ErrClientIsLocked); !ok {
+		t.Errorf("expected error: %v, found: %v", core.ErrClientIsLocked, err)
+	}
+}
+
+// lockingHasher locks the client while the password is being checked.
+type lockingHasher struct {
+	core.PasswordHasher
+	lock func()
+}
+
+func (receiver lockingHasher) Verify(hash, password string) (ok bool, err error) {
+	receiver.lock()
+	return receiver.PasswordHasher.Verify(hash, password)
+}
+
+func TestLoginRefusedWhenLockedDuringCheck(t *testing.T) {
+	store := core.NewMemoryStore()
+	ctx := context.Background()
+	bank := core.NewBank(store, core.WithPasswordHasher(core.NewBcryptHasher(4)))
+
+	err := bank.AddClient(ctx, "Vasya", "vasya", "1234", 999999999)
+	if err != nil {
+		t.Errorf("unexpected error at AddClient: %v", err)
+	}
+
+	locking := core.NewBank(store, core.WithPasswordHasher(lockingHasher{
+		PasswordHasher: core.NewBcryptHasher(4),
+		lock: func() {
+			err := bank.ChangeClientStatus(ctx, 999999999, core.Locked)
+			if err != nil {
+				t.Errorf("unexpected error at ChangeClientStatus: %v", err)
+			}
+		},
+	}))
+
+	_, err = locking.Authenticate(ctx, "vasya", "1234")
+	if ok := errors.Is(err, core.ErrClientIsLocked); !ok {
+		t.Errorf("expected error: %v, found: %v", core.ErrClientIsLocked, err)
+	}
+}
@@ -0,0 +1,223 @@
+package tests
+
+import (
+	"database/sql"
+	"errors"
+	"github.com/JAbduvohidov/apm-ibank-core/pkg/core"
+	_ "github.com/mattn/go-sqlite3"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestMigrateUpAndDown(t *testing.T) {
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Errorf("can't open db: %v", err)
+	}
+	defer func() {
+		if err := db.Close(); err != nil {
+			t.Errorf("can't close db: %v", err)
+		}
+	}()
+	db.SetMaxOpenConns(1)
+
+	status, err := core.GetSchemaStatus(db)
+	if err != nil {
+		t.Errorf("unexpected error at GetSchemaStatus: %v", err)
+	}
+
+	latest := core.LatestSchemaVersion()
+	if status.Current != 0 || status.Latest != latest || int64(len(status.Pending)) != latest {
+		t.Errorf("unexpected status of an empty database: %v", status)
+	}
+
+	err = core.Init(db)
+	if err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	err = core.Init(db)
+	if err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	status, err = core.GetSchemaStatus(db)
+	if err != nil {
+		t.Errorf("unexpected error at GetSchemaStatus: %v", err)
+	}
+
+	if status.Current != latest || status.Pending != nil {
+		t.Errorf("unexpected status after Init: %v", status)
+	}
+
+	err = core.AddClient("Vasya", "vasya", "1234", 1234, db)
+	if err != nil {
+		t.Errorf("unexpected error at AddClient: %v", err)
+	}
+
+	err = core.AddClient("Petya", "petya", "4321", 4321, db)
+	if err != nil {
+		t.Errorf("unexpected error at AddClient: %v", err)
+	}
+
+	err = core.AddAccount(1234, core.NewMoney(10000, core.DefaultCurrency), db)
+	if err != nil {
+		t.Errorf("unexpected error at AddAccount: %v", err)
+	}
+
+	err = core.AddAccount(4321, core.NewMoney(0, core.DefaultCurrency), db)
+	if err != nil {
+		t.Errorf("unexpected error at AddAccount: %v", err)
+	}
+
+	session, err := core.StartSession("vasya", "1234", "test", db)
+	if err != nil {
+		t.Errorf("unexpected error at StartSession: %v", err)
+	}
+
+	err = core.TransferToByPhoneNumber(4321, session, 1, core.NewMoney(2500, core.DefaultCurrency), db)
+	if err != nil {
+		t.Errorf("unexpected error at TransferToByPhoneNumber: %v", err)
+	}
+
+	accounts, err := core.GetListOfAccountsWithClients(db)
+	if err != nil {
+		t.Errorf("unexpected error at GetListOfAccountsWithClients: %v", err)
+	}
+
+	sent, err := core.GetJournalListFormatted("vasya", 10, 0, db)
+	if err != nil {
+		t.Errorf("unexpected error at GetJournalListFormatted: %v", err)
+	}
+
+	for version := latest - 1; version >= 1; version-- {
+		err = core.MigrateTo(version, db)
+		if err != nil {
+			t.Fatalf("unexpected error at MigrateTo(%d): %v", version, err)
+		}
+
+		status, err = core.GetSchemaStatus(db)
+		if err != nil {
+			t.Errorf("unexpected error at GetSchemaStatus: %v", err)
+		}
+		if status.Current != version || int64(len(status.Pending)) != latest-version {
+			t.Errorf("unexpected status after MigrateTo(%d): %v", version, status)
+		}
+	}
+
+	err = core.Migrate(db)
+	if err != nil {
+		t.Fatalf("unexpected error at Migrate: %v", err)
+	}
+
+	reimported, err := core.GetListOfAccountsWithClients(db)
+	if err != nil {
+		t.Errorf("unexpected error at GetListOfAccountsWithClients: %v", err)
+	}
+	if !reflect.DeepEqual(accounts, reimported) {
+		t.Errorf("expected: %v, found: %v", accounts, reimported)
+	}
+	checkLedgerBalances(t, db)
+
+	journals, err := core.GetJournalListFormatted("vasya", 10, 0, db)
+	if err != nil {
+		t.Errorf("unexpected error at GetJournalListFormatted: %v", err)
+	}
+	if len(journals) != 1 || !journals[0].Date.Equal(sent[0].Date.Truncate(time.Second)) || journals[0].Amount != sent[0].Amount {
+		t.Errorf("expected: %v, found: %v", sent, journals)
+	}
+
+	err = core.MigrateTo(0, db)
+	if err != nil {
+		t.Errorf("unexpected error at MigrateTo(0): %v", err)
+	}
+
+	err = core.Migrate(db)
+	if err != nil {
+		t.Errorf("unexpected error at Migrate: %v", err)
+	}
+
+	clients, err := core.GetListOfClients(db)
+	if err != nil {
+		t.Errorf("unexpected error at GetListOfClients: %v", err)
+	}
+	if clients != nil {
+		t.Errorf("empty list must be nil, found: %v", clients)
+	}
+
+	err = core.MigrateTo(latest+1, db)
+	if ok := errors.Is(err, core.ErrUnknownSchemaVersion); !ok {
+		t.Errorf("expected error: %v, found: %v", core.ErrUnknownSchemaVersion, err)
+	}
+}
+
+func TestMigrateRefusesNewerSchema(t *testing.T) {
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Errorf("can't open db: %v", err)
+	}
+	defer func() {
+		if err := db.Close(); err != nil {
+			t.Errorf("can't close db: %v", err)
+		}
+	}()
+	db.SetMaxOpenConns(1)
+
+	err = core.Init(db)
+	if err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	_, err = db.Exec(`INSERT INTO schema_version(version, description, applied_at) VALUES (?, 'future', '')`, core.LatestSchemaVersion()+1)
+	if err != nil {
+		t.Errorf("unexpected error at Exec: %v", err)
+	}
+
+	err = core.Init(db)
+	if ok := errors.Is(err, core.ErrUnknownSchemaVersion); !ok {
+		t.Errorf("expected error: %v, found: %v", core.ErrUnknownSchemaVersion, err)
+	}
+}
+
+func TestFailedMigrationIsRolledBack(t *testing.T) {
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Errorf("can't open db: %v", err)
+	}
+	defer func() {
+		if err := db.Close(); err != nil {
+			t.Errorf("can't close db: %v", err)
+		}
+	}()
+	db.SetMaxOpenConns(1)
+
+	_, err = db.Exec(`CREATE TABLE postings(id INTEGER PRIMARY KEY);
+CREATE TABLE accounts(id INTEGER PRIMARY KEY AUTOINCREMENT, client_id INTEGER NOT NULL, balance INTEGER NOT NULL);
+INSERT INTO accounts(client_id, balance) VALUES (1, 100);`)
+	if err != nil {
+		t.Errorf("unexpected error at Exec: %v", err)
+	}
+
+	err = core.Init(db)
+	if err == nil {
+		t.Errorf("migration over a broken postings table must fail")
+	}
+
+	status, err := core.GetSchemaStatus(db)
+	if err != nil {
+		t.Errorf("unexpected error at GetSchemaStatus: %v", err)
+	}
+	if status.Current != 3 {
+		t.Errorf("expected version: 3, found: %v", status)
+	}
+
+	var count int64
+	err = db.QueryRow(`SELECT COUNT(*) FROM sqlite_master WHERE name = 'ledger_entries'`).Scan(&count)
+	if err != nil {
+		t.Errorf("unexpected error at QueryRow: %v", err)
+	}
+	if count != 0 {
+		t.Errorf("a failed migration must not leave tables behind")
+	}
+}
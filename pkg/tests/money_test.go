@@ -0,0 +1,73 @@
+package tests
+
+import (
+	"errors"
+	"github.com/JAbduvohidov/apm-ibank-core/pkg/core"
+	"testing"
+)
+
+func TestParseMoney(t *testing.T) {
+	cases := []struct {
+		value    string
+		expected int64
+		err      error
+	}{
+		{"0.29", 29, nil},
+		{"12", 1200, nil},
+		{"12.3", 1230, nil},
+		{"12.30", 1230, nil},
+		{"-1.05", -105, nil},
+		{"1.000", 100, nil},
+		{"1.005", 0, core.ErrFractionalCents},
+		{"1.2.3", 0, core.ErrInvalidMoney},
+		{"abc", 0, core.ErrInvalidMoney},
+		{"", 0, core.ErrInvalidMoney},
+		{"99999999999999999999", 0, core.ErrInvalidMoney},
+	}
+
+	yen, err := core.ParseMoney("150", "JPY")
+	if err != nil || yen != core.NewMoney(150, "JPY") {
+		t.Errorf("expected: 150 JPY, found: %v, %v", yen, err)
+	}
+
+	_, err = core.ParseMoney("1.5", "JPY")
+	if ok := errors.Is(err, core.ErrFractionalCents); !ok {
+		t.Errorf("expected error: %v, found: %v", core.ErrFractionalCents, err)
+	}
+
+	_, err = core.ParseMoney("1", "XXX")
+	if ok := errors.Is(err, core.ErrUnsupportedCurrency); !ok {
+		t.Errorf("expected error: %v, found: %v", core.ErrUnsupportedCurrency, err)
+	}
+
+	for _, c := range cases {
+		money, err := core.ParseMoney(c.value, core.DefaultCurrency)
+		if ok := errors.Is(err, c.err); !ok {
+			t.Errorf("%q: expected error: %v, found: %v", c.value, c.err, err)
+			continue
+		}
+
+		if err == nil && money != core.NewMoney(c.expected, core.DefaultCurrency) {
+			t.Errorf("%q: expected: %d, found: %v", c.value, c.expected, money)
+		}
+	}
+}
+
+func TestMoneyString(t *testing.T) {
+	cases := []struct {
+		money    core.Money
+		expected string
+	}{
+		{core.NewMoney(29, core.DefaultCurrency), "0.29 TJS"},
+		{core.NewMoney(123456, core.DefaultCurrency), "1234.56 TJS"},
+		{core.NewMoney(-5, core.DefaultCurrency), "-0.05 TJS"},
+		{core.NewMoney(0, core.DefaultCurrency), "0.00 TJS"},
+		{core.NewMoney(150, "JPY"), "150 JPY"},
+	}
+
+	for _, c := range cases {
+		if c.money.String() != c.expected {
+			t.Errorf("expected: %s, found: %s", c.expected, c.money.String())
+		}
+	}
+}
@@ -0,0 +1,85 @@
+package tests
+
+import (
+	"database/sql"
+	"errors"
+	"github.com/JAbduvohidov/apm-ibank-core/pkg/core"
+	_ "github.com/mattn/go-sqlite3"
+	"testing"
+)
+
+func TestCrossClientDebitIsRejected(t *testing.T) {
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Errorf("can't open db: %v", err)
+	}
+	defer func() {
+		if err := db.Close(); err != nil {
+			t.Errorf("can't close db: %v", err)
+		}
+	}()
+
+	err = core.Init(db)
+	if err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	err = core.AddService("Water", db)
+	if err != nil {
+		t.Errorf("unexpected error at AddService: %v", err)
+	}
+
+	err = core.AddClient("Vasya", "vasya", "1234", 1234, db)
+	if err != nil {
+		t.Errorf("unexpected error at AddClient: %v", err)
+	}
+
+	err = core.AddClient("Petya", "petya", "1234", 5678, db)
+	if err != nil {
+		t.Errorf("unexpected error at AddClient: %v", err)
+	}
+
+	err = core.AddAccount(1234, core.NewMoney(10000, core.DefaultCurrency), db)
+	if err != nil {
+		t.Errorf("unexpected error at AddAccount: %v", err)
+	}
+
+	err = core.AddAccount(5678, core.NewMoney(50000, core.DefaultCurrency), db)
+	if err != nil {
+		t.Errorf("unexpected error at AddAccount: %v", err)
+	}
+
+	session, err := core.StartSession("vasya", "1234", "test", db)
+	if err != nil {
+		t.Errorf("unexpected error at StartSession: %v", err)
+	}
+
+	err = core.PayForService("Water", 2, session, core.NewMoney(1000, core.DefaultCurrency), db)
+	if ok := errors.Is(err, core.ErrAccountNotOwned); !ok {
+		t.Errorf("expected error: %v, found: %v", core.ErrAccountNotOwned, err)
+	}
+
+	err = core.TransferToByAccountId(1, session, 2, core.NewMoney(1000, core.DefaultCurrency), db)
+	if ok := errors.Is(err, core.ErrAccountNotOwned); !ok {
+		t.Errorf("expected error: %v, found: %v", core.ErrAccountNotOwned, err)
+	}
+
+	err = core.TransferToByPhoneNumber(1234, session, 2, core.NewMoney(1000, core.DefaultCurrency), db)
+	if ok := errors.Is(err, core.ErrAccountNotOwned); !ok {
+		t.Errorf("expected error: %v, found: %v", core.ErrAccountNotOwned, err)
+	}
+
+	err = core.TransferToByAccountId(2, session, 1, core.NewMoney(1000, core.DefaultCurrency), db)
+	if err != nil {
+		t.Errorf("unexpected error at TransferToByAccountId: %v", err)
+	}
+
+	accounts, err := core.GetListOfClientAccounts("petya", db)
+	if err != nil {
+		t.Errorf("unexpected error at GetListOfClientAccounts: %v", err)
+	}
+
+	if len(accounts) != 1 || accounts[0].Balance != core.NewMoney(51000, core.DefaultCurrency) {
+		t.Errorf("only the owner's debit must reach the account, found: %v", accounts)
+	}
+}
@@ -0,0 +1,211 @@
+package tests
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/JAbduvohidov/apm-ibank-core/pkg/core"
+	_ "github.com/mattn/go-sqlite3"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func clientIds(clients []core.Client) (ids []int64) {
+	for _, client := range clients {
+		ids = append(ids, client.Id)
+	}
+	return ids
+}
+
+func journalIds(journals []core.Journal) (ids []int64) {
+	for _, journal := range journals {
+		ids = append(ids, journal.Id)
+	}
+	return ids
+}
+
+func TestGetListOfClientsPage(t *testing.T) {
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Errorf("can't open db: %v", err)
+	}
+	defer func() {
+		if err := db.Close(); err != nil {
+			t.Errorf("can't close db: %v", err)
+		}
+	}()
+	db.SetMaxOpenConns(1)
+
+	core.SetPasswordHasher(core.NewBcryptHasher(4))
+	defer core.SetPasswordHasher(core.DefaultPasswordHasher)
+
+	err = core.Init(db)
+	if err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	for i := 1; i <= 7; i++ {
+		err = core.AddClient("Vasya", fmt.Sprintf("vasya%d", i), "1234", int64(1000+i), db)
+		if err != nil {
+			t.Errorf("unexpected error at AddClient: %v", err)
+		}
+	}
+
+	first, err := core.GetListOfClientsPage(core.PageRequest{Limit: 3, WithTotal: true}, db)
+	if err != nil {
+		t.Errorf("unexpected error at GetListOfClientsPage: %v", err)
+	}
+	if !reflect.DeepEqual(clientIds(first.Clients), []int64{1, 2, 3}) || first.PreviousCursor != "" || first.NextCursor == "" || first.Total != 7 {
+		t.Errorf("unexpected first page: %v", first)
+	}
+
+	err = core.AddClient("Petya", "petya", "1234", 2000, db)
+	if err != nil {
+		t.Errorf("unexpected error at AddClient: %v", err)
+	}
+
+	second, err := core.GetListOfClientsPage(core.PageRequest{Cursor: first.NextCursor, Limit: 3}, db)
+	if err != nil {
+		t.Errorf("unexpected error at GetListOfClientsPage: %v", err)
+	}
+	if !reflect.DeepEqual(clientIds(second.Clients), []int64{4, 5, 6}) || second.PreviousCursor == "" || second.NextCursor == "" || second.Total != 0 {
+		t.Errorf("unexpected second page: %v", second)
+	}
+
+	last, err := core.GetListOfClientsPage(core.PageRequest{Cursor: second.NextCursor, Limit: 3}, db)
+	if err != nil {
+		t.Errorf("unexpected error at GetListOfClientsPage: %v", err)
+	}
+	if !reflect.DeepEqual(clientIds(last.Clients), []int64{7, 8}) || last.NextCursor != "" {
+		t.Errorf("unexpected last page: %v", last)
+	}
+
+	previous, err := core.GetListOfClientsPage(core.PageRequest{Cursor: last.PreviousCursor, Limit: 3}, db)
+	if err != nil {
+		t.Errorf("unexpected error at GetListOfClientsPage: %v", err)
+	}
+	if !reflect.DeepEqual(clientIds(previous.Clients), []int64{4, 5, 6}) || previous.NextCursor == "" || previous.PreviousCursor == "" {
+		t.Errorf("unexpected previous page: %v", previous)
+	}
+
+	previous, err = core.GetListOfClientsPage(core.PageRequest{Cursor: previous.PreviousCursor, Limit: 3}, db)
+	if err != nil {
+		t.Errorf("unexpected error at GetListOfClientsPage: %v", err)
+	}
+	if !reflect.DeepEqual(clientIds(previous.Clients), []int64{1, 2, 3}) || previous.PreviousCursor != "" {
+		t.Errorf("unexpected previous page: %v", previous)
+	}
+
+	_, err = core.GetListOfClientsPage(core.PageRequest{Cursor: "garbage", Limit: 3}, db)
+	if ok := errors.Is(err, core.ErrInvalidCursor); !ok {
+		t.Errorf("expected error: %v, found: %v", core.ErrInvalidCursor, err)
+	}
+
+	_, err = core.GetListOfClientsPage(core.PageRequest{Limit: 0}, db)
+	if ok := errors.Is(err, core.ErrInvalidLimit); !ok {
+		t.Errorf("expected error: %v, found: %v", core.ErrInvalidLimit, err)
+	}
+}
+
+func TestGetJournalPage(t *testing.T) {
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Errorf("can't open db: %v", err)
+	}
+	defer func() {
+		if err := db.Close(); err != nil {
+			t.Errorf("can't close db: %v", err)
+		}
+	}()
+	db.SetMaxOpenConns(1)
+
+	day := time.Date(2020, 3, 1, 12, 0, 0, 0, time.UTC)
+	clock := &testClock{current: day}
+	core.SetClock(clock.Now)
+	defer core.SetClock(nil)
+
+	err = core.Init(db)
+	if err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	err = core.AddClient("Vasya", "vasya", "1234", 1234, db)
+	if err != nil {
+		t.Errorf("unexpected error at AddClient: %v", err)
+	}
+
+	err = core.AddAccount(1234, core.NewMoney(100000, core.DefaultCurrency), db)
+	if err != nil {
+		t.Errorf("unexpected error at AddAccount: %v", err)
+	}
+
+	err = core.AddService("Internet", db)
+	if err != nil {
+		t.Errorf("unexpected error at AddService: %v", err)
+	}
+
+	session, err := core.StartSession("vasya", "1234", "test", db)
+	if err != nil {
+		t.Errorf("unexpected error at StartSession: %v", err)
+	}
+
+	for _, minutes := range []int{0, 1, 1, 1, 2, 3, 4} {
+		clock.current = day.Add(time.Duration(minutes) * time.Minute)
+		err = core.PayForService("Internet", 1, session, core.NewMoney(100, core.DefaultCurrency), db)
+		if err != nil {
+			t.Errorf("unexpected error at PayForService: %v", err)
+		}
+	}
+
+	for _, query := range []core.JournalQuery{core.NewJournalQuery("vasya"), core.NewJournalQuery("vasya").Descending()} {
+		expected, err := core.GetJournalList(query, db)
+		if err != nil {
+			t.Errorf("unexpected error at GetJournalList: %v", err)
+		}
+
+		var forward []core.Journal
+		var pages []core.JournalPage
+		request := core.PageRequest{Limit: 2, WithTotal: true}
+		for {
+			page, err := core.GetJournalPage(query, request, db)
+			if err != nil {
+				t.Fatalf("unexpected error at GetJournalPage: %v", err)
+			}
+			if page.Total != 7 {
+				t.Errorf("expected total: 7, found: %d", page.Total)
+			}
+
+			pages = append(pages, page)
+			forward = append(forward, page.Journals...)
+			if page.NextCursor == "" {
+				break
+			}
+			request.Cursor = page.NextCursor
+		}
+
+		if !reflect.DeepEqual(journalIds(forward), journalIds(expected)) {
+			t.Errorf("expected: %v, found: %v", journalIds(expected), journalIds(forward))
+		}
+
+		for i := len(pages) - 1; i > 0; i-- {
+			previous, err := core.GetJournalPage(query, core.PageRequest{Cursor: pages[i].PreviousCursor, Limit: 2}, db)
+			if err != nil {
+				t.Fatalf("unexpected error at GetJournalPage: %v", err)
+			}
+			if !reflect.DeepEqual(journalIds(previous.Journals), journalIds(pages[i-1].Journals)) {
+				t.Errorf("expected: %v, found: %v", journalIds(pages[i-1].Journals), journalIds(previous.Journals))
+			}
+		}
+	}
+
+	page, err := core.GetJournalPage(core.NewJournalQuery("vasya"), core.PageRequest{Limit: 2}, db)
+	if err != nil {
+		t.Errorf("unexpected error at GetJournalPage: %v", err)
+	}
+
+	_, err = core.GetListOfClientsPage(core.PageRequest{Cursor: page.NextCursor, Limit: 2}, db)
+	if ok := errors.Is(err, core.ErrInvalidCursor); !ok {
+		t.Errorf("expected error: %v, found: %v", core.ErrInvalidCursor, err)
+	}
+}
@@ -0,0 +1,320 @@
+package tests
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"github.com/JAbduvohidov/apm-ibank-core/pkg/core"
+	_ "github.com/mattn/go-sqlite3"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAddClientHashesPassword(t *testing.T) {
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Errorf("can't open db: %v", err)
+	}
+	defer func() {
+		if err := db.Close(); err != nil {
+			t.Errorf("can't close db: %v", err)
+		}
+	}()
+
+	err = core.Init(db)
+	if err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	err = core.AddClient("Vasya", "vasya", "1234", 999999999, db)
+	if err != nil {
+		t.Errorf("unexpected error at AddClient: %v", err)
+	}
+
+	clients, err := core.GetListOfClients(db)
+	if err != nil {
+		t.Errorf("unexpected error at GetListOfClients: %v", err)
+	}
+
+	if len(clients) != 1 {
+		t.Fatalf("expected 1 client, found: %v", len(clients))
+	}
+
+	if clients[0].Password == "1234" || !strings.HasPrefix(clients[0].Password, "{"+core.BcryptScheme+"}") {
+		t.Errorf("password must be stored as bcrypt hash, found: %v", clients[0].Password)
+	}
+
+	_, err = core.Login("vasya", "1234", db)
+	if err != nil {
+		t.Errorf("unexpected error at Login: %v", err)
+	}
+}
+
+func TestLoginRehashesLegacyPassword(t *testing.T) {
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Errorf("can't open db: %v", err)
+	}
+	defer func() {
+		if err := db.Close(); err != nil {
+			t.Errorf("can't close db: %v", err)
+		}
+	}()
+
+	err = core.Init(db)
+	if err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	_, err = db.Exec(`INSERT INTO clients(name, login, password, phone_number, status)
+VALUES ('Vasya', 'vasya', '1234', 999999999, 'active');`)
+	if err != nil {
+		t.Errorf("can't insert legacy client: %v", err)
+	}
+
+	_, err = core.Login("vasya", "4321", db)
+	if ok := errors.Is(err, core.ErrInvalidPass); !ok {
+		t.Errorf("expected error: %v, found: %v", core.ErrInvalidPass, err)
+	}
+
+	_, err = core.Login("vasya", "1234", db)
+	if err != nil {
+		t.Errorf("unexpected error at Login: %v", err)
+	}
+
+	var password string
+	err = db.QueryRow(`SELECT password FROM clients WHERE login = 'vasya';`).Scan(&password)
+	if err != nil {
+		t.Errorf("can't read password: %v", err)
+	}
+
+	if !strings.HasPrefix(password, "{"+core.BcryptScheme+"}") {
+		t.Errorf("legacy password must be rehashed after login, found: %v", password)
+	}
+
+	_, err = core.Login("vasya", "1234", db)
+	if err != nil {
+		t.Errorf("unexpected error at Login after rehash: %v", err)
+	}
+}
+
+func TestLoginRehashesBracedLegacyPassword(t *testing.T) {
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Errorf("can't open db: %v", err)
+	}
+	defer func() {
+		if err := db.Close(); err != nil {
+			t.Errorf("can't close db: %v", err)
+		}
+	}()
+
+	err = core.Init(db)
+	if err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	_, err = db.Exec(`INSERT INTO clients(name, login, password, phone_number, status)
+VALUES ('Vasya', 'vasya', '{abc}123', 999999999, 'active');`)
+	if err != nil {
+		t.Errorf("can't insert legacy client: %v", err)
+	}
+
+	_, err = core.Login("vasya", "123", db)
+	if ok := errors.Is(err, core.ErrInvalidPass); !ok {
+		t.Errorf("expected error: %v, found: %v", core.ErrInvalidPass, err)
+	}
+
+	_, err = core.Login("vasya", "{abc}123", db)
+	if err != nil {
+		t.Errorf("unexpected error at Login: %v", err)
+	}
+
+	var password string
+	err = db.QueryRow(`SELECT password FROM clients WHERE login = 'vasya';`).Scan(&password)
+	if err != nil {
+		t.Errorf("can't read password: %v", err)
+	}
+
+	if !strings.HasPrefix(password, "{"+core.BcryptScheme+"}") {
+		t.Errorf("legacy password must be rehashed after login, found: %v", password)
+	}
+
+	_, err = core.Login("vasya", "{abc}123", db)
+	if err != nil {
+		t.Errorf("unexpected error at Login after rehash: %v", err)
+	}
+}
+
+func TestImportListOfClientsHashesPasswords(t *testing.T) {
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Errorf("can't open db: %v", err)
+	}
+	defer func() {
+		if err := db.Close(); err != nil {
+			t.Errorf("can't close db: %v", err)
+		}
+	}()
+
+	err = core.Init(db)
+	if err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	err = core.ImportListOfClients([]core.Client{
+		{Id: 1, Name: "Vasya", Login: "vasya", Password: "1234", PhoneNumber: 999999999, Status: core.Active},
+	}, db)
+	if err != nil {
+		t.Errorf("unexpected error at ImportListOfClients: %v", err)
+	}
+
+	exported, err := core.GetListOfClients(db)
+	if err != nil {
+		t.Errorf("unexpected error at GetListOfClients: %v", err)
+	}
+
+	if len(exported) != 1 || exported[0].Password == "1234" {
+		t.Fatalf("imported plaintext password must be hashed, found: %v", exported)
+	}
+
+	err = core.ImportListOfClients(exported, db)
+	if err != nil {
+		t.Errorf("unexpected error at ImportListOfClients: %v", err)
+	}
+
+	_, err = core.Login("vasya", "1234", db)
+	if err != nil {
+		t.Errorf("re-imported hash must stay valid, found: %v", err)
+	}
+}
+
+func TestImportListOfClientsHashesUnknownSchemes(t *testing.T) {
+	bank := core.NewBank(core.NewMemoryStore(), core.WithPasswordHasher(core.NewBcryptHasher(4)))
+	ctx := context.Background()
+
+	err := bank.ImportListOfClients(ctx, []core.Client{
+		{Id: 1, Name: "Vasya", Login: "vasya", Password: "{x}1234", PhoneNumber: 999999999, Status: core.Active},
+	})
+	if err != nil {
+		t.Errorf("unexpected error at ImportListOfClients: %v", err)
+	}
+
+	_, err = bank.Login(ctx, "vasya", "{x}1234")
+	if err != nil {
+		t.Errorf("password with an unknown scheme must be imported as plaintext, found: %v", err)
+	}
+}
+
+// sha256Hasher stands for a hasher replacing bcrypt, it isn't fit for
+// passwords.
+type sha256Hasher struct{}
+
+func (receiver sha256Hasher) Scheme() string {
+	return "sha256"
+}
+
+func (receiver sha256Hasher) Hash(password string) (hash string, err error) {
+	sum := sha256.Sum256([]byte(password))
+	return "{sha256}" + hex.EncodeToString(sum[:]), nil
+}
+
+func (receiver sha256Hasher) Verify(hash, password string) (ok bool, err error) {
+	expected, err := receiver.Hash(password)
+	return hash == expected, err
+}
+
+func (receiver sha256Hasher) NeedsRehash(hash string) bool {
+	return false
+}
+
+func TestLoginRehashesWithAnotherHasher(t *testing.T) {
+	store := core.NewMemoryStore()
+	ctx := context.Background()
+	bcryptBank := core.NewBank(store, core.WithPasswordHasher(core.NewBcryptHasher(4)))
+	shaBank := core.NewBank(store, core.WithPasswordHasher(sha256Hasher{}))
+
+	err := bcryptBank.AddClient(ctx, "Vasya", "vasya", "1234", 999999999)
+	if err != nil {
+		t.Errorf("unexpected error at AddClient: %v", err)
+	}
+
+	for i := 0; i < 2; i++ {
+		_, err = shaBank.Authenticate(ctx, "vasya", "1234")
+		if err != nil {
+			t.Errorf("unexpected error at Authenticate: %v", err)
+		}
+	}
+
+	clients, err := shaBank.GetListOfClients(ctx)
+	if err != nil {
+		t.Errorf("unexpected error at GetListOfClients: %v", err)
+	}
+	if len(clients) != 1 || !strings.HasPrefix(clients[0].Password, "{sha256}") {
+		t.Fatalf("password must be rehashed with the current hasher, found: %v", clients)
+	}
+
+	// Without a verifier for its scheme the hash is taken for plaintext.
+	_, err = bcryptBank.Authenticate(ctx, "vasya", "1234")
+	if ok := errors.Is(err, core.ErrInvalidCredentials); !ok {
+		t.Errorf("expected error: %v, found: %v", core.ErrInvalidCredentials, err)
+	}
+
+	bcryptBank = core.NewBank(store, core.WithPasswordHasher(core.NewBcryptHasher(4)), core.WithPasswordVerifier(sha256Hasher{}))
+	_, err = bcryptBank.Authenticate(ctx, "vasya", "4321")
+	if ok := errors.Is(err, core.ErrInvalidCredentials); !ok {
+		t.Errorf("expected error: %v, found: %v", core.ErrInvalidCredentials, err)
+	}
+
+	_, err = bcryptBank.Authenticate(ctx, "vasya", "1234")
+	if err != nil {
+		t.Errorf("unexpected error at Authenticate: %v", err)
+	}
+
+	clients, err = bcryptBank.GetListOfClients(ctx)
+	if err != nil {
+		t.Errorf("unexpected error at GetListOfClients: %v", err)
+	}
+	if len(clients) != 1 || !strings.HasPrefix(clients[0].Password, "{"+core.BcryptScheme+"}") {
+		t.Errorf("password must be rehashed back to bcrypt, found: %v", clients)
+	}
+}
+
+var errBrokenHasher = errors.New("broken hasher")
+
+// brokenHasher fails to verify any hash it made.
+type brokenHasher struct {
+	sha256Hasher
+}
+
+func (receiver brokenHasher) Verify(hash, password string) (ok bool, err error) {
+	return false, errBrokenHasher
+}
+
+func TestLoginErrorDoesNotCountAsFailedAttempt(t *testing.T) {
+	store := core.NewMemoryStore()
+	ctx := context.Background()
+	policy := core.LockoutPolicy{MaxFailedAttempts: 2, Window: time.Hour}
+	brokenBank := core.NewBank(store, core.WithPasswordHasher(brokenHasher{}), core.WithLockoutPolicy(policy))
+	shaBank := core.NewBank(store, core.WithPasswordHasher(sha256Hasher{}), core.WithLockoutPolicy(policy))
+
+	err := brokenBank.AddClient(ctx, "Vasya", "vasya", "1234", 999999999)
+	if err != nil {
+		t.Errorf("unexpected error at AddClient: %v", err)
+	}
+
+	for i := 0; i < 3; i++ {
+		_, err = brokenBank.Authenticate(ctx, "vasya", "1234")
+		if ok := errors.Is(err, errBrokenHasher); !ok {
+			t.Errorf("expected error: %v, found: %v", errBrokenHasher, err)
+		}
+	}
+
+	_, err = shaBank.Authenticate(ctx, "vasya", "1234")
+	if err != nil {
+		t.Errorf("unexpected error at Authenticate: %v", err)
+	}
+}
@@ -0,0 +1,52 @@
+package tests
+
+import (
+	"database/sql"
+	"github.com/JAbduvohidov/apm-ibank-core/pkg/core"
+	"reflect"
+	"testing"
+)
+
+func TestBindPostgres(t *testing.T) {
+	cases := []struct {
+		query        string
+		args         []interface{}
+		expected     string
+		expectedArgs []interface{}
+	}{
+		{"SELECT 1;", nil, "SELECT 1;", nil},
+		{"SELECT ?, ?;", []interface{}{1, 2}, "SELECT $1, $2;", []interface{}{1, 2}},
+		{
+			"SELECT :a, :b, :a;",
+			[]interface{}{sql.Named("b", 2), sql.Named("a", 1)},
+			"SELECT $1, $2, $1;",
+			[]interface{}{1, 2},
+		},
+		{
+			"SELECT :a, ?, :b, ?;",
+			[]interface{}{sql.Named("a", 1), 2, sql.Named("b", 3), 4},
+			"SELECT $1, $2, $3, $4;",
+			[]interface{}{1, 2, 3, 4},
+		},
+		{
+			"SELECT ?, :a, ?, :a;",
+			[]interface{}{2, 4, sql.Named("a", 3)},
+			"SELECT $1, $2, $3, $2;",
+			[]interface{}{2, 3, 4},
+		},
+		{"SELECT ?, :a;", nil, "SELECT $1, $2;", []interface{}{nil, nil}},
+		{
+			"SELECT '?', ':a', x::TEXT, ? FROM t;",
+			[]interface{}{1},
+			"SELECT '?', ':a', x::TEXT, $1 FROM t;",
+			[]interface{}{1},
+		},
+	}
+
+	for _, c := range cases {
+		query, args := core.BindPostgres(c.query, c.args)
+		if query != c.expected || !reflect.DeepEqual(args, c.expectedArgs) {
+			t.Errorf("%s: expected: %s %v, found: %s %v", c.query, c.expected, c.expectedArgs, query, args)
+		}
+	}
+}
@@ -0,0 +1,130 @@
+package tests
+
+import (
+	"database/sql"
+	"errors"
+	"github.com/JAbduvohidov/apm-ibank-core/pkg/core"
+	_ "github.com/mattn/go-sqlite3"
+	"testing"
+)
+
+func TestTransferPreconditions(t *testing.T) {
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Errorf("can't open db: %v", err)
+	}
+	defer func() {
+		if err := db.Close(); err != nil {
+			t.Errorf("can't close db: %v", err)
+		}
+	}()
+
+	err = core.Init(db)
+	if err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	err = core.AddService("Water", db)
+	if err != nil {
+		t.Errorf("unexpected error at AddService: %v", err)
+	}
+
+	err = core.AddClient("Vasya", "vasya", "1234", 1234, db)
+	if err != nil {
+		t.Errorf("unexpected error at AddClient: %v", err)
+	}
+
+	err = core.AddClient("Petya", "petya", "1234", 5678, db)
+	if err != nil {
+		t.Errorf("unexpected error at AddClient: %v", err)
+	}
+
+	err = core.AddClient("Kolya", "kolya", "1234", 9012, db)
+	if err != nil {
+		t.Errorf("unexpected error at AddClient: %v", err)
+	}
+
+	err = core.AddAccount(1234, core.NewMoney(10000, core.DefaultCurrency), db)
+	if err != nil {
+		t.Errorf("unexpected error at AddAccount: %v", err)
+	}
+
+	err = core.AddAccount(5678, core.NewMoney(0, core.DefaultCurrency), db)
+	if err != nil {
+		t.Errorf("unexpected error at AddAccount: %v", err)
+	}
+
+	session, err := core.StartSession("vasya", "1234", "test", db)
+	if err != nil {
+		t.Errorf("unexpected error at StartSession: %v", err)
+	}
+
+	cases := []struct {
+		name     string
+		transfer func() error
+		expected error
+	}{
+		{"missing target account", func() error {
+			return core.TransferToByAccountId(42, session, 1, core.NewMoney(1000, core.DefaultCurrency), db)
+		}, core.ErrTargetAccountNotExist},
+		{"missing source account", func() error {
+			return core.TransferToByAccountId(2, session, 42, core.NewMoney(1000, core.DefaultCurrency), db)
+		}, core.ErrAccountNotExist},
+		{"insufficient funds", func() error {
+			return core.TransferToByAccountId(2, session, 1, core.NewMoney(100000, core.DefaultCurrency), db)
+		}, core.ErrInsufficientFunds},
+		{"non positive amount", func() error {
+			return core.TransferToByAccountId(2, session, 1, core.NewMoney(0, core.DefaultCurrency), db)
+		}, core.ErrInvalidAmount},
+		{"unknown phone number", func() error {
+			return core.TransferToByPhoneNumber(4321, session, 1, core.NewMoney(1000, core.DefaultCurrency), db)
+		}, core.ErrPhoneNumberNotExist},
+		{"recipient without account", func() error {
+			return core.TransferToByPhoneNumber(9012, session, 1, core.NewMoney(1000, core.DefaultCurrency), db)
+		}, core.ErrRecipientHasNoAccount},
+		{"unknown service", func() error {
+			return core.PayForService("Gas", 1, session, core.NewMoney(1000, core.DefaultCurrency), db)
+		}, core.ErrServiceNotExist},
+	}
+
+	for _, c := range cases {
+		err = c.transfer()
+		if ok := errors.Is(err, c.expected); !ok {
+			t.Errorf("%s: expected error: %v, found: %v", c.name, c.expected, err)
+		}
+	}
+
+	err = core.ChangeClientStatus(5678, core.Locked, db)
+	if err != nil {
+		t.Errorf("unexpected error at ChangeClientStatus: %v", err)
+	}
+
+	err = core.TransferToByAccountId(2, session, 1, core.NewMoney(1000, core.DefaultCurrency), db)
+	if ok := errors.Is(err, core.ErrRecipientIsLocked); !ok {
+		t.Errorf("expected error: %v, found: %v", core.ErrRecipientIsLocked, err)
+	}
+
+	err = core.TransferToByPhoneNumber(5678, session, 1, core.NewMoney(1000, core.DefaultCurrency), db)
+	if ok := errors.Is(err, core.ErrClientIsLocked); !ok {
+		t.Errorf("expected error: %v, found: %v", core.ErrClientIsLocked, err)
+	}
+
+	err = core.ChangeClientStatus(1234, core.Locked, db)
+	if err != nil {
+		t.Errorf("unexpected error at ChangeClientStatus: %v", err)
+	}
+
+	err = core.PayForService("Water", 1, session, core.NewMoney(1000, core.DefaultCurrency), db)
+	if ok := errors.Is(err, core.ErrSenderIsLocked); !ok {
+		t.Errorf("expected error: %v, found: %v", core.ErrSenderIsLocked, err)
+	}
+
+	accounts, err := core.GetListOfAccountsWithClients(db)
+	if err != nil {
+		t.Errorf("unexpected error at GetListOfAccountsWithClients: %v", err)
+	}
+
+	if len(accounts) != 2 || accounts[0].Balance != core.NewMoney(10000, core.DefaultCurrency) || accounts[1].Balance != core.NewMoney(0, core.DefaultCurrency) {
+		t.Errorf("failed transfers must not move money, found: %v", accounts)
+	}
+}
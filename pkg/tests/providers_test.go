@@ -0,0 +1,184 @@
+package tests
+
+import (
+	"context"
+	"errors"
+	"github.com/JAbduvohidov/apm-ibank-core/pkg/core"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestServiceProviders(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store core.Store) {
+		date := time.Date(2020, time.March, 1, 12, 0, 0, 0, time.UTC)
+		start := date
+		bank := core.NewBank(
+			store,
+			core.WithClock(func() time.Time { return date }),
+			core.WithPasswordHasher(core.NewBcryptHasher(4)),
+			core.WithSessionTTL(24*time.Hour),
+		)
+		ctx := context.Background()
+
+		err := bank.AddClient(ctx, "Vasya", "vasya", "1234", 1234)
+		if err != nil {
+			t.Errorf("unexpected error at AddClient: %v", err)
+		}
+
+		for _, balance := range []core.Money{core.NewMoney(100000, core.DefaultCurrency), core.NewMoney(10000, "USD")} {
+			err = bank.AddAccount(ctx, 1234, balance)
+			if err != nil {
+				t.Errorf("unexpected error at AddAccount: %v", err)
+			}
+		}
+
+		for _, service := range []string{"Internet", "Water"} {
+			err = bank.AddService(ctx, service)
+			if err != nil {
+				t.Errorf("unexpected error at AddService: %v", err)
+			}
+		}
+
+		err = bank.AddProvider(ctx, "Tcell", core.DefaultCurrency)
+		if err != nil {
+			t.Errorf("unexpected error at AddProvider: %v", err)
+		}
+
+		err = bank.AddProvider(ctx, "Tcell", core.DefaultCurrency)
+		if ok := errors.Is(err, core.ErrProviderExist); !ok {
+			t.Errorf("expected error: %v, found: %v", core.ErrProviderExist, err)
+		}
+
+		err = bank.AddProvider(ctx, "Megafon", "XXX")
+		if ok := errors.Is(err, core.ErrUnsupportedCurrency); !ok {
+			t.Errorf("expected error: %v, found: %v", core.ErrUnsupportedCurrency, err)
+		}
+
+		err = bank.SetServiceProvider(ctx, "Internet", "Tcell")
+		if err != nil {
+			t.Errorf("unexpected error at SetServiceProvider: %v", err)
+		}
+
+		err = bank.SetServiceProvider(ctx, "Gas", "Tcell")
+		if ok := errors.Is(err, core.ErrServiceNotExist); !ok {
+			t.Errorf("expected error: %v, found: %v", core.ErrServiceNotExist, err)
+		}
+
+		err = bank.SetServiceProvider(ctx, "Water", "Megafon")
+		if ok := errors.Is(err, core.ErrProviderNotExist); !ok {
+			t.Errorf("expected error: %v, found: %v", core.ErrProviderNotExist, err)
+		}
+
+		session, err := bank.StartSession(ctx, "vasya", "1234", "test")
+		if err != nil {
+			t.Errorf("unexpected error at StartSession: %v", err)
+		}
+
+		// Journal entries 1, 2 and 3, only Internet has a provider.
+		for _, payment := range []struct {
+			service string
+			amount  int64
+		}{
+			{"Internet", 1000},
+			{"Internet", 1000},
+			{"Water", 500},
+		} {
+			err = bank.PayForService(ctx, payment.service, 1, session, core.NewMoney(payment.amount, core.DefaultCurrency))
+			if err != nil {
+				t.Errorf("unexpected error at PayForService: %v", err)
+			}
+		}
+
+		err = bank.PayForService(ctx, "Internet", 2, session, core.NewMoney(100, "USD"))
+		if ok := errors.Is(err, core.ErrCurrencyMismatch); !ok {
+			t.Errorf("expected error: %v, found: %v", core.ErrCurrencyMismatch, err)
+		}
+
+		_, err = bank.ReverseTransaction(ctx, 2, "double payment")
+		if err != nil {
+			t.Errorf("unexpected error at ReverseTransaction: %v", err)
+		}
+
+		providers, err := bank.GetListOfProviders(ctx)
+		if err != nil {
+			t.Errorf("unexpected error at GetListOfProviders: %v", err)
+		}
+		expected := []core.Provider{{Id: 1, Name: "Tcell", Balance: core.NewMoney(1000, core.DefaultCurrency)}}
+		if !reflect.DeepEqual(providers, expected) {
+			t.Errorf("expected: %v, found: %v", expected, providers)
+		}
+
+		date = date.Add(time.Hour)
+		for _, paid := range []int64{1000, 0} {
+			payout, err := bank.PayOutProvider(ctx, "Tcell")
+			if err != nil {
+				t.Errorf("unexpected error at PayOutProvider: %v", err)
+			}
+			if payout != core.NewMoney(paid, core.DefaultCurrency) {
+				t.Errorf("expected payout: %v, found: %v", core.NewMoney(paid, core.DefaultCurrency), payout)
+			}
+		}
+
+		_, err = bank.PayOutProvider(ctx, "Megafon")
+		if ok := errors.Is(err, core.ErrProviderNotExist); !ok {
+			t.Errorf("expected error: %v, found: %v", core.ErrProviderNotExist, err)
+		}
+
+		_, err = bank.ReverseTransaction(ctx, 1, "paid out")
+		if ok := errors.Is(err, core.ErrRecipientInsufficientFunds); !ok {
+			t.Errorf("expected error: %v, found: %v", core.ErrRecipientInsufficientFunds, err)
+		}
+
+		err = bank.PayForService(ctx, "Internet", 1, session, core.NewMoney(3000, core.DefaultCurrency))
+		if err != nil {
+			t.Errorf("unexpected error at PayForService: %v", err)
+		}
+
+		provider := core.Provider{Id: 1, Name: "Tcell", Balance: core.NewMoney(3000, core.DefaultCurrency)}
+		for _, report := range []struct {
+			to       time.Time
+			expected core.ProviderSettlement
+		}{
+			{start.Add(time.Hour), core.ProviderSettlement{
+				Provider:  provider,
+				Payments:  2,
+				Collected: core.NewMoney(2000, core.DefaultCurrency),
+				Refunds:   1,
+				Refunded:  core.NewMoney(1000, core.DefaultCurrency),
+				PaidOut:   core.NewMoney(0, core.DefaultCurrency),
+			}},
+			{start.Add(2 * time.Hour), core.ProviderSettlement{
+				Provider:  provider,
+				Payments:  3,
+				Collected: core.NewMoney(5000, core.DefaultCurrency),
+				Refunds:   1,
+				Refunded:  core.NewMoney(1000, core.DefaultCurrency),
+				Payouts:   1,
+				PaidOut:   core.NewMoney(1000, core.DefaultCurrency),
+			}},
+		} {
+			settlements, err := bank.GetSettlementReport(ctx, start, report.to)
+			if err != nil {
+				t.Errorf("unexpected error at GetSettlementReport: %v", err)
+			}
+			expected := []core.ProviderSettlement{report.expected}
+			if !reflect.DeepEqual(settlements, expected) {
+				t.Errorf("expected: %v, found: %v", expected, settlements)
+			}
+		}
+
+		balance, err := bank.GetLedgerBalance(ctx, 1)
+		if err != nil {
+			t.Errorf("unexpected error at GetLedgerBalance: %v", err)
+		}
+		if balance != core.NewMoney(95500, core.DefaultCurrency) {
+			t.Errorf("expected: %v, found: %v", core.NewMoney(95500, core.DefaultCurrency), balance)
+		}
+
+		err = bank.CheckLedger(ctx)
+		if err != nil {
+			t.Errorf("unexpected error at CheckLedger: %v", err)
+		}
+	})
+}
@@ -0,0 +1,172 @@
+package tests
+
+import (
+	"context"
+	"errors"
+	"github.com/JAbduvohidov/apm-ibank-core/pkg/core"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestReverseTransaction(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store core.Store) {
+		date := time.Date(2020, time.March, 1, 12, 0, 0, 0, time.UTC)
+		bank := core.NewBank(
+			store,
+			core.WithClock(func() time.Time { return date }),
+			core.WithPasswordHasher(core.NewBcryptHasher(4)),
+		)
+		ctx := context.Background()
+
+		for _, client := range []struct {
+			name, login string
+			phoneNumber int64
+		}{
+			{"Vasya", "vasya", 1234},
+			{"Petya", "petya", 4321},
+		} {
+			err := bank.AddClient(ctx, client.name, client.login, "1234", client.phoneNumber)
+			if err != nil {
+				t.Errorf("unexpected error at AddClient: %v", err)
+			}
+		}
+
+		for _, account := range []struct {
+			phoneNumber int64
+			balance     core.Money
+		}{
+			{1234, core.NewMoney(100000, core.DefaultCurrency)},
+			{1234, core.NewMoney(0, "USD")},
+			{4321, core.NewMoney(0, core.DefaultCurrency)},
+		} {
+			err := bank.AddAccount(ctx, account.phoneNumber, account.balance)
+			if err != nil {
+				t.Errorf("unexpected error at AddAccount: %v", err)
+			}
+		}
+
+		err := bank.AddService(ctx, "Internet")
+		if err != nil {
+			t.Errorf("unexpected error at AddService: %v", err)
+		}
+
+		err = bank.LoadExchangeRates(ctx, []core.ExchangeRate{
+			{Base: "USD", Quote: core.DefaultCurrency, Buy: mustParseRate(t, "10"), Sell: mustParseRate(t, "10")},
+		})
+		if err != nil {
+			t.Errorf("unexpected error at LoadExchangeRates: %v", err)
+		}
+
+		vasya, err := bank.StartSession(ctx, "vasya", "1234", "test")
+		if err != nil {
+			t.Errorf("unexpected error at StartSession: %v", err)
+		}
+
+		petya, err := bank.StartSession(ctx, "petya", "1234", "test")
+		if err != nil {
+			t.Errorf("unexpected error at StartSession: %v", err)
+		}
+
+		// Journal entries 1, 2 and 3, 4 and 5.
+		err = bank.PayForService(ctx, "Internet", 1, vasya, core.NewMoney(1000, core.DefaultCurrency))
+		if err != nil {
+			t.Errorf("unexpected error at PayForService: %v", err)
+		}
+
+		err = bank.TransferToByPhoneNumber(ctx, 4321, vasya, 1, core.NewMoney(5000, core.DefaultCurrency))
+		if err != nil {
+			t.Errorf("unexpected error at TransferToByPhoneNumber: %v", err)
+		}
+
+		_, err = bank.ConvertBetweenOwnAccounts(ctx, vasya, 1, 2, core.NewMoney(10000, core.DefaultCurrency))
+		if err != nil {
+			t.Errorf("unexpected error at ConvertBetweenOwnAccounts: %v", err)
+		}
+
+		reversal, err := bank.ReverseTransaction(ctx, 1, "provider failed")
+		if err != nil {
+			t.Errorf("unexpected error at ReverseTransaction: %v", err)
+		}
+		expected := core.JournalReversal{JournalId: 1, ReversalJournalId: 6, Reason: "provider failed", CreatedAt: date}
+		if reversal != expected {
+			t.Errorf("expected: %v, found: %v", expected, reversal)
+		}
+
+		_, err = bank.ReverseTransaction(ctx, 1, "again")
+		if ok := errors.Is(err, core.ErrAlreadyReversed); !ok {
+			t.Errorf("expected error: %v, found: %v", core.ErrAlreadyReversed, err)
+		}
+
+		for _, journalId := range []int64{3, 6} {
+			_, err = bank.ReverseTransaction(ctx, journalId, "incoming")
+			if ok := errors.Is(err, core.ErrNotReversible); !ok {
+				t.Errorf("expected error: %v, found: %v", core.ErrNotReversible, err)
+			}
+		}
+
+		_, err = bank.ReverseTransaction(ctx, 100, "unknown")
+		if ok := errors.Is(err, core.ErrJournalNotFound); !ok {
+			t.Errorf("expected error: %v, found: %v", core.ErrJournalNotFound, err)
+		}
+
+		// Journal entry 7 leaves Petya 1000 of the 5000 received.
+		err = bank.PayForService(ctx, "Internet", 3, petya, core.NewMoney(4000, core.DefaultCurrency))
+		if err != nil {
+			t.Errorf("unexpected error at PayForService: %v", err)
+		}
+
+		_, err = bank.ReverseTransaction(ctx, 2, "refund")
+		if ok := errors.Is(err, core.ErrRecipientInsufficientFunds); !ok {
+			t.Errorf("expected error: %v, found: %v", core.ErrRecipientInsufficientFunds, err)
+		}
+
+		reversal, err = bank.ReverseTransaction(ctx, 4, "wrong account")
+		if err != nil {
+			t.Errorf("unexpected error at ReverseTransaction: %v", err)
+		}
+		if reversal.ReversalJournalId != 8 {
+			t.Errorf("expected reversal journal entry: 8, found: %v", reversal)
+		}
+
+		accounts, err := bank.GetListOfAccountsWithClients(ctx)
+		if err != nil {
+			t.Errorf("unexpected error at GetListOfAccountsWithClients: %v", err)
+		}
+		expectedAccounts := []core.AccountWithClientId{
+			{Id: 1, ClientId: 1, Balance: core.NewMoney(95000, core.DefaultCurrency)},
+			{Id: 2, ClientId: 1, Balance: core.NewMoney(0, "USD")},
+			{Id: 3, ClientId: 2, Balance: core.NewMoney(1000, core.DefaultCurrency)},
+		}
+		if !reflect.DeepEqual(accounts, expectedAccounts) {
+			t.Errorf("expected: %v, found: %v", expectedAccounts, accounts)
+		}
+
+		journals, err := bank.GetJournalList(ctx, core.NewJournalQuery("vasya"))
+		if err != nil {
+			t.Errorf("unexpected error at GetJournalList: %v", err)
+		}
+		var reversals []core.Journal
+		for _, journal := range journals {
+			if journal.Type == core.Reversal {
+				reversals = append(reversals, journal)
+			}
+		}
+		expectedReversals := []core.Journal{
+			{Id: 6, Date: date, Type: core.Reversal, Direction: core.Incoming, AccountId: 1, Counterparty: "Internet",
+				TransferredTo: "Internet", Amount: core.NewMoney(1000, core.DefaultCurrency)},
+			{Id: 8, Date: date, Type: core.Reversal, Direction: core.Outgoing, AccountId: 2, Counterparty: "1234",
+				CounterpartyAccountId: 1, TransferredTo: "1234", Amount: core.NewMoney(1000, "USD"), Rate: mustParseRate(t, "10")},
+			{Id: 9, Date: date, Type: core.Reversal, Direction: core.Incoming, AccountId: 1, Counterparty: "1234",
+				CounterpartyAccountId: 2, TransferredTo: "1234", Amount: core.NewMoney(10000, core.DefaultCurrency), Rate: mustParseRate(t, "10")},
+		}
+		if !reflect.DeepEqual(reversals, expectedReversals) {
+			t.Errorf("expected: %v, found: %v", expectedReversals, reversals)
+		}
+
+		err = bank.CheckLedger(ctx)
+		if err != nil {
+			t.Errorf("unexpected error at CheckLedger: %v", err)
+		}
+	})
+}
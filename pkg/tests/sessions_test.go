@@ -0,0 +1,169 @@
+package tests
+
+import (
+	"database/sql"
+	"errors"
+	"github.com/JAbduvohidov/apm-ibank-core/pkg/core"
+	_ "github.com/mattn/go-sqlite3"
+	"testing"
+	"time"
+)
+
+func TestStartSession(t *testing.T) {
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Errorf("can't open db: %v", err)
+	}
+	defer func() {
+		if err := db.Close(); err != nil {
+			t.Errorf("can't close db: %v", err)
+		}
+	}()
+
+	err = core.Init(db)
+	if err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	err = core.AddClient("Vasya", "vasya", "1234", 999999999, db)
+	if err != nil {
+		t.Errorf("unexpected error at AddClient: %v", err)
+	}
+
+	_, err = core.StartSession("vasya", "4321", "phone", db)
+	if ok := errors.Is(err, core.ErrInvalidCredentials); !ok {
+		t.Errorf("expected error: %v, found: %v", core.ErrInvalidCredentials, err)
+	}
+
+	session, err := core.StartSession("vasya", "1234", "phone", db)
+	if err != nil {
+		t.Errorf("unexpected error at StartSession: %v", err)
+	}
+
+	if session.Token == "" || session.Device != "phone" {
+		t.Errorf("unexpected session: %v", session)
+	}
+
+	validated, err := core.ValidateSession(session.Token, db)
+	if err != nil {
+		t.Errorf("unexpected error at ValidateSession: %v", err)
+	}
+
+	if validated.ClientId != session.ClientId {
+		t.Errorf("expected client id: %d, found: %d", session.ClientId, validated.ClientId)
+	}
+
+	_, err = core.ValidateSession("unknown", db)
+	if ok := errors.Is(err, core.ErrSessionNotFound); !ok {
+		t.Errorf("expected error: %v, found: %v", core.ErrSessionNotFound, err)
+	}
+
+	err = core.Logout(session.Token, db)
+	if err != nil {
+		t.Errorf("unexpected error at Logout: %v", err)
+	}
+
+	_, err = core.ValidateSession(session.Token, db)
+	if ok := errors.Is(err, core.ErrSessionRevoked); !ok {
+		t.Errorf("expected error: %v, found: %v", core.ErrSessionRevoked, err)
+	}
+}
+
+func TestRevokeAllSessions(t *testing.T) {
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Errorf("can't open db: %v", err)
+	}
+	defer func() {
+		if err := db.Close(); err != nil {
+			t.Errorf("can't close db: %v", err)
+		}
+	}()
+
+	err = core.Init(db)
+	if err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	err = core.AddClient("Vasya", "vasya", "1234", 999999999, db)
+	if err != nil {
+		t.Errorf("unexpected error at AddClient: %v", err)
+	}
+
+	phone, err := core.StartSession("vasya", "1234", "phone", db)
+	if err != nil {
+		t.Errorf("unexpected error at StartSession: %v", err)
+	}
+
+	laptop, err := core.StartSession("vasya", "1234", "laptop", db)
+	if err != nil {
+		t.Errorf("unexpected error at StartSession: %v", err)
+	}
+
+	err = core.RevokeAllSessions("vasya", db)
+	if err != nil {
+		t.Errorf("unexpected error at RevokeAllSessions: %v", err)
+	}
+
+	for _, session := range []core.Session{phone, laptop} {
+		_, err = core.ValidateSession(session.Token, db)
+		if ok := errors.Is(err, core.ErrSessionRevoked); !ok {
+			t.Errorf("expected error: %v, found: %v", core.ErrSessionRevoked, err)
+		}
+	}
+}
+
+func TestSessionExpires(t *testing.T) {
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Errorf("can't open db: %v", err)
+	}
+	defer func() {
+		if err := db.Close(); err != nil {
+			t.Errorf("can't close db: %v", err)
+		}
+	}()
+
+	clock := &testClock{current: time.Date(2020, time.March, 1, 12, 0, 0, 0, time.UTC)}
+	core.SetClock(clock.Now)
+	defer core.SetClock(nil)
+
+	err = core.Init(db)
+	if err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	err = core.AddClient("Vasya", "vasya", "1234", 999999999, db)
+	if err != nil {
+		t.Errorf("unexpected error at AddClient: %v", err)
+	}
+
+	err = core.AddService("Water", db)
+	if err != nil {
+		t.Errorf("unexpected error at AddService: %v", err)
+	}
+
+	err = core.AddAccount(999999999, core.NewMoney(10000, core.DefaultCurrency), db)
+	if err != nil {
+		t.Errorf("unexpected error at AddAccount: %v", err)
+	}
+
+	session, err := core.StartSession("vasya", "1234", "phone", db)
+	if err != nil {
+		t.Errorf("unexpected error at StartSession: %v", err)
+	}
+
+	clock.Advance(core.DefaultSessionTTL - time.Nanosecond)
+
+	err = core.PayForService("Water", 1, session, core.NewMoney(1000, core.DefaultCurrency), db)
+	if err != nil {
+		t.Errorf("unexpected error at PayForService: %v", err)
+	}
+
+	clock.Advance(time.Nanosecond)
+
+	err = core.PayForService("Water", 1, session, core.NewMoney(1000, core.DefaultCurrency), db)
+	if ok := errors.Is(err, core.ErrSessionExpired); !ok {
+		t.Errorf("expected error: %v, found: %v", core.ErrSessionExpired, err)
+	}
+}
@@ -0,0 +1,152 @@
+package tests
+
+import (
+	"database/sql"
+	"fmt"
+	"github.com/JAbduvohidov/apm-ibank-core/pkg/core"
+	_ "github.com/mattn/go-sqlite3"
+	"math/rand"
+	"reflect"
+	"testing"
+)
+
+func randomSnapshot(random *rand.Rand) core.Snapshot {
+	snapshot := core.Snapshot{}
+
+	statuses := []string{core.Active, core.Locked}
+	for i := 1; i <= random.Intn(8); i++ {
+		snapshot.Clients = append(snapshot.Clients, core.Client{
+			Id:          int64(i),
+			Name:        fmt.Sprintf("Client %d", random.Intn(1000)),
+			Login:       fmt.Sprintf("client%d", i),
+			Password:    fmt.Sprintf("pass%d", random.Intn(1000)),
+			PhoneNumber: int64(900000000 + i),
+			Status:      statuses[random.Intn(len(statuses))],
+		})
+	}
+
+	currencies := []string{core.DefaultCurrency, "USD", "EUR", "JPY"}
+	if len(snapshot.Clients) > 0 {
+		for i := 1; i <= random.Intn(12); i++ {
+			snapshot.Accounts = append(snapshot.Accounts, core.AccountWithClientId{
+				Id:       int64(i),
+				ClientId: snapshot.Clients[random.Intn(len(snapshot.Clients))].Id,
+				Balance:  core.NewMoney(random.Int63n(100000000), currencies[random.Intn(len(currencies))]),
+			})
+		}
+	}
+
+	for i := 1; i <= random.Intn(5); i++ {
+		snapshot.ATMs = append(snapshot.ATMs, core.ATM{
+			Id:       int64(i),
+			Name:     fmt.Sprintf("ATM %d", random.Intn(1000)),
+			Location: fmt.Sprintf("location%d", i),
+		})
+	}
+
+	return snapshot
+}
+
+func openSnapshotDb(t *testing.T) *sql.DB {
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatalf("can't open db: %v", err)
+	}
+	db.SetMaxOpenConns(1)
+
+	err = core.Init(db)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	return db
+}
+
+func TestSnapshotRoundTrip(t *testing.T) {
+	core.SetPasswordHasher(core.NewBcryptHasher(4))
+	defer core.SetPasswordHasher(core.DefaultPasswordHasher)
+
+	random := rand.New(rand.NewSource(1))
+	for i := 0; i < 25; i++ {
+		source := openSnapshotDb(t)
+		target := openSnapshotDb(t)
+
+		err := core.ImportSnapshot(randomSnapshot(random), source)
+		if err != nil {
+			t.Fatalf("unexpected error at ImportSnapshot: %v", err)
+		}
+
+		exported, err := core.ExportSnapshot(source)
+		if err != nil {
+			t.Fatalf("unexpected error at ExportSnapshot: %v", err)
+		}
+
+		err = core.ImportSnapshot(exported, target)
+		if err != nil {
+			t.Fatalf("unexpected error at ImportSnapshot: %v", err)
+		}
+
+		reimported, err := core.ExportSnapshot(target)
+		if err != nil {
+			t.Fatalf("unexpected error at ExportSnapshot: %v", err)
+		}
+
+		if !reflect.DeepEqual(exported, reimported) {
+			t.Errorf("export -> import must be lossless\nexported:   %v\nreimported: %v", exported, reimported)
+		}
+
+		err = core.ImportSnapshot(reimported, target)
+		if err != nil {
+			t.Fatalf("unexpected error at ImportSnapshot: %v", err)
+		}
+
+		again, err := core.ExportSnapshot(target)
+		if err != nil {
+			t.Fatalf("unexpected error at ExportSnapshot: %v", err)
+		}
+
+		if !reflect.DeepEqual(reimported, again) {
+			t.Errorf("repeated import must not change the database\nbefore: %v\nafter:  %v", reimported, again)
+		}
+
+		_ = source.Close()
+		_ = target.Close()
+	}
+}
+
+func TestImportListOfAccountsKeepsMinorUnits(t *testing.T) {
+	db := openSnapshotDb(t)
+	defer func() {
+		if err := db.Close(); err != nil {
+			t.Errorf("can't close db: %v", err)
+		}
+	}()
+
+	err := core.AddClient("Vasya", "vasya", "1234", 999999999, db)
+	if err != nil {
+		t.Errorf("unexpected error at AddClient: %v", err)
+	}
+
+	err = core.AddAccount(999999999, core.NewMoney(12345, core.DefaultCurrency), db)
+	if err != nil {
+		t.Errorf("unexpected error at AddAccount: %v", err)
+	}
+
+	accounts, err := core.GetListOfAccountsWithClients(db)
+	if err != nil {
+		t.Errorf("unexpected error at GetListOfAccountsWithClients: %v", err)
+	}
+
+	err = core.ImportListOfAccounts(accounts, db)
+	if err != nil {
+		t.Errorf("unexpected error at ImportListOfAccounts: %v", err)
+	}
+
+	reimported, err := core.GetListOfAccountsWithClients(db)
+	if err != nil {
+		t.Errorf("unexpected error at GetListOfAccountsWithClients: %v", err)
+	}
+
+	if !reflect.DeepEqual(accounts, reimported) {
+		t.Errorf("expected: %v, found: %v", accounts, reimported)
+	}
+}
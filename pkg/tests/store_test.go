@@ -0,0 +1,1350 @@
+package tests
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"github.com/JAbduvohidov/apm-ibank-core/pkg/core"
+	_ "github.com/lib/pq"
+	_ "github.com/mattn/go-sqlite3"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// storeBackends open an empty store and return it with a function releasing
+// it. The conformance tests below run against every backend.
+var storeBackends = []struct {
+	name string
+	open func(t *testing.T) (core.Store, func())
+}{
+	{"sqlite", openSQLiteStore},
+	{"memory", openMemoryStore},
+	{"postgres", openPostgresStore},
+}
+
+// openSQLiteStore uses a file, an in-memory database is lost together with
+// the connection database/sql drops when a context ends mid-transaction.
+func openSQLiteStore(t *testing.T) (core.Store, func()) {
+	dir, err := ioutil.TempDir("", "store")
+	if err != nil {
+		t.Fatalf("can't create dir: %v", err)
+	}
+
+	db, err := sql.Open("sqlite3", filepath.Join(dir, "store.sqlite"))
+	if err != nil {
+		t.Fatalf("can't open db: %v", err)
+	}
+	db.SetMaxOpenConns(1)
+
+	return core.NewSQLiteStore(db), func() {
+		if err := db.Close(); err != nil {
+			t.Errorf("can't close db: %v", err)
+		}
+		if err := os.RemoveAll(dir); err != nil {
+			t.Errorf("can't remove dir: %v", err)
+		}
+	}
+}
+
+func openMemoryStore(t *testing.T) (core.Store, func()) {
+	return core.NewMemoryStore(), func() {}
+}
+
+// openPostgresStore runs against the database in IBANK_TEST_POSTGRES_DSN,
+// dropping everything in its public schema first, and is skipped when it
+// isn't set.
+func openPostgresStore(t *testing.T) (core.Store, func()) {
+	dsn := os.Getenv("IBANK_TEST_POSTGRES_DSN")
+	if dsn == "" {
+		t.Skip("IBANK_TEST_POSTGRES_DSN is not set")
+	}
+
+	db, err := sql.Open("postgres", dsn)
+	if err != nil {
+		t.Fatalf("can't open db: %v", err)
+	}
+
+	// Dropping the whole schema leaves no table or sequence behind, not even
+	// of tables added later.
+	_, err = db.Exec(`DROP SCHEMA public CASCADE;
+CREATE SCHEMA public;`)
+	if err != nil {
+		t.Fatalf("can't drop schema: %v", err)
+	}
+
+	return core.NewPostgresStore(db), func() {
+		if err := db.Close(); err != nil {
+			t.Errorf("can't close db: %v", err)
+		}
+	}
+}
+
+// forEachStore runs test against every backend, with the store initialised.
+func forEachStore(t *testing.T, test func(t *testing.T, store core.Store)) {
+	for _, backend := range storeBackends {
+		open := backend.open
+		t.Run(backend.name, func(t *testing.T) {
+			store, closeStore := open(t)
+			defer closeStore()
+
+			err := store.Init(context.Background())
+			if err != nil {
+				t.Fatalf("unexpected error at Init: %v", err)
+			}
+
+			test(t, store)
+		})
+	}
+}
+
+// addClients adds a client for every phone number, with the number as the
+// login, so that rows referring to clients can be saved.
+func addClients(t *testing.T, store core.Store, phoneNumbers ...int64) {
+	inTx(t, store, func(tx core.Tx) error {
+		for _, phoneNumber := range phoneNumbers {
+			login := strconv.FormatInt(phoneNumber, 10)
+			_, err := tx.AddClient(context.Background(), core.Client{Name: login, Login: login, Password: login, PhoneNumber: phoneNumber})
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// failInTx runs do in a transaction of store that is rolled back, do must
+// fail. Postgres aborts a transaction at its first error, so every expected
+// failure gets a transaction of its own.
+func failInTx(t *testing.T, store core.Store, message string, do func(tx core.Tx) error) {
+	tx, err := store.Begin(context.Background(), core.TxOptions{})
+	if err != nil {
+		t.Fatalf("unexpected error at Begin: %v", err)
+	}
+
+	err = do(tx)
+	if err == nil {
+		t.Errorf("%s must fail", message)
+	}
+
+	err = tx.Rollback()
+	if err != nil {
+		t.Errorf("unexpected error at Rollback: %v", err)
+	}
+}
+
+// inTx runs do in a transaction of store and commits it.
+func inTx(t *testing.T, store core.Store, do func(tx core.Tx) error) {
+	tx, err := store.Begin(context.Background(), core.TxOptions{})
+	if err != nil {
+		t.Fatalf("unexpected error at Begin: %v", err)
+	}
+
+	err = do(tx)
+	if err != nil {
+		_ = tx.Rollback()
+		t.Errorf("unexpected error: %v", err)
+		return
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		t.Errorf("unexpected error at Commit: %v", err)
+	}
+}
+
+func TestStoreClients(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store core.Store) {
+		ctx := context.Background()
+		inTx(t, store, func(tx core.Tx) error {
+			for _, client := range []core.Client{
+				{Name: "Vasya", Login: "vasya", Password: "1234", PhoneNumber: 1234},
+				{Name: "Petya", Login: "petya", Password: "4321", PhoneNumber: 4321},
+				{Name: "Masha", Login: "masha", Password: "1111", PhoneNumber: 5555},
+			} {
+				_, err := tx.AddClient(ctx, client)
+				if err != nil {
+					return err
+				}
+			}
+
+			return nil
+		})
+
+		failInTx(t, store, "duplicate login", func(tx core.Tx) error {
+			_, err := tx.AddClient(ctx, core.Client{Name: "Vasya", Login: "vasya", Password: "1", PhoneNumber: 1})
+			return err
+		})
+
+		failInTx(t, store, "duplicate phone number", func(tx core.Tx) error {
+			_, err := tx.AddClient(ctx, core.Client{Name: "Vasya", Login: "other", Password: "1", PhoneNumber: 1234})
+			return err
+		})
+
+		inTx(t, store, func(tx core.Tx) error {
+			client, err := tx.GetClientByLogin(ctx, "petya")
+			if err != nil {
+				return err
+			}
+			expected := core.Client{Id: 2, Name: "Petya", Login: "petya", Password: "4321", PhoneNumber: 4321, Status: core.Active}
+			if client != expected {
+				t.Errorf("expected: %v, found: %v", expected, client)
+			}
+
+			_, err = tx.GetClientByLogin(ctx, "unknown")
+			if ok := errors.Is(err, core.ErrLoginNotFound); !ok {
+				t.Errorf("expected error: %v, found: %v", core.ErrLoginNotFound, err)
+			}
+
+			_, err = tx.GetClientByPhoneNumber(ctx, 1)
+			if ok := errors.Is(err, core.ErrPhoneNumberNotExist); !ok {
+				t.Errorf("expected error: %v, found: %v", core.ErrPhoneNumberNotExist, err)
+			}
+
+			_, err = tx.GetClient(ctx, 10)
+			if ok := errors.Is(err, core.ErrClientNotExist); !ok {
+				t.Errorf("expected error: %v, found: %v", core.ErrClientNotExist, err)
+			}
+
+			return nil
+		})
+
+		inTx(t, store, func(tx core.Tx) error {
+			err := tx.SetClientStatus(ctx, 1, core.Locked)
+			if err != nil {
+				return err
+			}
+
+			err = tx.SetClientPassword(ctx, "masha", "2222")
+			if err != nil {
+				return err
+			}
+
+			err = tx.SaveClient(ctx, core.Client{Id: 10, Name: "Anya", Login: "anya", Password: "3333", PhoneNumber: 7777, Status: core.Active})
+			if err != nil {
+				return err
+			}
+
+			return nil
+		})
+
+		failInTx(t, store, "saving a taken login", func(tx core.Tx) error {
+			return tx.SaveClient(ctx, core.Client{Id: 2, Name: "Petya", Login: "vasya", Password: "4321", PhoneNumber: 4321, Status: core.Active})
+		})
+
+		inTx(t, store, func(tx core.Tx) error {
+			clients, err := tx.ListClients(ctx)
+			if err != nil {
+				return err
+			}
+			expected := []core.Client{
+				{Id: 1, Name: "Vasya", Login: "vasya", Password: "1234", PhoneNumber: 1234, Status: core.Locked},
+				{Id: 2, Name: "Petya", Login: "petya", Password: "4321", PhoneNumber: 4321, Status: core.Active},
+				{Id: 3, Name: "Masha", Login: "masha", Password: "2222", PhoneNumber: 5555, Status: core.Active},
+				{Id: 10, Name: "Anya", Login: "anya", Password: "3333", PhoneNumber: 7777, Status: core.Active},
+			}
+			if !reflect.DeepEqual(clients, expected) {
+				t.Errorf("expected: %v, found: %v", expected, clients)
+			}
+
+			id, err := tx.AddClient(ctx, core.Client{Name: "Olya", Login: "olya", Password: "4444", PhoneNumber: 8888})
+			if err != nil {
+				return err
+			}
+			if id != 11 {
+				t.Errorf("expected id: 11, found: %d", id)
+			}
+
+			count, err := tx.CountClients(ctx)
+			if err != nil {
+				return err
+			}
+			if count != 5 {
+				t.Errorf("expected count: 5, found: %d", count)
+			}
+
+			for _, test := range []struct {
+				name     string
+				list     func() ([]core.Client, error)
+				expected []int64
+			}{
+				{"by name", func() ([]core.Client, error) { return tx.ListClientsByName(ctx, 2, 1) }, []int64{2, 11}},
+				{"by name past the end", func() ([]core.Client, error) { return tx.ListClientsByName(ctx, 2, 5) }, nil},
+				{"after", func() ([]core.Client, error) { return tx.ListClientsAfter(ctx, 2, 2) }, []int64{3, 10}},
+				{"before", func() ([]core.Client, error) { return tx.ListClientsBefore(ctx, 10, 5) }, []int64{3, 2, 1}},
+				{"name", func() ([]core.Client, error) { return tx.SearchClientsByName(ctx, "ASH") }, []int64{3}},
+				{"phone number", func() ([]core.Client, error) { return tx.SearchClientsByPhoneNumber(ctx, "7") }, []int64{10}},
+			} {
+				clients, err := test.list()
+				if err != nil {
+					return err
+				}
+
+				var ids []int64
+				for _, client := range clients {
+					ids = append(ids, client.Id)
+				}
+				if !reflect.DeepEqual(ids, test.expected) {
+					t.Errorf("%s: expected: %v, found: %v", test.name, test.expected, ids)
+				}
+			}
+
+			return nil
+		})
+	})
+}
+
+func TestStoreAccounts(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store core.Store) {
+		ctx := context.Background()
+		addClients(t, store, 1234, 4321)
+		inTx(t, store, func(tx core.Tx) error {
+			for _, balance := range []core.Money{
+				core.NewMoney(1000, core.DefaultCurrency),
+				core.NewMoney(0, "USD"),
+				core.NewMoney(500, core.DefaultCurrency),
+			} {
+				_, err := tx.AddAccount(ctx, 1, balance)
+				if err != nil {
+					return err
+				}
+			}
+
+			return nil
+		})
+
+		failInTx(t, store, "negative balance", func(tx core.Tx) error {
+			_, err := tx.AddAccount(ctx, 1, core.NewMoney(-1, core.DefaultCurrency))
+			return err
+		})
+
+		failInTx(t, store, "balance below zero", func(tx core.Tx) error {
+			return tx.UpdateBalance(ctx, 1, -1001)
+		})
+
+		inTx(t, store, func(tx core.Tx) error {
+			err := tx.UpdateBalance(ctx, 1, -400)
+			if err != nil {
+				return err
+			}
+
+			err = tx.UpdateBalance(ctx, 10, 1)
+			if ok := errors.Is(err, core.ErrAccountNotExist); !ok {
+				t.Errorf("expected error: %v, found: %v", core.ErrAccountNotExist, err)
+			}
+
+			err = tx.SaveAccount(ctx, core.AccountWithClientId{Id: 2, ClientId: 2, Balance: core.NewMoney(300, "USD")})
+			if err != nil {
+				return err
+			}
+
+			account, err := tx.GetAccount(ctx, 1)
+			if err != nil {
+				return err
+			}
+			expected := core.AccountWithClientId{Id: 1, ClientId: 1, Balance: core.NewMoney(600, core.DefaultCurrency)}
+			if account != expected {
+				t.Errorf("expected: %v, found: %v", expected, account)
+			}
+
+			_, err = tx.GetAccount(ctx, 10)
+			if ok := errors.Is(err, core.ErrAccountNotExist); !ok {
+				t.Errorf("expected error: %v, found: %v", core.ErrAccountNotExist, err)
+			}
+
+			id, err := tx.FindClientAccount(ctx, 1, core.DefaultCurrency)
+			if err != nil {
+				return err
+			}
+			if id != 1 {
+				t.Errorf("expected account: 1, found: %d", id)
+			}
+
+			_, err = tx.FindClientAccount(ctx, 1, "USD")
+			if ok := errors.Is(err, core.ErrAccountNotExist); !ok {
+				t.Errorf("expected error: %v, found: %v", core.ErrAccountNotExist, err)
+			}
+
+			accounts, err := tx.ListClientAccounts(ctx, 1)
+			if err != nil {
+				return err
+			}
+			expectedAccounts := []core.Account{
+				{Id: 1, Balance: core.NewMoney(600, core.DefaultCurrency)},
+				{Id: 3, Balance: core.NewMoney(500, core.DefaultCurrency)},
+			}
+			if !reflect.DeepEqual(accounts, expectedAccounts) {
+				t.Errorf("expected: %v, found: %v", expectedAccounts, accounts)
+			}
+
+			return nil
+		})
+	})
+}
+
+func TestStoreServicesAndATMs(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store core.Store) {
+		ctx := context.Background()
+		inTx(t, store, func(tx core.Tx) error {
+			err := tx.AddService(ctx, "Internet")
+			if err != nil {
+				return err
+			}
+
+			return nil
+		})
+
+		failInTx(t, store, "duplicate service", func(tx core.Tx) error {
+			return tx.AddService(ctx, "Internet")
+		})
+
+		inTx(t, store, func(tx core.Tx) error {
+			exists, err := tx.ServiceExists(ctx, "Internet")
+			if err != nil || !exists {
+				t.Errorf("service must exist, found: %v, %v", exists, err)
+			}
+
+			exists, err = tx.ServiceExists(ctx, "Mobile")
+			if err != nil || exists {
+				t.Errorf("service must not exist, found: %v, %v", exists, err)
+			}
+
+			for _, atm := range []core.ATM{
+				{Name: "Center", Location: "Rudaki 1"},
+				{Name: "North", Location: "Somoni 2"},
+			} {
+				err = tx.AddATM(ctx, atm)
+				if err != nil {
+					return err
+				}
+			}
+
+			return nil
+		})
+
+		failInTx(t, store, "duplicate location", func(tx core.Tx) error {
+			return tx.AddATM(ctx, core.ATM{Name: "Other", Location: "Rudaki 1"})
+		})
+
+		inTx(t, store, func(tx core.Tx) error {
+			err := tx.SaveATM(ctx, core.ATM{Id: 5, Name: "Moved", Location: "Somoni 2"})
+			if err != nil {
+				return err
+			}
+
+			atms, err := tx.ListATMs(ctx)
+			if err != nil {
+				return err
+			}
+			expected := []core.ATM{
+				{Id: 1, Name: "Center", Location: "Rudaki 1"},
+				{Id: 5, Name: "Moved", Location: "Somoni 2"},
+			}
+			if !reflect.DeepEqual(atms, expected) {
+				t.Errorf("expected: %v, found: %v", expected, atms)
+			}
+
+			return nil
+		})
+	})
+}
+
+func TestStoreJournal(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store core.Store) {
+		ctx := context.Background()
+		date := time.Date(2020, time.January, 1, 0, 0, 0, 0, time.UTC)
+		addClients(t, store, 1234, 4321)
+		inTx(t, store, func(tx core.Tx) error {
+			for _, balance := range []core.Money{core.NewMoney(1000, core.DefaultCurrency), core.NewMoney(1000, "USD")} {
+				_, err := tx.AddAccount(ctx, 1, balance)
+				if err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+
+		inTx(t, store, func(tx core.Tx) error {
+			for index, journal := range []core.Journal{
+				{Date: date.Add(2 * time.Hour), Type: core.Transfer, AccountId: 1, Counterparty: "4321", Amount: core.NewMoney(100, core.DefaultCurrency)},
+				{Date: date, Type: core.Service, AccountId: 1, Counterparty: "Internet", Amount: core.NewMoney(200, core.DefaultCurrency)},
+				{Date: date.Add(time.Hour), Type: core.Transfer, AccountId: 2, Counterparty: "4321", Amount: core.NewMoney(300, "USD")},
+				{Date: date.Add(time.Hour), Type: core.Transfer, AccountId: 1, Counterparty: "5555", Amount: core.NewMoney(400, core.DefaultCurrency)},
+			} {
+				journal.Direction = core.Outgoing
+				journal.TransferredTo = journal.Counterparty
+				id, err := tx.AddJournal(ctx, 1, journal)
+				if err != nil {
+					return err
+				}
+				if id != int64(index+1) {
+					t.Errorf("expected id: %d, found: %d", index+1, id)
+				}
+			}
+
+			_, err := tx.AddJournal(ctx, 2, core.Journal{Date: date, Type: core.Transfer, Amount: core.NewMoney(100, core.DefaultCurrency)})
+			if err != nil {
+				return err
+			}
+
+			return nil
+		})
+
+		failInTx(t, store, "zero amount", func(tx core.Tx) error {
+			_, err := tx.AddJournal(ctx, 1, core.Journal{Date: date, Type: core.Transfer, Amount: core.NewMoney(0, core.DefaultCurrency)})
+			return err
+		})
+
+		inTx(t, store, func(tx core.Tx) error {
+			minAmount := core.NewMoney(150, core.DefaultCurrency)
+			for _, test := range []struct {
+				name     string
+				filter   core.JournalFilter
+				expected []int64
+				count    int64
+			}{
+				{"all", core.JournalFilter{ClientId: 1, Limit: -1}, []int64{2, 3, 4, 1}, 4},
+				{"descending", core.JournalFilter{ClientId: 1, Descending: true, Limit: 2, Offset: 1}, []int64{4, 3}, 4},
+				{"dates", core.JournalFilter{ClientId: 1, From: date.Add(time.Hour), To: date.Add(2 * time.Hour), Limit: -1}, []int64{3, 4}, 2},
+				{"types", core.JournalFilter{ClientId: 1, Types: []string{core.Service}, Limit: -1}, []int64{2}, 1},
+				{"counterparty", core.JournalFilter{ClientId: 1, Counterparty: "4321", Limit: -1}, []int64{3, 1}, 2},
+				{"account", core.JournalFilter{ClientId: 1, AccountId: 2, Limit: -1}, []int64{3}, 1},
+				{"amount", core.JournalFilter{ClientId: 1, MinAmount: &minAmount, Limit: -1}, []int64{2, 4}, 2},
+				{"after", core.JournalFilter{ClientId: 1, After: &core.JournalPosition{Date: date.Add(time.Hour), Id: 3}, Limit: 10}, []int64{4, 1}, 4},
+				{"before", core.JournalFilter{ClientId: 1, Descending: true, After: &core.JournalPosition{Date: date.Add(time.Hour), Id: 4}, Limit: 10}, []int64{3, 2}, 4},
+				{"other client", core.JournalFilter{ClientId: 3, Limit: -1}, nil, 0},
+			} {
+				journals, err := tx.ListJournal(ctx, test.filter)
+				if err != nil {
+					return err
+				}
+
+				var ids []int64
+				for _, journal := range journals {
+					ids = append(ids, journal.Id)
+				}
+				if !reflect.DeepEqual(ids, test.expected) {
+					t.Errorf("%s: expected: %v, found: %v", test.name, test.expected, ids)
+				}
+
+				count, err := tx.CountJournal(ctx, test.filter)
+				if err != nil {
+					return err
+				}
+				if count != test.count {
+					t.Errorf("%s: expected count: %d, found: %d", test.name, test.count, count)
+				}
+			}
+
+			journals, err := tx.ListJournal(ctx, core.JournalFilter{ClientId: 1, Types: []string{core.Service}, Limit: 1})
+			if err != nil {
+				return err
+			}
+			expected := []core.Journal{{
+				Id:            2,
+				Date:          date,
+				Type:          core.Service,
+				Direction:     core.Outgoing,
+				AccountId:     1,
+				Counterparty:  "Internet",
+				TransferredTo: "Internet",
+				Amount:        core.NewMoney(200, core.DefaultCurrency),
+			}}
+			if !reflect.DeepEqual(journals, expected) {
+				t.Errorf("expected: %v, found: %v", expected, journals)
+			}
+
+			return nil
+		})
+	})
+}
+
+func TestStoreLedger(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store core.Store) {
+		ctx := context.Background()
+		addClients(t, store, 1234)
+		inTx(t, store, func(tx core.Tx) error {
+			_, err := tx.AddAccount(ctx, 1, core.NewMoney(700, core.DefaultCurrency))
+			if err != nil {
+				return err
+			}
+
+			_, err = tx.AddPosting(ctx, core.Posting{Type: "opening", CreatedAt: time.Now(), Entries: []core.LedgerEntry{
+				{AccountId: 1, Amount: core.NewMoney(500, core.DefaultCurrency)},
+				{SystemAccount: "opening", Amount: core.NewMoney(-500, core.DefaultCurrency)},
+			}})
+			if err != nil {
+				return err
+			}
+
+			account, ledger, found, err := tx.GetAccountOutOfLedger(ctx)
+			if err != nil {
+				return err
+			}
+			if !found || account.Id != 1 || ledger != core.NewMoney(500, core.DefaultCurrency) {
+				t.Errorf("account 1 must be out of ledger, found: %v, %v, %v", account, ledger, found)
+			}
+
+			id, err := tx.AddPosting(ctx, core.Posting{Type: "opening", CreatedAt: time.Now(), Entries: []core.LedgerEntry{
+				{AccountId: 1, Amount: core.NewMoney(200, core.DefaultCurrency)},
+				{AccountId: 1, Amount: core.NewMoney(10, "USD")},
+				{SystemAccount: "opening", Amount: core.NewMoney(-200, core.DefaultCurrency)},
+			}})
+			if err != nil {
+				return err
+			}
+
+			balances, err := tx.GetLedgerBalances(ctx, 1)
+			if err != nil {
+				return err
+			}
+			expected := []core.Money{core.NewMoney(700, core.DefaultCurrency), core.NewMoney(10, "USD")}
+			if !reflect.DeepEqual(balances, expected) {
+				t.Errorf("expected: %v, found: %v", expected, balances)
+			}
+
+			_, _, found, err = tx.GetAccountOutOfLedger(ctx)
+			if err != nil || found {
+				t.Errorf("accounts must match the ledger, found: %v, %v", found, err)
+			}
+
+			postingId, sum, found, err := tx.GetUnbalancedPosting(ctx)
+			if err != nil {
+				return err
+			}
+			if !found || postingId != id || sum != core.NewMoney(10, "USD") {
+				t.Errorf("posting %d must be unbalanced, found: %d, %v, %v", id, postingId, sum, found)
+			}
+
+			return nil
+		})
+	})
+}
+
+func TestStoreSessionsAndLogins(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store core.Store) {
+		ctx := context.Background()
+		date := time.Date(2020, time.January, 1, 0, 0, 0, 0, time.UTC)
+		addClients(t, store, 1234, 4321)
+		inTx(t, store, func(tx core.Tx) error {
+			for _, tokenHash := range []string{"first", "second"} {
+				_, err := tx.AddSession(ctx, tokenHash, core.Session{ClientId: 1, Device: "test", CreatedAt: date, ExpiresAt: date.Add(time.Hour)})
+				if err != nil {
+					return err
+				}
+			}
+
+			return nil
+		})
+
+		failInTx(t, store, "duplicate token", func(tx core.Tx) error {
+			_, err := tx.AddSession(ctx, "first", core.Session{ClientId: 2, CreatedAt: date, ExpiresAt: date})
+			return err
+		})
+
+		inTx(t, store, func(tx core.Tx) error {
+			err := tx.RevokeSession(ctx, "first", date)
+			if err != nil {
+				return err
+			}
+
+			session, revoked, err := tx.GetSession(ctx, "second")
+			if err != nil {
+				return err
+			}
+			expected := core.Session{Id: 2, ClientId: 1, Device: "test", CreatedAt: date, ExpiresAt: date.Add(time.Hour)}
+			if revoked || !reflect.DeepEqual(session, expected) {
+				t.Errorf("expected: %v, found: %v, revoked: %v", expected, session, revoked)
+			}
+
+			err = tx.RevokeClientSessions(ctx, 1, date)
+			if err != nil {
+				return err
+			}
+
+			_, revoked, err = tx.GetSession(ctx, "second")
+			if err != nil || !revoked {
+				t.Errorf("session must be revoked, found: %v, %v", revoked, err)
+			}
+
+			_, _, err = tx.GetSession(ctx, "unknown")
+			if ok := errors.Is(err, core.ErrSessionNotFound); !ok {
+				t.Errorf("expected error: %v, found: %v", core.ErrSessionNotFound, err)
+			}
+
+			attempts, err := tx.GetLoginAttempts(ctx, 1)
+			if err != nil || attempts != (core.LoginAttempts{}) {
+				t.Errorf("expected no attempts, found: %v, %v", attempts, err)
+			}
+
+			err = tx.SaveSuccessfulLogin(ctx, 1, date)
+			if err != nil {
+				return err
+			}
+
+			failed := core.LoginAttempts{FailedCount: 3, FirstFailedAt: date.Add(time.Minute), LockedUntil: date.Add(time.Hour)}
+			err = tx.SaveFailedLogins(ctx, 1, failed)
+			if err != nil {
+				return err
+			}
+
+			attempts, err = tx.GetLoginAttempts(ctx, 1)
+			failed.LastLoginAt = date
+			if err != nil || !reflect.DeepEqual(attempts, failed) {
+				t.Errorf("expected: %v, found: %v, %v", failed, attempts, err)
+			}
+
+			err = tx.ResetLoginAttempts(ctx, 1)
+			if err != nil {
+				return err
+			}
+
+			attempts, err = tx.GetLoginAttempts(ctx, 1)
+			if err != nil || !reflect.DeepEqual(attempts, core.LoginAttempts{LastLoginAt: date}) {
+				t.Errorf("expected a reset, found: %v, %v", attempts, err)
+			}
+
+			return nil
+		})
+	})
+}
+
+func TestStoreExchangeRates(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store core.Store) {
+		ctx := context.Background()
+		date := time.Date(2020, time.January, 1, 0, 0, 0, 0, time.UTC)
+		inTx(t, store, func(tx core.Tx) error {
+			for _, rate := range []core.ExchangeRate{
+				{Base: "USD", Quote: core.DefaultCurrency, Buy: 10, Sell: 11, EffectiveAt: date},
+				{Base: "USD", Quote: core.DefaultCurrency, Buy: 12, Sell: 13, EffectiveAt: date.Add(time.Hour)},
+				{Base: "USD", Quote: core.DefaultCurrency, Buy: 14, Sell: 15, EffectiveAt: date.Add(time.Hour)},
+			} {
+				err := tx.AddExchangeRate(ctx, rate)
+				if err != nil {
+					return err
+				}
+			}
+
+			rate, err := tx.GetExchangeRate(ctx, "USD", core.DefaultCurrency, date.Add(30*time.Minute))
+			if err != nil {
+				return err
+			}
+			if rate.Buy != 10 || !rate.EffectiveAt.Equal(date) {
+				t.Errorf("expected the first rate, found: %v", rate)
+			}
+
+			rate, err = tx.GetExchangeRate(ctx, "USD", core.DefaultCurrency, date.Add(time.Hour))
+			if err != nil {
+				return err
+			}
+			if rate.Buy != 14 {
+				t.Errorf("expected the last rate, found: %v", rate)
+			}
+
+			_, err = tx.GetExchangeRate(ctx, "USD", core.DefaultCurrency, date.Add(-time.Hour))
+			if ok := errors.Is(err, core.ErrExchangeRateNotFound); !ok {
+				t.Errorf("expected error: %v, found: %v", core.ErrExchangeRateNotFound, err)
+			}
+
+			return nil
+		})
+	})
+}
+
+func TestStoreIdempotencyKeys(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store core.Store) {
+		ctx := context.Background()
+		date := time.Date(2020, time.January, 1, 0, 0, 0, 0, time.UTC)
+		addClients(t, store, 1234, 4321)
+		inTx(t, store, func(tx core.Tx) error {
+			_, err := tx.AddAccount(ctx, 1, core.NewMoney(1000, core.DefaultCurrency))
+			if err != nil {
+				return err
+			}
+
+			_, err = tx.AddJournal(ctx, 1, core.Journal{Date: date, Type: core.Service, Direction: core.Outgoing, AccountId: 1,
+				Counterparty: "Internet", TransferredTo: "Internet", Amount: core.NewMoney(100, core.DefaultCurrency)})
+			if err != nil {
+				return err
+			}
+
+			for _, client := range []int64{1, 2} {
+				err = tx.AddIdempotencyKey(ctx, client, core.IdempotencyKey{
+					Key:       "first",
+					Request:   "payment",
+					JournalId: 1,
+					Result:    core.NewMoney(100, core.DefaultCurrency),
+					CreatedAt: date,
+				})
+				if err != nil {
+					return err
+				}
+			}
+
+			return tx.AddIdempotencyKey(ctx, 1, core.IdempotencyKey{Key: "second", Request: "payment", JournalId: 1, CreatedAt: date.Add(time.Hour)})
+		})
+
+		failInTx(t, store, "duplicate key", func(tx core.Tx) error {
+			return tx.AddIdempotencyKey(ctx, 1, core.IdempotencyKey{Key: "first", Request: "other", JournalId: 1, CreatedAt: date})
+		})
+
+		inTx(t, store, func(tx core.Tx) error {
+			stored, err := tx.GetIdempotencyKey(ctx, 1, "first")
+			if err != nil {
+				return err
+			}
+			expected := core.IdempotencyKey{Key: "first", Request: "payment", JournalId: 1, Result: core.NewMoney(100, core.DefaultCurrency), CreatedAt: date}
+			if stored != expected {
+				t.Errorf("expected: %v, found: %v", expected, stored)
+			}
+
+			_, err = tx.GetIdempotencyKey(ctx, 2, "second")
+			if ok := errors.Is(err, core.ErrIdempotencyKeyNotFound); !ok {
+				t.Errorf("expected error: %v, found: %v", core.ErrIdempotencyKeyNotFound, err)
+			}
+
+			err = tx.DeleteIdempotencyKeysBefore(ctx, date.Add(time.Minute))
+			if err != nil {
+				return err
+			}
+
+			_, err = tx.GetIdempotencyKey(ctx, 1, "first")
+			if ok := errors.Is(err, core.ErrIdempotencyKeyNotFound); !ok {
+				t.Errorf("expected error: %v, found: %v", core.ErrIdempotencyKeyNotFound, err)
+			}
+
+			_, err = tx.GetIdempotencyKey(ctx, 1, "second")
+			return err
+		})
+	})
+}
+
+func TestStoreReversals(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store core.Store) {
+		ctx := context.Background()
+		date := time.Date(2020, time.January, 1, 0, 0, 0, 0, time.UTC)
+		addClients(t, store, 1234)
+		inTx(t, store, func(tx core.Tx) error {
+			_, err := tx.AddAccount(ctx, 1, core.NewMoney(1000, core.DefaultCurrency))
+			if err != nil {
+				return err
+			}
+
+			for _, journal := range []core.Journal{
+				{Date: date, Type: core.Service, Direction: core.Outgoing, AccountId: 1,
+					Counterparty: "Internet", TransferredTo: "Internet", Amount: core.NewMoney(100, core.DefaultCurrency)},
+				{Date: date, Type: core.Reversal, Direction: core.Incoming, AccountId: 1,
+					Counterparty: "Internet", TransferredTo: "Internet", Amount: core.NewMoney(100, core.DefaultCurrency)},
+			} {
+				_, err = tx.AddJournal(ctx, 1, journal)
+				if err != nil {
+					return err
+				}
+			}
+
+			_, err = tx.AddPosting(ctx, core.Posting{JournalId: 1, Type: core.Service, CreatedAt: date, Entries: []core.LedgerEntry{
+				{AccountId: 1, Amount: core.NewMoney(-100, core.DefaultCurrency)},
+				{SystemAccount: "services", Amount: core.NewMoney(100, core.DefaultCurrency)},
+			}})
+			if err != nil {
+				return err
+			}
+
+			return tx.AddReversal(ctx, core.JournalReversal{JournalId: 1, ReversalJournalId: 2, Reason: "refund", CreatedAt: date})
+		})
+
+		failInTx(t, store, "second reversal", func(tx core.Tx) error {
+			return tx.AddReversal(ctx, core.JournalReversal{JournalId: 1, ReversalJournalId: 2, Reason: "again", CreatedAt: date})
+		})
+
+		inTx(t, store, func(tx core.Tx) error {
+			clientId, journal, err := tx.GetJournal(ctx, 2)
+			if err != nil {
+				return err
+			}
+			if clientId != 1 || journal.Id != 2 || journal.Type != core.Reversal {
+				t.Errorf("expected reversal entry 2 of client 1, found: %d, %v", clientId, journal)
+			}
+
+			_, _, err = tx.GetJournal(ctx, 3)
+			if ok := errors.Is(err, core.ErrJournalNotFound); !ok {
+				t.Errorf("expected error: %v, found: %v", core.ErrJournalNotFound, err)
+			}
+
+			posting, err := tx.GetJournalPosting(ctx, 1)
+			if err != nil {
+				return err
+			}
+			expected := []core.LedgerEntry{
+				{AccountId: 1, Amount: core.NewMoney(-100, core.DefaultCurrency)},
+				{SystemAccount: "services", Amount: core.NewMoney(100, core.DefaultCurrency)},
+			}
+			if posting.JournalId != 1 || posting.Type != core.Service || !reflect.DeepEqual(posting.Entries, expected) {
+				t.Errorf("expected the posting of journal entry 1, found: %v", posting)
+			}
+
+			_, err = tx.GetJournalPosting(ctx, 2)
+			if ok := errors.Is(err, core.ErrPostingNotFound); !ok {
+				t.Errorf("expected error: %v, found: %v", core.ErrPostingNotFound, err)
+			}
+
+			reversal, err := tx.GetReversal(ctx, 1)
+			if err != nil {
+				return err
+			}
+			expectedReversal := core.JournalReversal{JournalId: 1, ReversalJournalId: 2, Reason: "refund", CreatedAt: date}
+			if reversal != expectedReversal {
+				t.Errorf("expected: %v, found: %v", expectedReversal, reversal)
+			}
+
+			_, err = tx.GetReversal(ctx, 2)
+			if ok := errors.Is(err, core.ErrReversalNotFound); !ok {
+				t.Errorf("expected error: %v, found: %v", core.ErrReversalNotFound, err)
+			}
+			return nil
+		})
+	})
+}
+
+func TestStoreProviders(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store core.Store) {
+		ctx := context.Background()
+		date := time.Date(2020, time.January, 1, 0, 0, 0, 0, time.UTC)
+		inTx(t, store, func(tx core.Tx) error {
+			for _, name := range []string{"Tcell", "Megafon"} {
+				_, err := tx.AddProvider(ctx, name, core.DefaultCurrency)
+				if err != nil {
+					return err
+				}
+			}
+
+			err := tx.AddService(ctx, "Internet")
+			if err != nil {
+				return err
+			}
+
+			return tx.SetServiceProvider(ctx, "Internet", 1)
+		})
+
+		failInTx(t, store, "duplicate provider", func(tx core.Tx) error {
+			_, err := tx.AddProvider(ctx, "Tcell", "USD")
+			return err
+		})
+
+		failInTx(t, store, "negative provider balance", func(tx core.Tx) error {
+			return tx.UpdateProviderBalance(ctx, 1, -1)
+		})
+
+		inTx(t, store, func(tx core.Tx) error {
+			providerId, err := tx.GetServiceProvider(ctx, "Internet")
+			if err != nil {
+				return err
+			}
+			if providerId != 1 {
+				t.Errorf("expected provider: 1, found: %d", providerId)
+			}
+
+			_, err = tx.GetServiceProvider(ctx, "Water")
+			if ok := errors.Is(err, core.ErrServiceNotExist); !ok {
+				t.Errorf("expected error: %v, found: %v", core.ErrServiceNotExist, err)
+			}
+
+			err = tx.UpdateProviderBalance(ctx, 2, 500)
+			if err != nil {
+				return err
+			}
+
+			_, err = tx.AddPosting(ctx, core.Posting{Type: core.Service, CreatedAt: date, Entries: []core.LedgerEntry{
+				{SystemAccount: "services", Amount: core.NewMoney(-700, core.DefaultCurrency)},
+				{SystemAccount: "provider:1", Amount: core.NewMoney(200, core.DefaultCurrency)},
+				{SystemAccount: "provider:2", Amount: core.NewMoney(500, core.DefaultCurrency)},
+			}})
+			if err != nil {
+				return err
+			}
+
+			provider, ledger, found, err := tx.GetProviderOutOfLedger(ctx)
+			if err != nil {
+				return err
+			}
+			expected := core.Provider{Id: 1, Name: "Tcell", Balance: core.NewMoney(0, core.DefaultCurrency)}
+			if !found || provider != expected || ledger != core.NewMoney(200, core.DefaultCurrency) {
+				t.Errorf("provider 1 must be out of ledger, found: %v, %v, %v", provider, ledger, found)
+			}
+
+			err = tx.UpdateProviderBalance(ctx, 1, 200)
+			if err != nil {
+				return err
+			}
+
+			_, _, found, err = tx.GetProviderOutOfLedger(ctx)
+			if err != nil || found {
+				t.Errorf("providers must match the ledger, found: %v, %v", found, err)
+			}
+
+			provider, err = tx.GetProviderByName(ctx, "Megafon")
+			if err != nil {
+				return err
+			}
+			expected = core.Provider{Id: 2, Name: "Megafon", Balance: core.NewMoney(500, core.DefaultCurrency)}
+			if provider != expected {
+				t.Errorf("expected: %v, found: %v", expected, provider)
+			}
+
+			_, err = tx.GetProvider(ctx, 3)
+			if ok := errors.Is(err, core.ErrProviderNotExist); !ok {
+				t.Errorf("expected error: %v, found: %v", core.ErrProviderNotExist, err)
+			}
+
+			_, err = tx.AddPosting(ctx, core.Posting{Type: "payouts", CreatedAt: date.Add(time.Hour), Entries: []core.LedgerEntry{
+				{SystemAccount: "provider:1", Amount: core.NewMoney(-50, core.DefaultCurrency)},
+				{SystemAccount: "payouts", Amount: core.NewMoney(50, core.DefaultCurrency)},
+			}})
+			if err != nil {
+				return err
+			}
+
+			totals, err := tx.GetSystemAccountTotals(ctx, "provider:1", date, date.Add(2*time.Hour))
+			if err != nil {
+				return err
+			}
+			expectedTotals := []core.LedgerTotal{
+				{Type: "payouts", Count: 1, Amount: core.NewMoney(-50, core.DefaultCurrency)},
+				{Type: core.Service, Count: 1, Amount: core.NewMoney(200, core.DefaultCurrency)},
+			}
+			if !reflect.DeepEqual(totals, expectedTotals) {
+				t.Errorf("expected: %v, found: %v", expectedTotals, totals)
+			}
+
+			totals, err = tx.GetSystemAccountTotals(ctx, "provider:1", date.Add(time.Hour), date.Add(2*time.Hour))
+			if err != nil {
+				return err
+			}
+			expectedTotals = expectedTotals[:1]
+			if !reflect.DeepEqual(totals, expectedTotals) {
+				t.Errorf("expected: %v, found: %v", expectedTotals, totals)
+			}
+			return nil
+		})
+	})
+}
+
+func TestStoreCatalog(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store core.Store) {
+		ctx := context.Background()
+		date := time.Date(2020, time.January, 1, 0, 0, 0, 0, time.UTC)
+		addClients(t, store, 1234)
+		mobile := core.CatalogService{
+			Name:             "Tcell",
+			Category:         core.Mobile,
+			MinAmount:        core.NewMoney(100, core.DefaultCurrency),
+			MaxAmount:        core.NewMoney(100000, core.DefaultCurrency),
+			FixedFee:         core.NewMoney(50, core.DefaultCurrency),
+			FeePercent:       1500000,
+			ReferencePattern: `\+992\d{9}`,
+		}
+		inTx(t, store, func(tx core.Tx) error {
+			err := tx.AddService(ctx, "Water")
+			if err != nil {
+				return err
+			}
+
+			err = tx.AddCatalogService(ctx, mobile)
+			if err != nil {
+				return err
+			}
+
+			return tx.AddCatalogService(ctx, core.CatalogService{Name: "Babilon", Category: core.Internet})
+		})
+
+		failInTx(t, store, "duplicate service", func(tx core.Tx) error {
+			return tx.AddCatalogService(ctx, core.CatalogService{Name: "Water", Category: core.Utilities})
+		})
+
+		inTx(t, store, func(tx core.Tx) error {
+			service, err := tx.GetCatalogService(ctx, "Tcell")
+			if err != nil {
+				return err
+			}
+			if service != mobile {
+				t.Errorf("expected: %v, found: %v", mobile, service)
+			}
+
+			_, err = tx.GetCatalogService(ctx, "Gas")
+			if ok := errors.Is(err, core.ErrServiceNotExist); !ok {
+				t.Errorf("expected error: %v, found: %v", core.ErrServiceNotExist, err)
+			}
+
+			services, err := tx.ListCatalogServices(ctx, "")
+			if err != nil {
+				return err
+			}
+			expected := []core.CatalogService{{Name: "Water"}, mobile, {Name: "Babilon", Category: core.Internet}}
+			if !reflect.DeepEqual(services, expected) {
+				t.Errorf("expected: %v, found: %v", expected, services)
+			}
+
+			services, err = tx.ListCatalogServices(ctx, core.Internet)
+			if err != nil {
+				return err
+			}
+			expected = expected[2:]
+			if !reflect.DeepEqual(services, expected) {
+				t.Errorf("expected: %v, found: %v", expected, services)
+			}
+
+			services, err = tx.ListCatalogServices(ctx, core.Utilities)
+			if err != nil || len(services) != 0 {
+				t.Errorf("expected no utilities, found: %v, %v", services, err)
+			}
+
+			accountId, err := tx.AddAccount(ctx, 1, core.NewMoney(1000, core.DefaultCurrency))
+			if err != nil {
+				return err
+			}
+
+			paid := core.Journal{Date: date, Type: core.Service, Direction: core.Outgoing, AccountId: accountId,
+				Counterparty: "Tcell", TransferredTo: "Tcell", Amount: core.NewMoney(200, core.DefaultCurrency),
+				Reference: "+992900000000", Fee: core.NewMoney(53, core.DefaultCurrency)}
+			plain := paid
+			plain.Reference, plain.Fee = "", core.NewMoney(0, core.DefaultCurrency)
+			for index, journal := range []core.Journal{paid, plain} {
+				id, err := tx.AddJournal(ctx, 1, journal)
+				if err != nil {
+					return err
+				}
+
+				_, found, err := tx.GetJournal(ctx, id)
+				if err != nil {
+					return err
+				}
+				journal.Id = id
+				if index == 1 {
+					journal.Fee = core.Money{}
+				}
+				if found != journal {
+					t.Errorf("expected: %v, found: %v", journal, found)
+				}
+			}
+			return nil
+		})
+	})
+}
+
+func TestStoreTransactions(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store core.Store) {
+		ctx := context.Background()
+		inTx(t, store, func(tx core.Tx) error {
+			_, err := tx.AddClient(ctx, core.Client{Name: "Vasya", Login: "vasya", Password: "1234", PhoneNumber: 1234})
+			return err
+		})
+
+		tx, err := store.Begin(ctx, core.TxOptions{})
+		if err != nil {
+			t.Fatalf("unexpected error at Begin: %v", err)
+		}
+		_, err = tx.AddClient(ctx, core.Client{Name: "Petya", Login: "petya", Password: "4321", PhoneNumber: 4321})
+		if err != nil {
+			t.Errorf("unexpected error at AddClient: %v", err)
+		}
+		err = tx.Rollback()
+		if err != nil {
+			t.Errorf("unexpected error at Rollback: %v", err)
+		}
+
+		cancelled, cancel := context.WithCancel(ctx)
+		tx, err = store.Begin(cancelled, core.TxOptions{})
+		if err != nil {
+			t.Fatalf("unexpected error at Begin: %v", err)
+		}
+		_, err = tx.AddClient(ctx, core.Client{Name: "Masha", Login: "masha", Password: "1111", PhoneNumber: 5555})
+		if err != nil {
+			t.Errorf("unexpected error at AddClient: %v", err)
+		}
+		cancel()
+		err = tx.Commit()
+		if err == nil {
+			t.Errorf("commit after cancel must fail")
+		}
+
+		_, err = store.Begin(cancelled, core.TxOptions{})
+		if ok := errors.Is(err, context.Canceled); !ok {
+			t.Errorf("expected error: %v, found: %v", context.Canceled, err)
+		}
+
+		inTx(t, store, func(tx core.Tx) error {
+			clients, err := tx.ListClients(ctx)
+			if err != nil {
+				return err
+			}
+			if len(clients) != 1 || clients[0].Login != "vasya" {
+				t.Errorf("only committed clients must be kept, found: %v", clients)
+			}
+			return nil
+		})
+	})
+}
+
+func TestMemoryStoreBeginStopsAtDeadline(t *testing.T) {
+	store := core.NewMemoryStore()
+	tx, err := store.Begin(context.Background(), core.TxOptions{})
+	if err != nil {
+		t.Fatalf("unexpected error at Begin: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err = store.Begin(ctx, core.TxOptions{})
+	if ok := errors.Is(err, context.DeadlineExceeded); !ok {
+		t.Errorf("expected error: %v, found: %v", context.DeadlineExceeded, err)
+	}
+
+	err = tx.Rollback()
+	if err != nil {
+		t.Errorf("unexpected error at Rollback: %v", err)
+	}
+
+	inTx(t, store, func(tx core.Tx) error {
+		return nil
+	})
+}
+
+func TestBankOverEveryStore(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store core.Store) {
+		ctx := context.Background()
+		bank := core.NewBank(store, core.WithPasswordHasher(core.NewBcryptHasher(4)))
+
+		err := bank.AddClient(ctx, "Vasya", "vasya", "1234", 1234)
+		if err != nil {
+			t.Errorf("unexpected error at AddClient: %v", err)
+		}
+
+		err = bank.AddClient(ctx, "Petya", "petya", "4321", 4321)
+		if err != nil {
+			t.Errorf("unexpected error at AddClient: %v", err)
+		}
+
+		err = bank.AddClient(ctx, "Vasya", "vasya", "1234", 1111)
+		if ok := errors.Is(err, core.ErrLoginExist); !ok {
+			t.Errorf("expected error: %v, found: %v", core.ErrLoginExist, err)
+		}
+
+		for _, account := range []struct {
+			phoneNumber int64
+			balance     core.Money
+		}{
+			{1234, core.NewMoney(100000, core.DefaultCurrency)},
+			{1234, core.NewMoney(0, "USD")},
+			{4321, core.NewMoney(0, core.DefaultCurrency)},
+		} {
+			err = bank.AddAccount(ctx, account.phoneNumber, account.balance)
+			if err != nil {
+				t.Errorf("unexpected error at AddAccount: %v", err)
+			}
+		}
+
+		err = bank.AddService(ctx, "Internet")
+		if err != nil {
+			t.Errorf("unexpected error at AddService: %v", err)
+		}
+
+		err = bank.LoadExchangeRates(ctx, []core.ExchangeRate{
+			{Base: "USD", Quote: core.DefaultCurrency, Buy: mustParseRate(t, "10.9"), Sell: mustParseRate(t, "11.05")},
+		})
+		if err != nil {
+			t.Errorf("unexpected error at LoadExchangeRates: %v", err)
+		}
+
+		_, err = bank.StartSession(ctx, "vasya", "4321", "test")
+		if ok := errors.Is(err, core.ErrInvalidCredentials); !ok {
+			t.Errorf("expected error: %v, found: %v", core.ErrInvalidCredentials, err)
+		}
+
+		session, err := bank.StartSession(ctx, "vasya", "1234", "test")
+		if err != nil {
+			t.Errorf("unexpected error at StartSession: %v", err)
+		}
+
+		err = bank.PayForService(ctx, "Internet", 1, session, core.NewMoney(1500, core.DefaultCurrency))
+		if err != nil {
+			t.Errorf("unexpected error at PayForService: %v", err)
+		}
+
+		err = bank.TransferToByPhoneNumber(ctx, 4321, session, 1, core.NewMoney(2500, core.DefaultCurrency))
+		if err != nil {
+			t.Errorf("unexpected error at TransferToByPhoneNumber: %v", err)
+		}
+
+		err = bank.TransferToByAccountId(ctx, 3, session, 1, core.NewMoney(1000000, core.DefaultCurrency))
+		if ok := errors.Is(err, core.ErrInsufficientFunds); !ok {
+			t.Errorf("expected error: %v, found: %v", core.ErrInsufficientFunds, err)
+		}
+
+		converted, err := bank.ConvertBetweenOwnAccounts(ctx, session, 1, 2, core.NewMoney(11050, core.DefaultCurrency))
+		if err != nil {
+			t.Errorf("unexpected error at ConvertBetweenOwnAccounts: %v", err)
+		}
+		if converted != core.NewMoney(1000, "USD") {
+			t.Errorf("expected: %v, found: %v", core.NewMoney(1000, "USD"), converted)
+		}
+
+		accounts, err := bank.GetListOfAccountsWithClients(ctx)
+		if err != nil {
+			t.Errorf("unexpected error at GetListOfAccountsWithClients: %v", err)
+		}
+		expected := []core.AccountWithClientId{
+			{Id: 1, ClientId: 1, Balance: core.NewMoney(84950, core.DefaultCurrency)},
+			{Id: 2, ClientId: 1, Balance: core.NewMoney(1000, "USD")},
+			{Id: 3, ClientId: 2, Balance: core.NewMoney(2500, core.DefaultCurrency)},
+		}
+		if !reflect.DeepEqual(accounts, expected) {
+			t.Errorf("expected: %v, found: %v", expected, accounts)
+		}
+
+		journals, err := bank.GetJournalList(ctx, core.NewJournalQuery("vasya"))
+		if err != nil {
+			t.Errorf("unexpected error at GetJournalList: %v", err)
+		}
+		if len(journals) != 4 {
+			t.Errorf("expected 4 entries, found: %v", journals)
+		}
+
+		err = bank.CheckLedger(ctx)
+		if err != nil {
+			t.Errorf("unexpected error at CheckLedger: %v", err)
+		}
+
+		err = bank.Logout(ctx, session.Token)
+		if err != nil {
+			t.Errorf("unexpected error at Logout: %v", err)
+		}
+
+		_, err = bank.ValidateSession(ctx, session.Token)
+		if ok := errors.Is(err, core.ErrSessionRevoked); !ok {
+			t.Errorf("expected error: %v, found: %v", core.ErrSessionRevoked, err)
+		}
+
+		snapshot, err := bank.ExportSnapshot(ctx)
+		if err != nil {
+			t.Errorf("unexpected error at ExportSnapshot: %v", err)
+		}
+
+		copied := core.NewBank(core.NewMemoryStore())
+		err = copied.ImportSnapshot(ctx, snapshot)
+		if err != nil {
+			t.Errorf("unexpected error at ImportSnapshot: %v", err)
+		}
+
+		exported, err := copied.ExportSnapshot(ctx)
+		if err != nil {
+			t.Errorf("unexpected error at ExportSnapshot: %v", err)
+		}
+		if !reflect.DeepEqual(snapshot, exported) {
+			t.Errorf("expected: %v, found: %v", snapshot, exported)
+		}
+	})
+}